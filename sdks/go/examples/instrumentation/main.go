@@ -200,6 +200,9 @@ func main() {
 	// Example 6: Provider instrumentation
 	registry := untrace.NewProviderRegistry()
 	untrace.RegisterDefaultProviders(registry)
+	if err := registry.InitializeAll(client); err != nil {
+		log.Printf("Failed to initialize providers: %v", err)
+	}
 
 	// Try to instrument the OpenAI client
 	instrumentedClient, err := registry.Instrument("openai", openaiClient)
@@ -24,6 +24,37 @@ type (
 	InstrumentationConfig = untrace.InstrumentationConfig
 	ProviderRegistry      = untrace.ProviderRegistry
 	ProviderInstrumentation = untrace.ProviderInstrumentation
+	MetricTemporality       = untrace.MetricTemporality
+	AttributeConvention     = untrace.AttributeConvention
+	Message                 = untrace.Message
+	ExportError             = untrace.ExportError
+	ModelPricing            = untrace.ModelPricing
+	EmbeddingSpanOptions    = untrace.EmbeddingSpanOptions
+	MetricsSnapshot         = untrace.MetricsSnapshot
+	LatencySummary          = untrace.LatencySummary
+	CacheStats              = untrace.CacheStats
+	LLMError                = untrace.LLMError
+	ConfigurationError      = untrace.ConfigurationError
+	EmbeddingBatch          = untrace.EmbeddingBatch
+	StreamRecorder          = untrace.StreamRecorder
+	ExportFormat            = untrace.ExportFormat
+	SamplerMode             = untrace.SamplerMode
+	RAGOptions              = untrace.RAGOptions
+	SpanStub                = untrace.SpanStub
+	Option                  = untrace.Option
+	TestClient              = untrace.TestClient
+	RAGUsage                = untrace.RAGUsage
+	RAGSteps                = untrace.RAGSteps
+	LLMResult               = untrace.LLMResult
+	OpenAIInstrumentation   = untrace.OpenAIInstrumentation
+	OpenAIWrapper           = untrace.OpenAIWrapper
+	OpenAIClient            = untrace.OpenAIClient
+	GeminiInstrumentation   = untrace.GeminiInstrumentation
+	GeminiWrapper           = untrace.GeminiWrapper
+	MiddlewareOption        = untrace.MiddlewareOption
+	HeaderCarrier           = untrace.HeaderCarrier
+	Redactor                = untrace.Redactor
+	MapCarrier              = untrace.MapCarrier
 )
 
 // Re-export all public functions
@@ -32,14 +63,80 @@ var (
 	InitFromEnv            = untrace.InitFromEnv
 	MustInit               = untrace.MustInit
 	MustInitFromEnv        = untrace.MustInitFromEnv
+	InitForTesting         = untrace.InitForTesting
+	Reset                  = untrace.Reset
+	InitWithOptions        = untrace.InitWithOptions
+	WithExporter           = untrace.WithExporter
+	WithSpanProcessor      = untrace.WithSpanProcessor
+	WithSpanFilter         = untrace.WithSpanFilter
+	WithAttributeProcessor = untrace.WithAttributeProcessor
+	WithSpanAttributes     = untrace.WithSpanAttributes
+	WithSamplingRate       = untrace.WithSamplingRate
 	GetInstance            = untrace.GetInstance
 	DefaultConfig          = untrace.DefaultConfig
 	NewInstrumentation     = untrace.NewInstrumentation
 	NewProviderRegistry    = untrace.NewProviderRegistry
 	GetDefaultProviders    = untrace.GetDefaultProviders
 	RegisterDefaultProviders = untrace.RegisterDefaultProviders
+	NewOpenAIInstrumentation = untrace.NewOpenAIInstrumentation
+	NewGeminiInstrumentation = untrace.NewGeminiInstrumentation
+	NewTransport             = untrace.NewTransport
+	Middleware               = untrace.Middleware
+	WithSkipPaths            = untrace.WithSkipPaths
+	WithRoute                = untrace.WithRoute
+	WithPropagator           = untrace.WithPropagator
+	WithB3Propagation        = untrace.WithB3Propagation
+	WithEvalRun             = untrace.WithEvalRun
+	RegisterModelPricing    = untrace.RegisterModelPricing
+	LookupModelPricing      = untrace.LookupModelPricing
+	SetModelPricing         = untrace.SetModelPricing
+	RegisterContextWindow   = untrace.RegisterContextWindow
+	LookupContextWindow     = untrace.LookupContextWindow
+	CalculateCost           = untrace.CalculateCost
+	RecordClassification    = untrace.RecordClassification
+	RecordLLMError          = untrace.RecordLLMError
+	SetLLMResult            = untrace.SetLLMResult
+	AddEvent                = untrace.AddEvent
+	LinkFromContext         = untrace.LinkFromContext
+	EmbeddingBatchFromContext = untrace.EmbeddingBatchFromContext
+	ShutdownOnSignal        = untrace.ShutdownOnSignal
+	WithConversationID      = untrace.WithConversationID
+	WithConversationIDBaggage = untrace.WithConversationIDBaggage
+	MarkColdStart           = untrace.MarkColdStart
+	RecordRAGContext        = untrace.RecordRAGContext
+	Detach                  = untrace.Detach
+	WorkflowFromContext     = untrace.WorkflowFromContext
+	WithBaggage             = untrace.WithBaggage
+	NewRedactor             = untrace.NewRedactor
+	BaggageFromContext      = untrace.BaggageFromContext
+	NewSeededIDGenerator    = untrace.NewSeededIDGenerator
+	InjectContext           = untrace.InjectContext
+	ExtractContext          = untrace.ExtractContext
+	InjectCarrier           = untrace.InjectCarrier
+	ExtractCarrier          = untrace.ExtractCarrier
 )
 
+// Generic functions cannot be re-exported as variables, so these forward to
+// the internal package
+
+// InstrumentAs instruments a module with the named provider and returns the
+// wrapper as T
+func InstrumentAs[T any](r *ProviderRegistry, name string, module interface{}) (T, error) {
+	return untrace.InstrumentAs[T](r, name, module)
+}
+
+// InstrumentOpenAI instruments an OpenAI chat client and returns it with the
+// same static type
+func InstrumentOpenAI[T untrace.OpenAIChatClient[Req, Resp], Req, Resp any](r *ProviderRegistry, client T) (T, error) {
+	return untrace.InstrumentOpenAI(r, client)
+}
+
+// TypedResponse asserts the response of a wrapped provider call to its
+// concrete type, passing the error through
+func TypedResponse[Resp any](response interface{}, err error) (Resp, error) {
+	return untrace.TypedResponse[Resp](response, err)
+}
+
 // Re-export all public constants
 const (
 	// LLM Operation Types
@@ -52,6 +149,89 @@ const (
 	LLMOperationAudioGeneration  = untrace.LLMOperationAudioGeneration
 	LLMOperationModeration       = untrace.LLMOperationModeration
 	LLMOperationToolUse          = untrace.LLMOperationToolUse
+
+	// Metric temporalities
+	MetricTemporalityCumulative = untrace.MetricTemporalityCumulative
+	MetricTemporalityDelta      = untrace.MetricTemporalityDelta
+
+	// Export formats
+	ExportFormatOTLPProto = untrace.ExportFormatOTLPProto
+	ExportFormatJSON      = untrace.ExportFormatJSON
+	ExportFormatOTLPJSON  = untrace.ExportFormatOTLPJSON
+
+	// Environment variables
+	EnvAPIKey         = untrace.EnvAPIKey
+	EnvServiceName    = untrace.EnvServiceName
+	EnvEnvironment    = untrace.EnvEnvironment
+	EnvVersion        = untrace.EnvVersion
+	EnvBaseURL        = untrace.EnvBaseURL
+	EnvDebug          = untrace.EnvDebug
+	EnvSamplingRate   = untrace.EnvSamplingRate
+	EnvMaxBatchSize   = untrace.EnvMaxBatchSize
+	EnvExportInterval = untrace.EnvExportInterval
+
+	// RAG pipeline stages
+	RAGStageEmbed    = untrace.RAGStageEmbed
+	RAGStageRetrieve = untrace.RAGStageRetrieve
+	RAGStageRerank   = untrace.RAGStageRerank
+	RAGStageGenerate = untrace.RAGStageGenerate
+
+	// LLM span event names
+	LLMFirstTokenEvent    = untrace.LLMFirstTokenEvent
+	LLMToolCallStartEvent = untrace.LLMToolCallStartEvent
+	LLMToolCallEndEvent   = untrace.LLMToolCallEndEvent
+
+	// Workflow statuses
+	WorkflowStatusAbandoned = untrace.WorkflowStatusAbandoned
+
+	// Sampler modes
+	SamplerAlwaysOn     = untrace.SamplerAlwaysOn
+	SamplerTraceIDRatio = untrace.SamplerTraceIDRatio
+	SamplerParentBased  = untrace.SamplerParentBased
+	SamplerRetention    = untrace.SamplerRetention
+
+	// Modalities
+	ModalityText  = untrace.ModalityText
+	ModalityImage = untrace.ModalityImage
+	ModalityAudio = untrace.ModalityAudio
+
+	// Built-in redaction patterns
+	RedactionPatternEmail      = untrace.RedactionPatternEmail
+	RedactionPatternPhone      = untrace.RedactionPatternPhone
+	RedactionPatternCreditCard = untrace.RedactionPatternCreditCard
+
+	// SDK version
+	SDKVersion = untrace.SDKVersion
+
+	// Default context utilization warning threshold
+	DefaultContextUtilizationThreshold = untrace.DefaultContextUtilizationThreshold
+
+	// Config defaults
+	DefaultServiceName    = untrace.DefaultServiceName
+	DefaultBaseURL        = untrace.DefaultBaseURL
+	DefaultMaxBatchSize   = untrace.DefaultMaxBatchSize
+	DefaultExportInterval = untrace.DefaultExportInterval
+
+	// Default export compression threshold in bytes
+	DefaultCompressionThreshold = untrace.DefaultCompressionThreshold
+
+	// Payload schema version
+	PayloadSchemaVersion = untrace.PayloadSchemaVersion
+
+	// Attribute conventions
+	AttributeConventionUntrace       = untrace.AttributeConventionUntrace
+	AttributeConventionOpenInference = untrace.AttributeConventionOpenInference
+)
+
+// Re-export sentinel errors
+var (
+	ErrAlreadyInitialized = untrace.ErrAlreadyInitialized
+)
+
+// Re-export defaults
+var (
+	DefaultLatencyBuckets    = untrace.DefaultLatencyBuckets
+	DefaultRedactionPatterns = untrace.DefaultRedactionPatterns
 )
 
 // Re-export attribute helpers
@@ -70,6 +250,9 @@ var (
 var (
 	CreateLLMAttributes      = untrace.CreateLLMAttributes
 	CreateVectorDBAttributes = untrace.CreateVectorDBAttributes
+	CreateVectorQueryAttributes = untrace.CreateVectorQueryAttributes
+	CreateRAGContextAttributes  = untrace.CreateRAGContextAttributes
+	CreateCostAttributes        = untrace.CreateCostAttributes
 	CreateFrameworkAttributes = untrace.CreateFrameworkAttributes
 	CreateWorkflowAttributes = untrace.CreateWorkflowAttributes
 	SanitizeAttributes       = untrace.SanitizeAttributes
@@ -1,6 +1,7 @@
 package untrace
 
 import (
+	"regexp"
 	"strings"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -19,6 +20,15 @@ const (
 	LLMPromptTokensKey     = "llm.prompt.tokens"
 	LLMCompletionTokensKey = "llm.completion.tokens"
 	LLMTotalTokensKey      = "llm.total.tokens"
+	LLMReasoningTokensKey  = "llm.reasoning.tokens"
+
+	// Prompt cache attributes
+	LLMCacheReadTokensKey     = "llm.cache.read_tokens"
+	LLMCacheCreationTokensKey = "llm.cache.creation_tokens"
+
+	// Response cache attributes
+	LLMCacheHitKey       = "llm.cache.hit"
+	LLMCacheSavedCostKey = "llm.cache.saved_cost"
 
 	// Parameter attributes
 	LLMTemperatureKey = "llm.temperature"
@@ -26,6 +36,25 @@ const (
 	LLMMaxTokensKey   = "llm.max_tokens"
 	LLMStreamKey      = "llm.stream"
 
+	// Streaming attributes
+	LLMStreamAbortedKey      = "llm.stream.aborted"
+	LLMStreamTokensKey       = "llm.stream.tokens"
+	LLMStreamChunksKey       = "llm.stream.chunks"
+	LLMTimeToFirstTokenMsKey = "llm.time_to_first_token_ms"
+
+	// Modality attributes
+	LLMModalitiesKey        = "llm.modalities"
+	LLMInputImageCountKey   = "llm.input.image_count"
+	LLMInputAudioSecondsKey = "llm.input.audio_seconds"
+
+	// Context window attributes
+	LLMContextWindowKey          = "llm.context.window"
+	LLMContextUtilizationKey     = "llm.context.utilization"
+	LLMContextHighUtilizationKey = "llm.context.high_utilization"
+
+	// Queueing attributes
+	LLMQueueWaitMsKey = "llm.queue.wait_ms"
+
 	// Tool attributes
 	LLMToolsKey     = "llm.tools"
 	LLMToolCallsKey = "llm.tool_calls"
@@ -34,17 +63,46 @@ const (
 	LLMDurationMsKey = "llm.duration_ms"
 
 	// Cost attributes
-	LLMCostPromptKey     = "llm.cost.prompt"
-	LLMCostCompletionKey = "llm.cost.completion"
-	LLMCostTotalKey      = "llm.cost.total"
+	LLMCostPromptKey       = "llm.cost.prompt"
+	LLMCostCompletionKey   = "llm.cost.completion"
+	LLMCostReasoningKey    = "llm.cost.reasoning"
+	LLMCostTotalKey        = "llm.cost.total"
+	LLMCostNativeAmountKey = "llm.cost.native.amount"
+	LLMCostNativeUnitKey   = "llm.cost.native.unit"
 
 	// Error attributes
-	LLMErrorKey     = "llm.error"
-	LLMErrorTypeKey = "llm.error.type"
+	LLMErrorKey          = "llm.error"
+	LLMErrorTypeKey      = "llm.error.type"
+	LLMErrorCodeKey      = "llm.error.code"
+	LLMErrorParamKey     = "llm.error.param"
+	LLMErrorRequestIDKey = "llm.error.request_id"
 
 	// Request attributes
 	LLMRequestIDKey    = "llm.request.id"
 	LLMUsageReasonKey  = "llm.usage.reason"
+	LLMStopMatchedKey  = "llm.stop.matched"
+
+	// Content attributes
+	LLMPromptKey     = "llm.prompt"
+	LLMCompletionKey = "llm.completion"
+	LLMPromptHashKey = "llm.prompt.hash"
+)
+
+// OpenInference attribute keys and event names
+const (
+	OpenInferenceInputEvent        = "input"
+	OpenInferenceOutputEvent       = "output"
+	OpenInferenceInputValueKey     = "input.value"
+	OpenInferenceInputMimeTypeKey  = "input.mime_type"
+	OpenInferenceOutputValueKey    = "output.value"
+	OpenInferenceOutputMimeTypeKey = "output.mime_type"
+)
+
+// LLM span event names
+const (
+	LLMFirstTokenEvent    = "llm.first_token"
+	LLMToolCallStartEvent = "llm.tool_call.start"
+	LLMToolCallEndEvent   = "llm.tool_call.end"
 )
 
 // Vector DB attribute keys
@@ -59,6 +117,18 @@ const (
 	VectorQueryKKey  = "vector.query.k"
 	VectorQueryFilterKey = "vector.query.filter"
 	VectorQueryMetricKey = "vector.query.metric"
+	VectorResultsCountKey    = "vector.results.count"
+	VectorResultsTopScoreKey = "vector.results.top_score"
+	VectorResultsMinScoreKey = "vector.results.min_score"
+)
+
+// RAG attribute keys
+const (
+	RAGContextCharsKey  = "rag.context.chars"
+	RAGContextTokensKey = "rag.context.tokens"
+	RAGStageKey         = "rag.stage"
+	RAGTotalTokensKey   = "rag.total.tokens"
+	RAGCostTotalKey     = "rag.cost.total"
 )
 
 // Framework attribute keys
@@ -72,6 +142,8 @@ const (
 	FrameworkAgentTypeKey = "framework.agent.type"
 	FrameworkToolNameKey  = "framework.tool.name"
 	FrameworkToolTypeKey  = "framework.tool.type"
+	FrameworkToolInputKey  = "framework.tool.input"
+	FrameworkToolOutputKey = "framework.tool.output"
 )
 
 // Workflow attribute keys
@@ -82,9 +154,70 @@ const (
 	WorkflowParentIDKey = "workflow.parent_id"
 	WorkflowUserIDKey   = "workflow.user_id"
 	WorkflowSessionIDKey = "workflow.session_id"
+	WorkflowStatusKey    = "workflow.status"
 	WorkflowMetadataKey = "workflow.metadata"
 )
 
+// Evaluation attribute keys
+const (
+	EvalDatasetIDKey = "eval.dataset.id"
+	EvalRunIDKey     = "eval.run.id"
+)
+
+// FaaS attribute keys
+const (
+	FaaSColdStartKey = "faas.cold_start"
+)
+
+// Conversation attribute keys
+const (
+	ConversationIDKey = "conversation.id"
+)
+
+// Error attribute keys and event names
+const (
+	ErrorEvent      = "error"
+	ErrorTypeKey    = "error.type"
+	ErrorMessageKey = "error.message"
+)
+
+// Function attribute keys
+const (
+	// FunctionArgKeyPrefix prefixes the captured arguments of a traced
+	// function, e.g. function.arg.0
+	FunctionArgKeyPrefix = "function.arg"
+)
+
+// Retry attribute keys
+const (
+	RetryAttemptKey   = "retry.attempt"
+	RetryAttemptsKey  = "retry.attempts"
+	RetryBackoffMsKey = "retry.backoff_ms"
+)
+
+// Request attribute keys
+const (
+	RequestDeadlineRemainingMsKey = "request.deadline_remaining_ms"
+)
+
+// HTTP attribute keys
+const (
+	HTTPMethodKey       = "http.method"
+	HTTPRouteKey        = "http.route"
+	HTTPStatusCodeKey   = "http.status_code"
+	HTTPRequestBodyKey  = "http.request.body"
+	HTTPResponseBodyKey = "http.response.body"
+)
+
+// SDK attribute keys
+const (
+	UntraceSpanTruncatedKey       = "untrace.span.truncated"
+	UntraceSpanCountKey           = "untrace.span.count"
+	UntraceSpanDurationTotalMsKey = "untrace.span.duration_total_ms"
+	UntraceSampledReasonKey       = "untrace.sampled.reason"
+	UntraceSamplingForcedKey      = "untrace.sampling.forced"
+)
+
 // CreateLLMAttributes creates LLM-specific attributes
 func CreateLLMAttributes(provider, model string, operation LLMOperationType) []attribute.KeyValue {
 	return []attribute.KeyValue{
@@ -102,6 +235,65 @@ func CreateVectorDBAttributes(system, operation string) []attribute.KeyValue {
 	}
 }
 
+// CreateVectorQueryAttributes summarizes the similarity scores returned by a
+// vector query: the metric used, the result count and the highest and lowest
+// score
+func CreateVectorQueryAttributes(metric string, scores []float64) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String(VectorQueryMetricKey, metric),
+		attribute.Int(VectorResultsCountKey, len(scores)),
+	}
+
+	if len(scores) == 0 {
+		return attrs
+	}
+
+	top, lowest := scores[0], scores[0]
+	for _, score := range scores[1:] {
+		if score > top {
+			top = score
+		}
+		if score < lowest {
+			lowest = score
+		}
+	}
+
+	return append(attrs,
+		attribute.Float64(VectorResultsTopScoreKey, top),
+		attribute.Float64(VectorResultsMinScoreKey, lowest),
+	)
+}
+
+// CreateRAGContextAttributes describes the retrieved context injected into
+// the subsequent prompt. A negative token count is omitted
+func CreateRAGContextAttributes(chars, tokens int) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.Int(RAGContextCharsKey, chars)}
+	if tokens >= 0 {
+		attrs = append(attrs, attribute.Int(RAGContextTokensKey, tokens))
+	}
+	return attrs
+}
+
+// CreateCostAttributes creates the span attributes of a cost, including its
+// native provider amount when set
+func CreateCostAttributes(cost Cost) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.Float64(LLMCostPromptKey, cost.Prompt),
+		attribute.Float64(LLMCostCompletionKey, cost.Completion),
+		attribute.Float64(LLMCostTotalKey, cost.Total),
+	}
+	if cost.Reasoning > 0 {
+		attrs = append(attrs, attribute.Float64(LLMCostReasoningKey, cost.Reasoning))
+	}
+	if cost.NativeUnit != "" {
+		attrs = append(attrs,
+			attribute.Float64(LLMCostNativeAmountKey, cost.NativeAmount),
+			attribute.String(LLMCostNativeUnitKey, cost.NativeUnit),
+		)
+	}
+	return attrs
+}
+
 // CreateFrameworkAttributes creates framework-specific attributes
 func CreateFrameworkAttributes(name, operation string) []attribute.KeyValue {
 	return []attribute.KeyValue{
@@ -123,8 +315,8 @@ func SanitizeAttributes(attrs map[string]interface{}) map[string]interface{} {
 	sanitized := make(map[string]interface{})
 
 	for key, value := range attrs {
-		// Skip sensitive keys
-		if isSensitiveKey(key) {
+		// Mask sensitive keys and secret-looking values
+		if isSensitiveKey(key) || isSensitiveValue(value) {
 			sanitized[key] = "[REDACTED]"
 		} else {
 			sanitized[key] = value
@@ -158,6 +350,30 @@ func isSensitiveKey(key string) bool {
 	return false
 }
 
+// sensitiveValuePatterns match values that look like credentials
+var sensitiveValuePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}`),                             // OpenAI-style API keys
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),                                // AWS access key IDs
+	regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{16,}`),               // Bearer tokens
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`), // JWTs
+}
+
+// isSensitiveValue checks if a value looks like a credential
+func isSensitiveValue(value interface{}) bool {
+	str, ok := value.(string)
+	if !ok {
+		return false
+	}
+
+	for _, pattern := range sensitiveValuePatterns {
+		if pattern.MatchString(str) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // MergeAttributes merges multiple attribute maps
 func MergeAttributes(attrs ...map[string]interface{}) map[string]interface{} {
 	merged := make(map[string]interface{})
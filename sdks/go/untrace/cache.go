@@ -0,0 +1,114 @@
+package untrace
+
+import (
+	"context"
+	"sync"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// maxCacheSessions bounds how many sessions cache stats are kept for; spans
+// of further sessions are not aggregated
+const maxCacheSessions = 10000
+
+// CacheStats aggregates prompt cache usage over the LLM spans of a session
+type CacheStats struct {
+	Spans               int64
+	PromptTokens        int64
+	CacheReadTokens     int64
+	CacheCreationTokens int64
+}
+
+// HitRate returns the share of prompt tokens served from the cache
+func (s CacheStats) HitRate() float64 {
+	if s.PromptTokens == 0 {
+		return 0
+	}
+	return float64(s.CacheReadTokens) / float64(s.PromptTokens)
+}
+
+// cacheStatsProcessor is a span processor that aggregates the prompt cache
+// attributes of ended spans by conversation or workflow session ID
+type cacheStatsProcessor struct {
+	mu       sync.Mutex
+	sessions map[string]*CacheStats
+}
+
+// newCacheStatsProcessor creates an empty cache stats processor
+func newCacheStatsProcessor() *cacheStatsProcessor {
+	return &cacheStatsProcessor{
+		sessions: make(map[string]*CacheStats),
+	}
+}
+
+// OnStart does nothing
+func (p *cacheStatsProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+// OnEnd adds the span's cache usage to its session
+func (p *cacheStatsProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	var session string
+	var prompt, read, creation int64
+	cached := false
+
+	for _, attr := range s.Attributes() {
+		switch string(attr.Key) {
+		case ConversationIDKey:
+			session = attr.Value.AsString()
+		case WorkflowSessionIDKey:
+			if session == "" {
+				session = attr.Value.AsString()
+			}
+		case LLMPromptTokensKey:
+			prompt = attr.Value.AsInt64()
+		case LLMCacheReadTokensKey:
+			read = attr.Value.AsInt64()
+			cached = true
+		case LLMCacheCreationTokensKey:
+			creation = attr.Value.AsInt64()
+			cached = true
+		}
+	}
+
+	if session == "" || !cached {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats, ok := p.sessions[session]
+	if !ok {
+		if len(p.sessions) >= maxCacheSessions {
+			return
+		}
+		stats = &CacheStats{}
+		p.sessions[session] = stats
+	}
+
+	stats.Spans++
+	stats.PromptTokens += prompt
+	stats.CacheReadTokens += read
+	stats.CacheCreationTokens += creation
+}
+
+// Shutdown does nothing
+func (p *cacheStatsProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ForceFlush does nothing
+func (p *cacheStatsProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// Snapshot returns a copy of the per-session cache stats
+func (p *cacheStatsProcessor) Snapshot() map[string]CacheStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snapshot := make(map[string]CacheStats, len(p.sessions))
+	for session, stats := range p.sessions {
+		snapshot[session] = *stats
+	}
+	return snapshot
+}
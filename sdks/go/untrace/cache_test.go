@@ -0,0 +1,96 @@
+package untrace
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestCacheStatsBySession(t *testing.T) {
+	// call describes one LLM span: its session and token attributes
+	type call struct {
+		conversation string
+		session      string
+		prompt       int
+		read         int
+		creation     int
+		uncached     bool
+	}
+
+	tests := []struct {
+		name        string
+		calls       []call
+		want        map[string]CacheStats
+		wantHitRate map[string]float64
+	}{
+		{
+			name: "one conversation",
+			calls: []call{
+				{conversation: "c1", prompt: 1000, creation: 800},
+				{conversation: "c1", prompt: 1200, read: 800},
+			},
+			want:        map[string]CacheStats{"c1": {Spans: 2, PromptTokens: 2200, CacheReadTokens: 800, CacheCreationTokens: 800}},
+			wantHitRate: map[string]float64{"c1": 800.0 / 2200},
+		},
+		{
+			name: "conversation ID preferred over workflow session",
+			calls: []call{
+				{conversation: "c1", session: "s1", prompt: 500, read: 250},
+				{session: "s1", prompt: 500, read: 500},
+			},
+			want: map[string]CacheStats{
+				"c1": {Spans: 1, PromptTokens: 500, CacheReadTokens: 250},
+				"s1": {Spans: 1, PromptTokens: 500, CacheReadTokens: 500},
+			},
+		},
+		{
+			name: "spans without cache usage or session ignored",
+			calls: []call{
+				{conversation: "c1", prompt: 300, uncached: true},
+				{prompt: 300, read: 100},
+			},
+			want: map[string]CacheStats{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTestClient(t, nil)
+
+			for _, c := range tt.calls {
+				ctx := context.Background()
+				if c.conversation != "" {
+					ctx = WithConversationID(ctx, c.conversation)
+				}
+				_, span := client.Tracer().StartLLMSpan(ctx, "llm.chat", LLMSpanOptions{Provider: "anthropic", Model: "claude-3"})
+				span.SetAttributes(attribute.Int(LLMPromptTokensKey, c.prompt))
+				if c.session != "" {
+					span.SetAttributes(attribute.String(WorkflowSessionIDKey, c.session))
+				}
+				if !c.uncached {
+					span.SetAttributes(
+						attribute.Int(LLMCacheReadTokensKey, c.read),
+						attribute.Int(LLMCacheCreationTokensKey, c.creation),
+					)
+				}
+				span.End()
+			}
+
+			got := client.MetricsSnapshot().CacheBySession
+			if len(got) != len(tt.want) {
+				t.Fatalf("CacheBySession = %v, want %v", got, tt.want)
+			}
+			for session, want := range tt.want {
+				if got[session] != want {
+					t.Errorf("session %s: stats = %+v, want %+v", session, got[session], want)
+				}
+			}
+			for session, want := range tt.wantHitRate {
+				if rate := got[session].HitRate(); rate != want {
+					t.Errorf("session %s: HitRate() = %v, want %v", session, rate, want)
+				}
+			}
+		})
+	}
+}
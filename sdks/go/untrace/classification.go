@@ -0,0 +1,37 @@
+package untrace
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Classification targets
+const (
+	ClassificationTargetInput  = "input"
+	ClassificationTargetOutput = "output"
+)
+
+// RecordClassification records classifier outputs (e.g. PII present,
+// jailbreak attempt) for an LLM input or output. Each label's score is set as
+// a classification.<target>.<label> attribute on the span in ctx and recorded
+// on the classification score histogram.
+func RecordClassification(ctx context.Context, target string, labels map[string]float64) error {
+	if target != ClassificationTargetInput && target != ClassificationTargetOutput {
+		return NewValidationError(fmt.Sprintf("classification target must be %q or %q", ClassificationTargetInput, ClassificationTargetOutput), "target")
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(labels))
+	for label, score := range labels {
+		attrs = append(attrs, attribute.Float64(fmt.Sprintf("classification.%s.%s", target, label), score))
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attrs...)
+
+	if client := GetInstance(); client != nil {
+		client.Metrics().WithContext(ctx).RecordClassification(target, labels)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package untrace
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRecordClassification(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		labels  map[string]float64
+		wantErr bool
+	}{
+		{name: "input", target: ClassificationTargetInput, labels: map[string]float64{"jailbreak": 0.92, "pii": 0.1}},
+		{name: "output", target: ClassificationTargetOutput, labels: map[string]float64{"toxicity": 0.05}},
+		{name: "unknown target", target: "prompt", labels: map[string]float64{"pii": 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			ctx, span := client.Tracer().StartSpan(context.Background(), "guardrail", SpanOptions{})
+			err := RecordClassification(ctx, tt.target, tt.labels)
+			span.End()
+
+			var validationErr *ValidationError
+			if tt.wantErr != errors.As(err, &validationErr) {
+				t.Fatalf("RecordClassification() error = %v, want validation error: %v", err, tt.wantErr)
+			}
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "guardrail")
+			m, recorded := findMetric(t, client.snapshotReader, "llm.classification.score")
+			if tt.wantErr {
+				if len(got.Attributes) != 0 || recorded {
+					t.Errorf("recorded classification for an invalid target")
+				}
+				return
+			}
+
+			for label, score := range tt.labels {
+				key := "classification." + tt.target + "." + label
+				if value, _ := attrValue(got.Attributes, key); value.AsFloat64() != score {
+					t.Errorf("%s = %v, want %v", key, value.AsFloat64(), score)
+				}
+			}
+
+			if !recorded {
+				t.Fatal("classification scores not recorded")
+			}
+			hist, _ := m.Data.(metricdata.Histogram[float64])
+			if len(hist.DataPoints) != len(tt.labels) {
+				t.Fatalf("got %d data points, want %d", len(hist.DataPoints), len(tt.labels))
+			}
+			for _, dp := range hist.DataPoints {
+				target, _ := dp.Attributes.Value("target")
+				label, _ := dp.Attributes.Value("label")
+				if target.AsString() != tt.target || dp.Count != 1 || dp.Sum != tt.labels[label.AsString()] {
+					t.Errorf("data point %v: sum %v, count %d", dp.Attributes, dp.Sum, dp.Count)
+				}
+			}
+		})
+	}
+}
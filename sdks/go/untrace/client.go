@@ -2,41 +2,141 @@ package untrace
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"reflect"
+	"strings"
 	"sync"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // untraceClient implements the Client interface
 type untraceClient struct {
-	config     Config
-	tracer     Tracer
-	metrics    Metrics
-	context    Context
-	provider   *sdktrace.TracerProvider
-	meter      metric.Meter
-	mu         sync.RWMutex
-	shutdown   bool
+	config         Config
+	tracer         Tracer
+	metrics        Metrics
+	context        Context
+	provider       *sdktrace.TracerProvider
+	exporter       *countingExporter
+	handled        *countingExporter
+	ended          *endedSpanCounter
+	cacheStats     *cacheStatsProcessor
+	meter          metric.Meter
+	meterProvider  *sdkmetric.MeterProvider
+	snapshotReader *sdkmetric.ManualReader
+	mu             sync.RWMutex
+	shutdown       bool
 }
 
 // Global state management
 var (
 	globalClient *untraceClient
 	globalMu     sync.RWMutex
+
+	// defaultGlobalProvider is the OTel global delegate in place before any
+	// provider is registered; ownGlobalProvider is the last one we registered
+	defaultGlobalProvider = otel.GetTracerProvider()
+	ownGlobalProvider     trace.TracerProvider
 )
 
+// Option customizes the configuration passed to InitWithOptions
+type Option func(*Config)
+
+// WithExporter replaces the built-in OTLP exporter with the given exporter,
+// e.g. one fanning out to several backends
+func WithExporter(exporter sdktrace.SpanExporter) Option {
+	return func(c *Config) {
+		c.SpanExporter = exporter
+	}
+}
+
+// WithSpanProcessor registers an additional span processor after the
+// built-in ones. Unless WithExporter is also given, span processors replace
+// the default OTLP export, so spans aren't exported twice
+func WithSpanProcessor(processor sdktrace.SpanProcessor) Option {
+	return func(c *Config) {
+		c.SpanProcessors = append(c.SpanProcessors, processor)
+	}
+}
+
+// WithSpanFilter drops every span for which filter returns false before it
+// is queued for export
+func WithSpanFilter(filter func(sdktrace.ReadOnlySpan) bool) Option {
+	return func(c *Config) {
+		c.SpanFilter = filter
+	}
+}
+
+// WithSamplingRate sets the sampling rate. Unlike Config.SamplingRate in a
+// struct literal, a rate of 0 set this way is kept rather than defaulted
+func WithSamplingRate(rate float64) Option {
+	return func(c *Config) {
+		c.SamplingRate = rate
+		c.samplingRateSet = true
+	}
+}
+
+// WithSpanAttributes sets the given attributes on every span as it starts,
+// merged with any set before
+func WithSpanAttributes(attrs map[string]interface{}) Option {
+	return func(c *Config) {
+		merged := make(map[string]interface{}, len(c.SpanAttributes)+len(attrs))
+		for key, value := range c.SpanAttributes {
+			merged[key] = value
+		}
+		for key, value := range attrs {
+			merged[key] = value
+		}
+		c.SpanAttributes = merged
+	}
+}
+
+// WithAttributeProcessor rewrites the attributes of every span before it is
+// queued for export, e.g. to strip large values
+func WithAttributeProcessor(processor func([]attribute.KeyValue) []attribute.KeyValue) Option {
+	return func(c *Config) {
+		c.AttributeProcessor = processor
+	}
+}
+
 // Init initializes the Untrace SDK with the given configuration
 func Init(config Config) (Client, error) {
+	return InitWithOptions(config)
+}
+
+// InitWithOptions initializes the Untrace SDK with the given configuration
+// customized by the options
+func InitWithOptions(config Config, opts ...Option) (Client, error) {
+	// Copy the processors so options don't append to the caller's slice
+	config.SpanProcessors = append([]sdktrace.SpanProcessor(nil), config.SpanProcessors...)
+	for _, opt := range opts {
+		opt(&config)
+	}
+
 	globalMu.Lock()
 	defer globalMu.Unlock()
 
+	// Apply defaults for fields left unset, then the profile of the
+	// configured environment
+	config = config.withDefaults().WithProfile()
+
 	if globalClient != nil {
+		if fields := conflictingFields(globalClient.config, config); len(fields) > 0 {
+			return nil, NewConfigurationError(
+				fmt.Sprintf("SDK initialized with a different %s; call Shutdown before reinitializing",
+					strings.Join(fields, ", ")),
+				ErrAlreadyInitialized)
+		}
 		if config.Debug {
 			log.Println("[Untrace] SDK already initialized. Returning existing instance.")
 		}
@@ -47,51 +147,176 @@ func Init(config Config) (Client, error) {
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
+	for _, warning := range config.Warnings() {
+		log.Printf("[Untrace] Warning: %s", warning)
+	}
 
 	// Create resource
 	res := CreateResource(config)
 
-	// Create OTLP exporter
-	otlpClient, err := CreateOTLPExporter(config)
+	// Create span exporter
+	exporter, err := createSpanExporter(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create exporter: %w", err)
+		return nil, err
 	}
 
-	// Create OTLP exporter
-	exporter, err := otlptrace.New(context.Background(), otlpClient)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	// Count exported spans so flushes can report them, and report the
+	// traces affected by failed exports
+	reporter := newErrorReportingExporter(exporter, config)
+	counter := newCountingExporter(reporter)
+
+	// Truncate oversized spans instead of failing their export
+	var spanExporter sdktrace.SpanExporter = counter
+	if config.MaxSpanBytes > 0 {
+		spanExporter = newSpanLimitExporter(spanExporter, config.MaxSpanBytes)
 	}
 
-	// Create batch span processor
-	bsp := sdktrace.NewBatchSpanProcessor(exporter,
-		sdktrace.WithBatchTimeout(config.ExportInterval),
-		sdktrace.WithMaxExportBatchSize(config.MaxBatchSize),
-	)
+	// Scrub sensitive values, ahead of truncation so no partial match survives
+	if config.RedactValues {
+		redactor, err := NewRedactor(config.redactionPatterns()...)
+		if err != nil {
+			return nil, err
+		}
+		spanExporter = newRedactionExporter(spanExporter, redactor)
+	}
 
-	// Create tracer provider
-	provider := sdktrace.NewTracerProvider(
-		sdktrace.WithResource(res),
-		sdktrace.WithSpanProcessor(bsp),
-	)
+	// Collapse repeated sibling spans from tight loops
+	if config.CompressRepeatedSpans {
+		spanExporter = newCompressionExporter(spanExporter)
+	}
 
-	// Register global tracer provider
-	otel.SetTracerProvider(provider)
+	// Keep errored, high-cost and forced spans; sample the rest
+	spanExporter = newRetentionExporter(spanExporter, config)
+
+	// Count spans taken off the batch queue so shutdown can report what it
+	// abandoned
+	handled := newCountingExporter(spanExporter)
+
+	// Create batch span processor, unless span processors supplied without
+	// an exporter replace the default export pipeline
+	var bsp sdktrace.SpanProcessor
+	if !replacesExport(config) {
+		bsp = sdktrace.NewBatchSpanProcessor(handled,
+			sdktrace.WithBatchTimeout(config.ExportInterval),
+			sdktrace.WithMaxExportBatchSize(config.MaxBatchSize),
+		)
+	}
+
+	// Create meter provider with an in-memory reader for metric snapshots,
+	// periodically exporting metrics alongside spans
+	snapshotReader := sdkmetric.NewManualReader()
+	meterOpts := []sdkmetric.Option{
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(snapshotReader),
+		sdkmetric.WithView(latencyView(config.LatencyBuckets)),
+	}
+	metricExporter, err := createMetricExporter(config)
+	if err != nil {
+		return nil, err
+	}
+	if metricExporter != nil {
+		meterOpts = append(meterOpts, sdkmetric.WithReader(
+			sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(config.ExportInterval)),
+		))
+	}
+	meterProvider := sdkmetric.NewMeterProvider(meterOpts...)
 
 	// Create meter
-	meter := otel.Meter("untrace")
+	meter := meterProvider.Meter("untrace")
+	metrics, err := newMetrics(meter, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Report exporter liveness as a gauge, before any global provider is
+	// installed so a failure leaves the globals untouched
+	if config.ExporterUpGauge {
+		if err := registerExporterUpGauge(meter, reporter); err != nil {
+			return nil, fmt.Errorf("failed to create exporter up gauge: %w", err)
+		}
+	}
+
+	// Count ended spans entering the batch queue
+	ended := &endedSpanCounter{}
+
+	// Aggregate prompt cache usage per session
+	cacheStats := newCacheStatsProcessor()
+
+	// Built-in processors: span mutations first, then export queueing
+	processors := []sdktrace.SpanProcessor{&contextAttributesProcessor{}}
+	if len(config.SpanAttributes) > 0 {
+		processors = append(processors, newStaticAttributesProcessor(config.SpanAttributes))
+	}
+	if config.OnSpanStart != nil {
+		processors = append(processors, &spanStartHookProcessor{hook: config.OnSpanStart, debug: config.Debug})
+	}
+	if config.SpanNameTransform != nil {
+		processors = append(processors, &spanNameProcessor{transform: config.SpanNameTransform})
+	}
+	if config.DetectColdStart {
+		processors = append(processors, &coldStartProcessor{metrics: metrics})
+	}
+	processors = append(processors, cacheStats)
+
+	if bsp != nil {
+		// Apply the user's span filter and attribute processor before
+		// queueing, counting only the spans it keeps as ended
+		var queue = bsp
+		if config.SpanFilter != nil || config.AttributeProcessor != nil {
+			filter := newFilterProcessor(bsp, config.SpanFilter, config.AttributeProcessor)
+			filter.ended = ended
+			queue = filter
+		} else {
+			processors = append(processors, ended)
+		}
+
+		// Record the remaining deadline budget on spans as they are queued
+		processors = append(processors, newDeadlineProcessor(queue))
+	}
+
+	// User processors run after the built-in ones
+	processors = append(processors, config.SpanProcessors...)
+
+	// Create tracer provider
+	providerOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(newHeadSampler(config)),
+	}
+	if config.IDGenerator != nil {
+		providerOpts = append(providerOpts, sdktrace.WithIDGenerator(config.IDGenerator))
+	}
+	for _, processor := range processors {
+		providerOpts = append(providerOpts, sdktrace.WithSpanProcessor(processor))
+	}
+	provider := sdktrace.NewTracerProvider(providerOpts...)
+
+	// Register global tracer provider unless another library already did
+	if config.ForceGlobal || !foreignGlobalTracerProvider() {
+		otel.SetTracerProvider(provider)
+		ownGlobalProvider = provider
+	} else {
+		log.Println("[Untrace] Warning: a global TracerProvider is already set; leaving it in place. Set ForceGlobal to override it.")
+	}
+	otel.SetMeterProvider(meterProvider)
+	installPropagator()
 
 	// Create client
 	client := &untraceClient{
-		config:   config,
-		provider: provider,
-		meter:    meter,
+		config:         config,
+		provider:       provider,
+		exporter:       counter,
+		handled:        handled,
+		ended:          ended,
+		cacheStats:     cacheStats,
+		meter:          meter,
+		meterProvider:  meterProvider,
+		snapshotReader: snapshotReader,
 	}
 
 	// Initialize components
-	client.tracer = NewTracer(provider.Tracer("untrace"))
-	client.metrics = NewMetrics(meter)
-	client.context = NewContext()
+	client.tracer = newTracer(provider.Tracer("untrace"), config)
+	client.metrics = metrics
+	client.context = newContext(provider.Tracer("untrace"), metrics)
 
 	// Store global instance
 	globalClient = client
@@ -103,10 +328,146 @@ func Init(config Config) (Client, error) {
 	return client, nil
 }
 
+// conflictingFields returns the names of the fields in which a new
+// configuration differs materially from the active one, i.e. where the
+// existing client would send telemetry somewhere or as something else
+func conflictingFields(active, config Config) []string {
+	var fields []string
+	if active.APIKey != config.APIKey {
+		fields = append(fields, "APIKey")
+	}
+	// The active base URL was normalized by Validate
+	if active.BaseURL != otlpURL(config.BaseURL, "") {
+		fields = append(fields, "BaseURL")
+	}
+	if active.ServiceName != config.ServiceName {
+		fields = append(fields, "ServiceName")
+	}
+	if active.Environment != config.Environment {
+		fields = append(fields, "Environment")
+	}
+	if active.Version != config.Version {
+		fields = append(fields, "Version")
+	}
+	if active.SamplingRate != config.SamplingRate || active.SamplerMode != config.SamplerMode {
+		fields = append(fields, "sampling")
+	}
+	if !maps.Equal(active.Headers, config.Headers) {
+		fields = append(fields, "Headers")
+	}
+	if !sameExporter(active.SpanExporter, config.SpanExporter) {
+		fields = append(fields, "SpanExporter")
+	}
+	return fields
+}
+
+// sameExporter reports whether two configured exporters are the same value,
+// treating exporters that can't be compared as different
+func sameExporter(a, b sdktrace.SpanExporter) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// foreignGlobalTracerProvider reports whether another library registered the
+// global tracer provider
+func foreignGlobalTracerProvider() bool {
+	current := otel.GetTracerProvider()
+	if !reflect.TypeOf(current).Comparable() {
+		return true
+	}
+	return current != defaultGlobalProvider && current != ownGlobalProvider
+}
+
+// createSpanExporter creates the exporter that sends spans to Untrace, a
+// dry-run exporter that never sends them, or one discarding them when span
+// processors replace the export pipeline
+func createSpanExporter(config Config) (sdktrace.SpanExporter, error) {
+	if config.SpanExporter != nil {
+		return config.SpanExporter, nil
+	}
+
+	if replacesExport(config) {
+		return discardExporter{}, nil
+	}
+
+	if config.DryRun {
+		return newDryRunExporter(config), nil
+	}
+
+	// JSON formats are sent by the Untrace HTTP exporter
+	if config.ExportFormat == ExportFormatJSON || config.ExportFormat == ExportFormatOTLPJSON {
+		return NewUntraceExporter(config)
+	}
+
+	// Create OTLP exporter
+	otlpClient, err := CreateOTLPExporter(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exporter: %w", err)
+	}
+
+	// Create OTLP exporter
+	exporter, err := otlptrace.New(context.Background(), otlpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	return exporter, nil
+}
+
+// replacesExport reports whether span processors supplied without an
+// exporter take the place of the default export pipeline
+func replacesExport(config Config) bool {
+	return config.SpanExporter == nil && len(config.SpanProcessors) > 0
+}
+
+// discardExporter drops every span, standing in for the exporter when span
+// processors replace the default export pipeline
+type discardExporter struct{}
+
+// ExportSpans drops the spans
+func (discardExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return nil
+}
+
+// Shutdown does nothing
+func (discardExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// createMetricExporter creates the exporter that sends metrics to Untrace.
+// It returns nil, keeping metrics in memory only, in dry-run mode or when
+// custom span exporting is set up without a metric exporter
+func createMetricExporter(config Config) (sdkmetric.Exporter, error) {
+	if config.MetricExporter != nil {
+		return config.MetricExporter, nil
+	}
+
+	if config.DryRun || config.SpanExporter != nil || replacesExport(config) {
+		return nil, nil
+	}
+
+	exporter, err := CreateOTLPMetricExporter(context.Background(), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+	}
+
+	return exporter, nil
+}
+
 // GetInstance returns the current global Untrace instance
 func GetInstance() Client {
 	globalMu.RLock()
 	defer globalMu.RUnlock()
+
+	// Avoid returning a non-nil interface holding a nil client
+	if globalClient == nil {
+		return nil
+	}
 	return globalClient
 }
 
@@ -131,7 +492,13 @@ func (c *untraceClient) Context() Context {
 	return c.context
 }
 
-// Flush flushes all pending spans
+// TracerProvider returns the underlying OpenTelemetry tracer provider so
+// third-party instrumentation can share the Untrace export pipeline
+func (c *untraceClient) TracerProvider() *sdktrace.TracerProvider {
+	return c.provider
+}
+
+// Flush flushes all pending spans and metrics
 func (c *untraceClient) Flush(ctx context.Context) error {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -144,8 +511,15 @@ func (c *untraceClient) Flush(ctx context.Context) error {
 		log.Println("[Untrace] Flushing spans...")
 	}
 
+	var errs []error
 	if err := c.provider.ForceFlush(ctx); err != nil {
-		return fmt.Errorf("failed to flush spans: %w", err)
+		errs = append(errs, fmt.Errorf("failed to flush spans: %w", err))
+	}
+	if err := c.meterProvider.ForceFlush(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to flush metrics: %w", err))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
 	}
 
 	if c.config.Debug {
@@ -155,6 +529,17 @@ func (c *untraceClient) Flush(ctx context.Context) error {
 	return nil
 }
 
+// FlushWithCount flushes all pending spans and returns how many were exported
+func (c *untraceClient) FlushWithCount(ctx context.Context) (int, error) {
+	before := c.exporter.Exported()
+
+	if err := c.Flush(ctx); err != nil {
+		return 0, err
+	}
+
+	return int(c.exporter.Exported() - before), nil
+}
+
 // Shutdown shuts down the client
 func (c *untraceClient) Shutdown(ctx context.Context) error {
 	c.mu.Lock()
@@ -168,27 +553,49 @@ func (c *untraceClient) Shutdown(ctx context.Context) error {
 		log.Println("[Untrace] Shutting down SDK...")
 	}
 
-	// Flush before shutdown
+	// End workflows that were never ended so their spans are exported
+	if manager, ok := c.context.(*untraceContext); ok {
+		manager.abandonWorkflows()
+	}
+
+	// Drain the batch queue before shutdown, best-effort within the deadline
+	exportedBefore := c.exporter.Exported()
 	if err := c.provider.ForceFlush(ctx); err != nil {
 		if c.config.Debug {
 			log.Printf("[Untrace] Warning: failed to flush during shutdown: %v", err)
 		}
 	}
 
-	// Shutdown provider
+	// Shutdown both providers, the meter provider exporting the final metrics
+	var errs []error
 	if err := c.provider.Shutdown(ctx); err != nil {
-		return fmt.Errorf("failed to shutdown provider: %w", err)
+		errs = append(errs, fmt.Errorf("failed to shutdown provider: %w", err))
+	}
+	if err := c.meterProvider.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to shutdown meter provider: %w", err))
 	}
 
+	// The providers can't be restarted even if their shutdown failed, so
+	// release the global instance either way to let Init start over
 	c.shutdown = true
 
-	// Clear global instance
 	globalMu.Lock()
 	if globalClient == c {
 		globalClient = nil
 	}
 	globalMu.Unlock()
 
+	// Report the drain even when the deadline cut it short
+	if c.config.Debug {
+		drained := c.exporter.Exported() - exportedBefore
+		abandoned := c.ended.Ended() - c.handled.Exported()
+		log.Printf("[Untrace] Shutdown drained %d spans, abandoned %d", drained, abandoned)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
 	if c.config.Debug {
 		log.Println("[Untrace] SDK shutdown complete")
 	}
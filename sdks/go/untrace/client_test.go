@@ -0,0 +1,511 @@
+package untrace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestFlushWithCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		spans int
+	}{
+		{name: "no spans", spans: 0},
+		{name: "one span", spans: 1},
+		{name: "several spans", spans: 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTestClient(t, nil)
+
+			ctx := context.Background()
+			for i := 0; i < tt.spans; i++ {
+				_, span := client.Tracer().StartSpan(ctx, "work", SpanOptions{})
+				span.End()
+			}
+
+			count, err := client.FlushWithCount(ctx)
+			if err != nil {
+				t.Fatalf("FlushWithCount() error = %v", err)
+			}
+			if count != tt.spans {
+				t.Errorf("FlushWithCount() = %d, want %d", count, tt.spans)
+			}
+
+			// Spans already exported are not counted again
+			if count, _ := client.FlushWithCount(ctx); count != 0 {
+				t.Errorf("second FlushWithCount() = %d, want 0", count)
+			}
+		})
+	}
+}
+
+// slowExporter exports to memory after a fixed delay per batch
+type slowExporter struct {
+	*tracetest.InMemoryExporter
+	delay time.Duration
+}
+
+// ExportSpans waits out the delay, then records the spans
+func (e *slowExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	time.Sleep(e.delay)
+	return e.InMemoryExporter.ExportSpans(ctx, spans)
+}
+
+func TestShutdownDrainReport(t *testing.T) {
+	const spans = 10
+
+	tests := []struct {
+		name          string
+		deadline      time.Duration
+		wantAbandoned bool
+	}{
+		{name: "deadline long enough", deadline: 5 * time.Second},
+		{name: "deadline too short", deadline: 60 * time.Millisecond, wantAbandoned: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			client, _ := newTestClient(t, func(c *Config) {
+				c.Debug = true
+				c.MaxBatchSize = 1
+				c.SpanExporter = &slowExporter{InMemoryExporter: tracetest.NewInMemoryExporter(), delay: 25 * time.Millisecond}
+			})
+
+			ctx := context.Background()
+			for i := 0; i < spans; i++ {
+				_, span := client.Tracer().StartSpan(ctx, "queued", SpanOptions{})
+				span.End()
+			}
+
+			shutdownCtx, cancel := context.WithTimeout(ctx, tt.deadline)
+			defer cancel()
+			_ = client.Shutdown(shutdownCtx)
+
+			var drained, abandoned int
+			report := logs.String()
+			i := strings.Index(report, "Shutdown drained")
+			if i < 0 {
+				t.Fatalf("no drain report logged:\n%s", report)
+			}
+			if _, err := fmt.Sscanf(report[i:], "Shutdown drained %d spans, abandoned %d", &drained, &abandoned); err != nil {
+				t.Fatalf("unexpected drain report %q: %v", report[i:], err)
+			}
+
+			if tt.wantAbandoned {
+				if drained >= spans || abandoned == 0 {
+					t.Errorf("drained %d, abandoned %d; want a partial drain", drained, abandoned)
+				}
+				return
+			}
+			if drained != spans || abandoned != 0 {
+				t.Errorf("drained %d, abandoned %d; want %d drained", drained, abandoned, spans)
+			}
+		})
+	}
+}
+
+func TestTracerProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		tracerName string
+		spanName   string
+	}{
+		{name: "third-party instrumentation", tracerName: "otelhttp", spanName: "GET /chat"},
+		{name: "application tracer", tracerName: "app", spanName: "handle"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			provider := client.TracerProvider()
+			if provider == nil {
+				t.Fatal("TracerProvider() = nil")
+			}
+
+			_, span := provider.Tracer(tt.tracerName).Start(context.Background(), tt.spanName)
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), tt.spanName)
+			if got.InstrumentationLibrary.Name != tt.tracerName {
+				t.Errorf("instrumentation scope = %q, want %q", got.InstrumentationLibrary.Name, tt.tracerName)
+			}
+			if name, _ := got.Resource.Set().Value("service.name"); name.AsString() != DefaultServiceName {
+				t.Errorf("service.name = %q, want %q", name.AsString(), DefaultServiceName)
+			}
+		})
+	}
+}
+
+func TestForeignGlobalTracerProvider(t *testing.T) {
+	tests := []struct {
+		name        string
+		foreign     bool
+		forceGlobal bool
+		wantOwn     bool
+	}{
+		{name: "no foreign provider", wantOwn: true},
+		{name: "foreign provider kept", foreign: true},
+		{name: "foreign provider overridden", foreign: true, forceGlobal: true, wantOwn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Restore the global provider and which one the SDK owns, so
+			// later tests don't see a foreign provider
+			previous, previousOwn := otel.GetTracerProvider(), ownGlobalProvider
+			t.Cleanup(func() {
+				otel.SetTracerProvider(previous)
+				globalMu.Lock()
+				ownGlobalProvider = previousOwn
+				globalMu.Unlock()
+			})
+			previousPropagator := otel.GetTextMapPropagator()
+			t.Cleanup(func() { otel.SetTextMapPropagator(previousPropagator) })
+
+			foreign := sdktrace.NewTracerProvider()
+			defer foreign.Shutdown(context.Background())
+			if tt.foreign {
+				otel.SetTracerProvider(foreign)
+			}
+			otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+			client, _ := newTestClient(t, func(c *Config) {
+				c.ForceGlobal = tt.forceGlobal
+			})
+
+			global := otel.GetTracerProvider()
+			if own := global == client.provider; own != tt.wantOwn {
+				t.Errorf("global tracer provider is the SDK's = %v, want %v", own, tt.wantOwn)
+			}
+			if !tt.wantOwn && global != foreign {
+				t.Errorf("foreign tracer provider was replaced")
+			}
+
+			// The meter provider and propagator are registered either way
+			if otel.GetMeterProvider() != client.meterProvider {
+				t.Errorf("global meter provider is not the SDK's")
+			}
+			if fields := otel.GetTextMapPropagator().Fields(); len(fields) == 0 {
+				t.Errorf("propagator not installed")
+			}
+		})
+	}
+}
+
+func TestInitWithOptions(t *testing.T) {
+	tests := []struct {
+		name            string
+		withExporter    bool
+		withProcessor   bool
+		wantDefault     bool
+		wantCustom      bool
+		wantProcessor   bool
+		wantPassthrough bool
+	}{
+		{name: "config exporter only", wantDefault: true},
+		{name: "custom exporter", withExporter: true, wantCustom: true},
+		{name: "processor alongside the exporter", withProcessor: true, wantDefault: true, wantProcessor: true},
+		{name: "processor replaces the export pipeline", withProcessor: true, wantProcessor: true, wantPassthrough: true},
+		{name: "custom exporter and processor", withExporter: true, withProcessor: true, wantCustom: true, wantProcessor: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			custom := tracetest.NewInMemoryExporter()
+			processed := tracetest.NewInMemoryExporter()
+			baseProcessors := make([]sdktrace.SpanProcessor, 0, 1)
+
+			var opts []Option
+			if tt.withExporter {
+				opts = append(opts, WithExporter(custom))
+			}
+			if tt.withProcessor {
+				opts = append(opts, WithSpanProcessor(sdktrace.NewSimpleSpanProcessor(processed)))
+			}
+
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.SpanProcessors = baseProcessors
+				if tt.wantPassthrough {
+					c.SpanExporter = nil
+				}
+			}, opts...)
+
+			_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{})
+			span.End()
+			if err := client.Flush(context.Background()); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			counts := []struct {
+				name string
+				got  int
+				want bool
+			}{
+				{name: "config exporter", got: len(exporter.GetSpans()), want: tt.wantDefault},
+				{name: "custom exporter", got: len(custom.GetSpans()), want: tt.wantCustom},
+				{name: "span processor", got: len(processed.GetSpans()), want: tt.wantProcessor},
+			}
+			for _, c := range counts {
+				want := 0
+				if c.want {
+					want = 1
+				}
+				if c.got != want {
+					t.Errorf("%s received %d spans, want %d", c.name, c.got, want)
+				}
+			}
+
+			// Options must not write into the spare capacity of the caller's slice
+			if tt.withProcessor && baseProcessors[:1][0] != nil {
+				t.Errorf("WithSpanProcessor appended to the caller's SpanProcessors")
+			}
+		})
+	}
+}
+
+func TestFlushExportsMetrics(t *testing.T) {
+	tests := []struct {
+		name      string
+		exportErr error
+	}{
+		{name: "metrics exported"},
+		{name: "metric export fails", exportErr: errors.New("collector unavailable")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := &memoryMetricExporter{}
+			client, _ := newTestClient(t, func(c *Config) {
+				c.MetricExporter = exporter
+				// Longer than the test, so only Flush exports
+				c.ExportInterval = time.Hour
+			})
+			exporter.setErr(tt.exportErr)
+			t.Cleanup(func() { exporter.setErr(nil) })
+
+			client.Metrics().RecordCost(Cost{Total: 0.5, Provider: "openai", Model: "gpt-4"})
+			_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{})
+			span.End()
+
+			err := client.Flush(context.Background())
+			if tt.exportErr != nil {
+				if !errors.Is(err, tt.exportErr) || !strings.Contains(err.Error(), "failed to flush metrics") {
+					t.Errorf("Flush() error = %v, want the metric export error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			if !exporter.metricNames()["llm.cost.total"] {
+				t.Errorf("llm.cost.total not exported by Flush; got %v", exporter.metricNames())
+			}
+		})
+	}
+}
+
+func TestInitTwice(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(c *Config)
+		wantErr   bool
+	}{
+		{name: "identical config"},
+		{name: "debug only", configure: func(c *Config) { c.Debug = true }},
+		{name: "different service name", configure: func(c *Config) { c.ServiceName = "billing" }, wantErr: true},
+		{name: "different API key", configure: func(c *Config) { c.APIKey = "other-key" }, wantErr: true},
+		{name: "different sampling rate", configure: func(c *Config) { c.SamplingRate = 0.5 }, wantErr: true},
+		{name: "different exporter", configure: func(c *Config) { c.SpanExporter = tracetest.NewInMemoryExporter() }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			config := DefaultConfig("test-key")
+			config.SpanExporter = exporter
+			if tt.configure != nil {
+				tt.configure(&config)
+			}
+
+			again, err := Init(config)
+			if tt.wantErr {
+				var configErr *ConfigurationError
+				if !errors.As(err, &configErr) || !errors.Is(err, ErrAlreadyInitialized) {
+					t.Errorf("Init() error = %v, want a ConfigurationError wrapping ErrAlreadyInitialized", err)
+				}
+				if again != nil {
+					t.Errorf("Init() = %v, want nil on conflict", again)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+			if again != Client(client) {
+				t.Errorf("Init() returned a new client, want the existing one")
+			}
+		})
+	}
+}
+
+// failingShutdownProcessor is a span processor that fails to shut down
+type failingShutdownProcessor struct {
+	sdktrace.SpanProcessor
+	err error
+}
+
+// Shutdown shuts down the processor, then fails with its error
+func (p *failingShutdownProcessor) Shutdown(ctx context.Context) error {
+	_ = p.SpanProcessor.Shutdown(ctx)
+	return p.err
+}
+
+func TestReinitializeAfterShutdown(t *testing.T) {
+	shutdownErr := errors.New("connection reset")
+
+	tests := []struct {
+		name        string
+		firstErr    error
+		serviceName string
+	}{
+		{name: "same config", serviceName: DefaultServiceName},
+		{name: "different config", serviceName: "billing"},
+		{name: "shutdown fails", firstErr: shutdownErr, serviceName: DefaultServiceName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := DefaultConfig("test-key")
+			first.SpanExporter = tracetest.NewInMemoryExporter()
+			processor := &failingShutdownProcessor{
+				SpanProcessor: sdktrace.NewSimpleSpanProcessor(tracetest.NewInMemoryExporter()),
+				err:           tt.firstErr,
+			}
+			client, err := InitWithOptions(first, WithSpanProcessor(processor))
+			if err != nil {
+				t.Fatalf("InitWithOptions() error = %v", err)
+			}
+			if err := client.Shutdown(context.Background()); !errors.Is(err, tt.firstErr) {
+				t.Fatalf("Shutdown() error = %v, want %v", err, tt.firstErr)
+			}
+			if GetInstance() != nil {
+				t.Fatalf("GetInstance() after Shutdown = %v, want nil", GetInstance())
+			}
+
+			reinitialized, exporter := newTestClient(t, func(c *Config) {
+				c.ServiceName = tt.serviceName
+			})
+			if Client(reinitialized) == client {
+				t.Fatalf("Init() after Shutdown returned the shut down client")
+			}
+
+			_, span := reinitialized.Tracer().StartSpan(context.Background(), "after-restart", SpanOptions{})
+			span.End()
+			got := findSpan(t, exportedSpans(t, reinitialized, exporter), "after-restart")
+			if name, _ := got.Resource.Set().Value("service.name"); name.AsString() != tt.serviceName {
+				t.Errorf("service.name = %q, want %q", name.AsString(), tt.serviceName)
+			}
+		})
+	}
+}
+
+func TestInitStructLiteralConfig(t *testing.T) {
+	tests := []struct {
+		name             string
+		config           Config
+		opts             []Option
+		wantMaxBatchSize int
+		wantInterval     time.Duration
+		wantSamplingRate float64
+	}{
+		{
+			name:             "API key and service name only",
+			config:           Config{APIKey: "test-key", ServiceName: "checkout"},
+			wantMaxBatchSize: DefaultMaxBatchSize,
+			wantInterval:     DefaultExportInterval,
+			wantSamplingRate: DefaultSamplingRate,
+		},
+		{
+			name:             "explicit values kept",
+			config:           Config{APIKey: "test-key", ServiceName: "checkout", MaxBatchSize: 64, ExportInterval: time.Second, SamplingRate: 0.25},
+			wantMaxBatchSize: 64,
+			wantInterval:     time.Second,
+			wantSamplingRate: 0.25,
+		},
+		{
+			name:             "zero sampling rate set with WithSamplingRate",
+			config:           Config{APIKey: "test-key", ServiceName: "checkout"},
+			opts:             []Option{WithSamplingRate(0)},
+			wantMaxBatchSize: DefaultMaxBatchSize,
+			wantInterval:     DefaultExportInterval,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			config := tt.config
+			config.SpanExporter = exporter
+			client, err := InitWithOptions(config, tt.opts...)
+			if err != nil {
+				t.Fatalf("InitWithOptions() error = %v", err)
+			}
+			t.Cleanup(func() {
+				_ = client.Shutdown(context.Background())
+				_ = Reset()
+			})
+
+			active := client.(*untraceClient).config
+			if active.ServiceName != "checkout" {
+				t.Errorf("ServiceName = %q, want checkout", active.ServiceName)
+			}
+			if active.BaseURL != DefaultBaseURL {
+				t.Errorf("BaseURL = %q, want %q", active.BaseURL, DefaultBaseURL)
+			}
+			if active.MaxBatchSize != tt.wantMaxBatchSize {
+				t.Errorf("MaxBatchSize = %d, want %d", active.MaxBatchSize, tt.wantMaxBatchSize)
+			}
+			if active.ExportInterval != tt.wantInterval {
+				t.Errorf("ExportInterval = %v, want %v", active.ExportInterval, tt.wantInterval)
+			}
+			if active.SamplingRate != tt.wantSamplingRate {
+				t.Errorf("SamplingRate = %v, want %v", active.SamplingRate, tt.wantSamplingRate)
+			}
+
+			// Rates in between sample by trace ID, so only the extremes are
+			// checked end to end
+			if tt.wantSamplingRate != 0 && tt.wantSamplingRate != 1 {
+				return
+			}
+			_, span := client.Tracer().StartLLMSpan(context.Background(), "llm.chat", LLMSpanOptions{Provider: "openai", Model: "gpt-4"})
+			span.End()
+			exported, err := client.FlushWithCount(context.Background())
+			if err != nil {
+				t.Fatalf("FlushWithCount() error = %v", err)
+			}
+			if want := int(tt.wantSamplingRate); exported != want {
+				t.Errorf("FlushWithCount() = %d, want %d", exported, want)
+			}
+		})
+	}
+}
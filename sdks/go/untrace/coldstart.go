@@ -0,0 +1,57 @@
+package untrace
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// coldStartClaimed is set once a span has been marked as the process's cold
+// start, so it is counted only once per process
+var coldStartClaimed atomic.Bool
+
+// MarkColdStart tags the current span with faas.cold_start=true and records
+// a cold start. Only the first call per process records the cold start.
+func MarkColdStart(ctx context.Context) {
+	if !coldStartClaimed.CompareAndSwap(false, true) {
+		return
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool(FaaSColdStartKey, true))
+
+	if client := GetInstance(); client != nil {
+		client.Metrics().WithContext(ctx).RecordColdStart()
+	}
+}
+
+// coldStartProcessor is a span processor that marks the first span started
+// after process start as a cold start and every later span as warm
+type coldStartProcessor struct {
+	metrics Metrics
+}
+
+// OnStart tags the span with its cold start state
+func (p *coldStartProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	cold := coldStartClaimed.CompareAndSwap(false, true)
+	s.SetAttributes(attribute.Bool(FaaSColdStartKey, cold))
+
+	if cold {
+		p.metrics.WithContext(parent).RecordColdStart()
+	}
+}
+
+// OnEnd does nothing
+func (p *coldStartProcessor) OnEnd(s sdktrace.ReadOnlySpan) {}
+
+// Shutdown does nothing
+func (p *coldStartProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ForceFlush does nothing
+func (p *coldStartProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
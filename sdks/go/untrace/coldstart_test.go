@@ -0,0 +1,60 @@
+package untrace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestColdStart(t *testing.T) {
+	tests := []struct {
+		name   string
+		detect bool
+		mark   bool
+		// want is the faas.cold_start value expected on each span, "" when unset
+		want []string
+	}{
+		{name: "detected", detect: true, want: []string{"true", "false", "false"}},
+		{name: "marked manually", mark: true, want: []string{"true", "", ""}},
+		{name: "neither", want: []string{"", "", ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.DetectColdStart = tt.detect
+			})
+
+			var names []string
+			for i := range tt.want {
+				name := "invocation." + string(rune('a'+i))
+				names = append(names, name)
+				ctx, span := client.Tracer().StartSpan(context.Background(), name, SpanOptions{})
+				if tt.mark {
+					MarkColdStart(ctx)
+				}
+				span.End()
+			}
+
+			spans := exportedSpans(t, client, exporter)
+			for i, name := range names {
+				value, ok := attrValue(findSpan(t, spans, name).Attributes, FaaSColdStartKey)
+				got := ""
+				if ok {
+					got = value.Emit()
+				}
+				if got != tt.want[i] {
+					t.Errorf("%s: %s = %q, want %q", name, FaaSColdStartKey, got, tt.want[i])
+				}
+			}
+
+			m, recorded := findMetric(t, client.snapshotReader, "faas.cold_starts")
+			wantColdStarts := tt.detect || tt.mark
+			if recorded != wantColdStarts {
+				t.Fatalf("cold start recorded = %v, want %v", recorded, wantColdStarts)
+			}
+			if recorded && sumValue(t, m) != 1 {
+				t.Errorf("faas.cold_starts = %v, want 1", sumValue(t, m))
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package untrace
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// compressionExporter collapses runs of consecutive sibling leaf spans with
+// the same name, e.g. from tight loops, into a single span carrying the run's
+// count and total duration. Only spans exported in the same batch are
+// compressed; errored spans and spans with children are never compressed.
+type compressionExporter struct {
+	sdktrace.SpanExporter
+}
+
+// newCompressionExporter wraps the given exporter with span compression
+func newCompressionExporter(exporter sdktrace.SpanExporter) *compressionExporter {
+	return &compressionExporter{SpanExporter: exporter}
+}
+
+// ExportSpans compresses repeated spans and exports the batch
+func (e *compressionExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return e.SpanExporter.ExportSpans(ctx, compressSpans(spans))
+}
+
+// siblingKey identifies the children of one parent span
+type siblingKey struct {
+	traceID trace.TraceID
+	parent  trace.SpanID
+}
+
+// compressSpans returns the spans with each run of repeated siblings
+// replaced by its first span, extended to cover the whole run
+func compressSpans(spans []sdktrace.ReadOnlySpan) []sdktrace.ReadOnlySpan {
+	hasChildren := make(map[trace.SpanID]bool, len(spans))
+	siblings := make(map[siblingKey][]int)
+	for i, span := range spans {
+		hasChildren[span.Parent().SpanID()] = true
+		key := siblingKey{traceID: span.SpanContext().TraceID(), parent: span.Parent().SpanID()}
+		siblings[key] = append(siblings[key], i)
+	}
+
+	compressible := func(span sdktrace.ReadOnlySpan) bool {
+		return span.Status().Code != codes.Error && !hasChildren[span.SpanContext().SpanID()]
+	}
+
+	replaced := make(map[int]sdktrace.ReadOnlySpan)
+	dropped := make(map[int]bool)
+	for _, group := range siblings {
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(a, b int) bool {
+			return spans[group[a]].StartTime().Before(spans[group[b]].StartTime())
+		})
+
+		for start := 0; start < len(group); {
+			head := spans[group[start]]
+			end := start + 1
+			for compressible(head) && end < len(group) &&
+				compressible(spans[group[end]]) && spans[group[end]].Name() == head.Name() {
+				end++
+			}
+
+			if run := group[start:end]; len(run) > 1 {
+				replaced[run[0]] = mergeSpans(spans, run)
+				for _, i := range run[1:] {
+					dropped[i] = true
+				}
+			}
+			start = end
+		}
+	}
+
+	if len(dropped) == 0 {
+		return spans
+	}
+
+	compressed := make([]sdktrace.ReadOnlySpan, 0, len(spans)-len(dropped))
+	for i, span := range spans {
+		if dropped[i] {
+			continue
+		}
+		if merged, ok := replaced[i]; ok {
+			span = merged
+		}
+		compressed = append(compressed, span)
+	}
+	return compressed
+}
+
+// mergeSpans merges a run of spans into its first span
+func mergeSpans(spans []sdktrace.ReadOnlySpan, run []int) sdktrace.ReadOnlySpan {
+	head := spans[run[0]]
+	end := head.EndTime()
+	var total time.Duration
+	for _, i := range run {
+		total += spans[i].EndTime().Sub(spans[i].StartTime())
+		if spans[i].EndTime().After(end) {
+			end = spans[i].EndTime()
+		}
+	}
+
+	return &compressedSpan{
+		ReadOnlySpan: withAttributes(head,
+			attribute.Int(UntraceSpanCountKey, len(run)),
+			attribute.Int64(UntraceSpanDurationTotalMsKey, total.Milliseconds()),
+		),
+		end: end,
+	}
+}
+
+// compressedSpan is a span extended to end with the last span of its run
+type compressedSpan struct {
+	sdktrace.ReadOnlySpan
+	end time.Time
+}
+
+// EndTime returns the end of the compressed run
+func (s *compressedSpan) EndTime() time.Time {
+	return s.end
+}
@@ -0,0 +1,73 @@
+package untrace
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestCompressRepeatedSpans(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		children []string
+		errorAt  int
+		// wantCounts maps the exported child spans, in order, to their
+		// untrace.span.count (0 when not compressed)
+		wantCounts []int64
+	}{
+		{name: "disabled", children: repeat("embed", 3), errorAt: -1, wantCounts: []int64{0, 0, 0}},
+		{name: "100 identical children", enabled: true, children: repeat("embed", 100), errorAt: -1, wantCounts: []int64{100}},
+		{name: "different names", enabled: true, children: []string{"embed", "store", "embed"}, errorAt: -1, wantCounts: []int64{0, 0, 0}},
+		{name: "errored child breaks the run", enabled: true, children: repeat("embed", 5), errorAt: 2, wantCounts: []int64{2, 0, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.CompressRepeatedSpans = tt.enabled
+			})
+
+			ctx, parent := client.Tracer().StartSpan(context.Background(), "loop", SpanOptions{})
+			for i, name := range tt.children {
+				_, child := client.Tracer().StartSpan(ctx, name, SpanOptions{})
+				if i == tt.errorAt {
+					child.SetStatus(codes.Error, "boom")
+				}
+				child.End()
+			}
+			parent.End()
+
+			var counts []int64
+			for _, span := range exportedSpans(t, client, exporter) {
+				if span.Name == "loop" {
+					if _, ok := attrValue(span.Attributes, UntraceSpanCountKey); ok {
+						t.Errorf("parent span was compressed")
+					}
+					continue
+				}
+				count, _ := attrValue(span.Attributes, UntraceSpanCountKey)
+				counts = append(counts, count.AsInt64())
+			}
+			if len(counts) != len(tt.wantCounts) {
+				t.Fatalf("exported children with counts %v, want %v", counts, tt.wantCounts)
+			}
+			for i := range counts {
+				if counts[i] != tt.wantCounts[i] {
+					t.Errorf("exported children with counts %v, want %v", counts, tt.wantCounts)
+					break
+				}
+			}
+		})
+	}
+}
+
+// repeat returns a slice holding name n times
+func repeat(name string, n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = name
+	}
+	return names
+}
@@ -1,7 +1,17 @@
 package untrace
 
 import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
 	"time"
+	"unicode"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // Config represents the configuration options for initializing the Untrace SDK
@@ -13,45 +23,462 @@ type Config struct {
 	ServiceName        string
 	Environment        string
 	Version            string
+	// BaseURL is the root URL of the Untrace API, e.g. "https://untrace.dev"
+	// or "http://localhost:4318" for a local collector. A bare host such as
+	// "untrace.dev" means https. The OTLP paths (/v1/traces, /v1/metrics)
+	// are appended to it, so any path it has is kept as a prefix.
 	BaseURL            string
 	Debug              bool
-	SamplingRate       float64
 	MaxBatchSize       int
 	ExportInterval     time.Duration
 	Headers            map[string]string
 	ResourceAttributes map[string]interface{}
+
+	// SamplingRate is the share of traces sampled, by trace ID. By default
+	// it is applied when spans start, so unsampled traces are neither
+	// recorded nor propagated as sampled; see SamplerMode. Init treats a rate
+	// of 0 as unset and samples every trace unless the config came from
+	// DefaultConfig or WithSamplingRate set it
+	SamplingRate float64
+
+	// SamplerMode selects where SamplingRate is applied. SamplerTraceIDRatio
+	// (the default) samples at span start, and SamplerParentBased
+	// additionally follows the sampling decision of a parent span, including
+	// a remote one. SamplerRetention records every span and samples at
+	// export, so errored, high-cost and forced spans of unsampled traces can
+	// still be kept. SamplerAlwaysOn keeps every span, ignoring SamplingRate
+	SamplerMode SamplerMode
+
+	// IDGenerator generates trace and span IDs. Set it to
+	// NewSeededIDGenerator in tests for reproducible sampling decisions.
+	// Defaults to the OpenTelemetry random generator
+	IDGenerator sdktrace.IDGenerator
+
+	// CostDimensions lists LLM span attribute keys (e.g. "team.id",
+	// "project.id") that are copied onto cost metrics as labels, from
+	// Cost.Attributes or else the LLM span in the context the metrics are
+	// bound to with WithContext
+	CostDimensions []string
+
+	// RedactValues scrubs substrings matching RedactionPatterns out of span
+	// attribute and event values before export, e.g. PII in captured prompts
+	RedactValues bool
+
+	// RedactionPatterns are the regular expressions redacted when
+	// RedactValues is set. Nil uses DefaultRedactionPatterns
+	RedactionPatterns []string
+
+	// MaxSpanBytes caps the estimated size of a single exported span; larger
+	// spans have their biggest string attributes truncated. Zero disables it
+	MaxSpanBytes int
+
+	// HighCostThreshold keeps every LLM span whose total cost reaches it,
+	// regardless of SamplingRate, in SamplerRetention mode. Zero disables the
+	// high-cost path
+	HighCostThreshold float64
+
+	// RecordSampledReason tags kept spans with untrace.sampled.reason so a
+	// downstream tail sampler can make consistent decisions. Outside
+	// SamplerRetention mode every exported span is kept for rate
+	RecordSampledReason bool
+
+	// LatencyBuckets are the llm.latency histogram bucket boundaries in
+	// seconds, in increasing order. Nil uses DefaultLatencyBuckets
+	LatencyBuckets []float64
+
+	// MetricTemporality selects cumulative or delta aggregation temporality
+	// for exported metrics. Defaults to cumulative
+	MetricTemporality MetricTemporality
+
+	// CaptureContent records LLM input/output messages on spans
+	CaptureContent bool
+
+	// AttributeConvention selects how captured content is laid out on spans.
+	// Defaults to the Untrace flat attributes
+	AttributeConvention AttributeConvention
+
+	// SpanProcessors are registered after the built-in processors, in order.
+	// Without a SpanExporter they replace the built-in export pipeline, so
+	// spans are exported only by them. With one, the batch export processor
+	// is built in and a processor here sees each span's OnEnd after the span
+	// was queued for export; attributes set in OnStart are still exported
+	SpanProcessors []sdktrace.SpanProcessor
+
+	// OnExportError is called when a span export fails, with a sample of the
+	// affected trace IDs for cross-referencing
+	OnExportError func(err *ExportError)
+
+	// OnExport is called after every span export attempt with the number of
+	// spans in the batch and the export error, if any
+	OnExport func(spans int, err error)
+
+	// SpanNameTransform renames every span at start from its original name
+	// and start attributes, e.g. to include the model in LLM span names
+	SpanNameTransform func(original string, attrs map[string]interface{}) string
+
+	// OnSpanStart is called as every span starts with its parent context and
+	// name; the returned attributes are set on the span. It runs on the hot
+	// path, so it should be cheap; slow calls are logged when Debug is set
+	OnSpanStart func(ctx context.Context, name string) []attribute.KeyValue
+
+	// ExporterUpGauge reports an untrace.exporter.up gauge that is 1 while
+	// span exports succeed and 0 while they fail
+	ExporterUpGauge bool
+
+	// BaggageToMetricLabels lists baggage keys (e.g. "tenant.id") copied as
+	// labels onto metrics recorded through Metrics().WithContext(ctx). Every
+	// distinct value creates a new time series, so only list low-cardinality
+	// keys
+	BaggageToMetricLabels []string
+
+	// SpanExporter replaces the built-in exporter, e.g. with an in-memory
+	// exporter in tests. Sampling, truncation and export reporting still
+	// apply. See InitForTesting
+	SpanExporter sdktrace.SpanExporter
+
+	// SpanFilter, if set, is called for every ended span; spans it returns
+	// false for are dropped instead of exported
+	SpanFilter func(sdktrace.ReadOnlySpan) bool
+
+	// AttributeProcessor, if set, rewrites the attributes of every ended span
+	// before export. It may modify the slice it is passed
+	AttributeProcessor func([]attribute.KeyValue) []attribute.KeyValue
+
+	// SpanAttributes are set on every span as it starts, e.g. the region or
+	// git commit. Unlike ResourceAttributes they are attributes of each span.
+	// Attributes a span is started with take precedence over them; ones set
+	// on it later overwrite them
+	SpanAttributes map[string]interface{}
+
+	// MetricExporter replaces the built-in OTLP metric exporter. Metrics are
+	// not exported in dry-run mode or when SpanExporter is set unless this
+	// is set too
+	MetricExporter sdkmetric.Exporter
+
+	// Profiles holds per-environment overrides keyed by environment name.
+	// When Environment matches a profile, every non-zero field of the profile
+	// overrides the base config; zero fields keep the base value, so a
+	// profile can turn a bool option on but not off
+	Profiles map[string]Config
+
+	// DryRun converts and serializes spans as usual but never sends them,
+	// logging what would have been exported when Debug is set
+	DryRun bool
+
+	// ForceGlobal registers the SDK's tracer provider globally even when
+	// another library already registered one. The meter provider and the
+	// propagator are registered either way
+	ForceGlobal bool
+
+	// DisableCostMetrics and DisableTokenMetrics turn off the cost and token
+	// metrics; spans still carry the cost and token attributes
+	DisableCostMetrics  bool
+	DisableTokenMetrics bool
+
+	// ExportFormat selects the span payload encoding. Defaults to OTLP protobuf
+	ExportFormat ExportFormat
+
+	// MetricsFromSampledOnly records token and cost metrics only for spans
+	// that are exported: traces that pass SamplingRate, plus in
+	// SamplerRetention mode the errored, high-cost and forced spans kept
+	// regardless of it, keeping metric and
+	// trace populations consistent. UpsampleMetrics scales the metrics of
+	// rate-sampled spans by 1/SamplingRate to estimate the unsampled totals
+	MetricsFromSampledOnly bool
+	UpsampleMetrics        bool
+
+	// MaxAttributeSliceLen caps the length of slice-typed span attributes;
+	// longer slices are cut and flagged with a <key>_truncated attribute.
+	// Zero disables the cap
+	MaxAttributeSliceLen int
+
+	// CompressRepeatedSpans collapses runs of consecutive sibling leaf spans
+	// with the same name into one span carrying untrace.span.count and
+	// untrace.span.duration_total_ms. Only spans exported in the same batch
+	// are compressed
+	CompressRepeatedSpans bool
+
+	// CompressionThreshold is the payload size in bytes above which JSON
+	// exports are gzip-compressed. Zero uses DefaultCompressionThreshold and
+	// a negative value disables compression
+	CompressionThreshold int
+
+	// ContextUtilizationThreshold is the share of the model's context window
+	// above which LLM spans are tagged llm.context.high_utilization. Zero
+	// uses DefaultContextUtilizationThreshold
+	ContextUtilizationThreshold float64
+
+	// DetectColdStart tags the first span started after process start with
+	// faas.cold_start=true and later spans with false
+	DetectColdStart bool
+
+	// samplingRateSet records that SamplingRate was set deliberately, so Init
+	// keeps a rate of 0 rather than defaulting it
+	samplingRateSet bool
+}
+
+// ExportFormat represents the encoding of exported span payloads
+type ExportFormat string
+
+const (
+	// ExportFormatOTLPProto sends OTLP protobuf over HTTP
+	ExportFormatOTLPProto ExportFormat = "otlp_proto"
+	// ExportFormatJSON sends the flat Untrace JSON payload
+	ExportFormatJSON ExportFormat = "json"
+	// ExportFormatOTLPJSON sends the OTLP/JSON encoding
+	ExportFormatOTLPJSON ExportFormat = "otlp_json"
+)
+
+// AttributeConvention represents the layout used for captured span content
+type AttributeConvention string
+
+const (
+	// AttributeConventionUntrace records content as flat llm.* attributes
+	AttributeConventionUntrace AttributeConvention = "untrace"
+	// AttributeConventionOpenInference records content as OpenInference
+	// input/output span events with mime types
+	AttributeConventionOpenInference AttributeConvention = "openinference"
+)
+
+// SamplerMode represents where and how spans are sampled
+type SamplerMode string
+
+const (
+	// SamplerTraceIDRatio applies SamplingRate by trace ID at span start
+	SamplerTraceIDRatio SamplerMode = "traceid_ratio"
+	// SamplerParentBased follows the parent span's sampling decision and
+	// applies SamplingRate by trace ID to root spans
+	SamplerParentBased SamplerMode = "parent_based"
+	// SamplerAlwaysOn records and exports every span, ignoring SamplingRate
+	SamplerAlwaysOn SamplerMode = "always_on"
+	// SamplerRetention records every span and applies SamplingRate at
+	// export, always keeping errored, high-cost and forced spans. Spans
+	// propagate as sampled, so downstream services may keep traces this
+	// service drops
+	SamplerRetention SamplerMode = "retention"
+)
+
+// MetricTemporality represents the aggregation temporality of exported metrics
+type MetricTemporality string
+
+const (
+	MetricTemporalityCumulative MetricTemporality = "cumulative"
+	MetricTemporalityDelta      MetricTemporality = "delta"
+)
+
+// redactionPatterns returns the patterns to redact with
+func (c *Config) redactionPatterns() []string {
+	if c.RedactionPatterns == nil {
+		return DefaultRedactionPatterns
+	}
+	return c.RedactionPatterns
 }
 
+// DefaultLatencyBuckets are the default Config.LatencyBuckets, in seconds,
+// spanning fast completions to long generations
+var DefaultLatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30, 60}
+
+// DefaultCompressionThreshold is the default Config.CompressionThreshold
+const DefaultCompressionThreshold = 1024
+
 // DefaultConfig returns a config with sensible defaults
 func DefaultConfig(apiKey string) Config {
 	return Config{
 		APIKey:            apiKey,
-		ServiceName:       "untrace-app",
+		ServiceName:       DefaultServiceName,
 		Environment:       "production",
 		Version:           "0.1.0",
-		BaseURL:           "https://untrace.dev",
+		BaseURL:           DefaultBaseURL,
 		Debug:             false,
-		SamplingRate:      1.0,
-		MaxBatchSize:      512,
-		ExportInterval:    5 * time.Second,
+		SamplingRate:      DefaultSamplingRate,
+		MaxBatchSize:      DefaultMaxBatchSize,
+		ExportInterval:    DefaultExportInterval,
 		Headers:           make(map[string]string),
 		ResourceAttributes: make(map[string]interface{}),
+		MetricTemporality:  MetricTemporalityCumulative,
+		AttributeConvention: AttributeConventionUntrace,
+		SamplerMode:         SamplerTraceIDRatio,
+		CompressionThreshold: DefaultCompressionThreshold,
+		samplingRateSet:      true,
 	}
 }
 
+// Defaults applied by DefaultConfig, and by Init to fields left unset
+const (
+	DefaultServiceName    = "untrace-app"
+	DefaultBaseURL        = "https://untrace.dev"
+	DefaultMaxBatchSize   = 512
+	DefaultExportInterval = 5 * time.Second
+	DefaultSamplingRate   = 1.0
+)
+
+// withDefaults fills in the fields a config built as a struct literal may
+// leave unset. A SamplingRate of 0 is only kept when it was set deliberately
+func (c Config) withDefaults() Config {
+	if c.SamplingRate == 0 && !c.samplingRateSet {
+		c.SamplingRate = DefaultSamplingRate
+	}
+	if c.ServiceName == "" {
+		c.ServiceName = DefaultServiceName
+	}
+	if c.BaseURL == "" {
+		c.BaseURL = DefaultBaseURL
+	}
+	if c.MaxBatchSize == 0 {
+		c.MaxBatchSize = DefaultMaxBatchSize
+	}
+	if c.ExportInterval == 0 {
+		c.ExportInterval = DefaultExportInterval
+	}
+	return c
+}
+
 // Validate validates the configuration
 func (c *Config) Validate() error {
 	if c.APIKey == "" {
 		return &ValidationError{Message: "API key is required"}
 	}
+	if strings.TrimSpace(c.ServiceName) == "" {
+		return NewValidationError("service name is required", "ServiceName")
+	}
+	if strings.IndexFunc(c.ServiceName, unicode.IsControl) >= 0 {
+		return NewValidationError("service name must not contain control characters", "ServiceName")
+	}
 	if c.SamplingRate < 0.0 || c.SamplingRate > 1.0 {
 		return &ValidationError{Message: "sampling rate must be between 0.0 and 1.0"}
 	}
+	if c.BaseURL != "" {
+		baseURL, err := normalizeBaseURL(c.BaseURL)
+		if err != nil {
+			return NewValidationError(err.Error(), "BaseURL")
+		}
+		c.BaseURL = baseURL
+	}
 	if c.MaxBatchSize <= 0 {
 		return &ValidationError{Message: "max batch size must be positive"}
 	}
 	if c.ExportInterval <= 0 {
 		return &ValidationError{Message: "export interval must be positive"}
 	}
+	if c.HighCostThreshold < 0 {
+		return NewValidationError("high cost threshold must not be negative", "HighCostThreshold")
+	}
+	for i := 1; i < len(c.LatencyBuckets); i++ {
+		if c.LatencyBuckets[i] <= c.LatencyBuckets[i-1] {
+			return NewValidationError("latency buckets must be in increasing order", "LatencyBuckets")
+		}
+	}
+	switch c.MetricTemporality {
+	case "", MetricTemporalityCumulative, MetricTemporalityDelta:
+	default:
+		return NewValidationError("metric temporality must be cumulative or delta", "MetricTemporality")
+	}
+	switch c.AttributeConvention {
+	case "", AttributeConventionUntrace, AttributeConventionOpenInference:
+	default:
+		return NewValidationError("attribute convention must be untrace or openinference", "AttributeConvention")
+	}
+	switch c.SamplerMode {
+	case "", SamplerTraceIDRatio, SamplerParentBased, SamplerAlwaysOn, SamplerRetention:
+	default:
+		return NewValidationError("sampler mode must be traceid_ratio, parent_based, always_on or retention", "SamplerMode")
+	}
+	switch c.ExportFormat {
+	case "", ExportFormatOTLPProto, ExportFormatJSON, ExportFormatOTLPJSON:
+	default:
+		return NewValidationError("export format must be otlp_proto, json or otlp_json", "ExportFormat")
+	}
+	if c.ContextUtilizationThreshold < 0 {
+		return NewValidationError("context utilization threshold must not be negative", "ContextUtilizationThreshold")
+	}
+	if c.MaxAttributeSliceLen < 0 {
+		return NewValidationError("max attribute slice length must not be negative", "MaxAttributeSliceLen")
+	}
+	if c.MaxSpanBytes < 0 {
+		return NewValidationError("max span bytes must not be negative", "MaxSpanBytes")
+	}
+	if _, err := NewRedactor(c.RedactionPatterns...); err != nil {
+		return NewValidationError(err.Error(), "RedactionPatterns")
+	}
 	return nil
 }
+
+// normalizeBaseURL parses a base URL into the form "scheme://host[:port][/path]",
+// defaulting a bare host to https and dropping any trailing slash
+func normalizeBaseURL(raw string) (string, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("base URL is invalid: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("base URL scheme must be http or https, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("base URL %q has no host", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("base URL %q must not have a query or fragment", raw)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// WithProfile returns the config with the profile matching its Environment
+// merged over it, or the config unchanged if no profile matches
+func (c Config) WithProfile() Config {
+	profile, ok := c.Profiles[c.Environment]
+	if !ok {
+		return c
+	}
+
+	merged := c
+	base := reflect.ValueOf(&merged).Elem()
+	overrides := reflect.ValueOf(profile)
+	for i := 0; i < overrides.NumField(); i++ {
+		field := overrides.Field(i)
+		if f := base.Type().Field(i); f.Name == "Profiles" || !f.IsExported() || field.IsZero() {
+			continue
+		}
+		base.Field(i).Set(field)
+	}
+	return merged
+}
+
+// Warnings returns non-fatal guidance about config combinations that are
+// likely misconfigured
+func (c *Config) Warnings() []string {
+	var warnings []string
+
+	if c.MaxBatchSize > 0 && c.MaxBatchSize < 10 && c.ExportInterval >= 30*time.Second {
+		warnings = append(warnings, fmt.Sprintf(
+			"max batch size %d with export interval %s exports tiny batches rarely; spans may wait up to %s before export",
+			c.MaxBatchSize, c.ExportInterval, c.ExportInterval))
+	}
+	if c.MaxBatchSize >= 2048 && c.ExportInterval > 0 && c.ExportInterval < time.Second {
+		warnings = append(warnings, fmt.Sprintf(
+			"max batch size %d with export interval %s wakes the exporter often for batches that rarely fill; consider a longer interval",
+			c.MaxBatchSize, c.ExportInterval))
+	}
+	if c.MaxBatchSize > sdktrace.DefaultMaxQueueSize {
+		warnings = append(warnings, fmt.Sprintf(
+			"max batch size %d exceeds the span queue size %d; batches are capped at the queue size",
+			c.MaxBatchSize, sdktrace.DefaultMaxQueueSize))
+	}
+	if c.SamplerMode == SamplerAlwaysOn && c.SamplingRate < 1 {
+		warnings = append(warnings, fmt.Sprintf(
+			"sampling rate %g is ignored by sampler mode %s; every span is exported",
+			c.SamplingRate, c.SamplerMode))
+	}
+	if c.HighCostThreshold > 0 && !sampledAtExport(*c) {
+		warnings = append(warnings, fmt.Sprintf(
+			"high cost threshold only keeps spans in sampler mode %s; unsampled traces are not recorded", SamplerRetention))
+	}
+
+	return warnings
+}
@@ -0,0 +1,249 @@
+package untrace
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestConfigWarnings(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxBatchSize   int
+		exportInterval time.Duration
+		configure      func(c *Config)
+		want           string
+	}{
+		{name: "defaults", maxBatchSize: DefaultMaxBatchSize, exportInterval: DefaultExportInterval},
+		{name: "tiny batches exported rarely", maxBatchSize: 1, exportInterval: time.Minute, want: "exports tiny batches rarely"},
+		{name: "huge batches exported often", maxBatchSize: 2048, exportInterval: 100 * time.Millisecond, want: "wakes the exporter often"},
+		{name: "batch larger than the queue", maxBatchSize: 4096, exportInterval: DefaultExportInterval, want: "exceeds the span queue size"},
+		{
+			name:           "rate ignored by always on",
+			maxBatchSize:   DefaultMaxBatchSize,
+			exportInterval: DefaultExportInterval,
+			configure:      func(c *Config) { c.SamplerMode, c.SamplingRate = SamplerAlwaysOn, 0.1 },
+			want:           "is ignored by sampler mode always_on",
+		},
+		{
+			name:           "high cost threshold without retention",
+			maxBatchSize:   DefaultMaxBatchSize,
+			exportInterval: DefaultExportInterval,
+			configure:      func(c *Config) { c.HighCostThreshold = 1 },
+			want:           "only keeps spans in sampler mode retention",
+		},
+		{
+			name:           "high cost threshold with retention",
+			maxBatchSize:   DefaultMaxBatchSize,
+			exportInterval: DefaultExportInterval,
+			configure:      func(c *Config) { c.SamplerMode, c.HighCostThreshold = SamplerRetention, 1 },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig("test-key")
+			config.MaxBatchSize = tt.maxBatchSize
+			config.ExportInterval = tt.exportInterval
+			if tt.configure != nil {
+				tt.configure(&config)
+			}
+
+			warnings := config.Warnings()
+			if tt.want == "" {
+				if len(warnings) != 0 {
+					t.Errorf("Warnings() = %q, want none", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 || !strings.Contains(warnings[0], tt.want) {
+				t.Errorf("Warnings() = %q, want one containing %q", warnings, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithProfile(t *testing.T) {
+	profiles := map[string]Config{
+		"production": {SamplingRate: 0.1, ServiceName: "checkout-prod"},
+		"staging":    {Debug: true},
+	}
+
+	tests := []struct {
+		name            string
+		environment     string
+		debug           bool
+		wantServiceName string
+		wantRate        float64
+		wantDebug       bool
+	}{
+		{name: "no matching profile", environment: "development", wantServiceName: "checkout", wantRate: 1},
+		{name: "profile overrides fields", environment: "production", wantServiceName: "checkout-prod", wantRate: 0.1},
+		{name: "zero fields keep the base value", environment: "staging", wantServiceName: "checkout", wantRate: 1, wantDebug: true},
+		{name: "profile cannot turn a bool off", environment: "production", debug: true, wantServiceName: "checkout-prod", wantRate: 0.1, wantDebug: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig("test-key")
+			config.ServiceName = "checkout"
+			config.SamplingRate = 1
+			config.Debug = tt.debug
+			config.Environment = tt.environment
+			config.Profiles = profiles
+
+			got := config.WithProfile()
+			if got.ServiceName != tt.wantServiceName {
+				t.Errorf("ServiceName = %q, want %q", got.ServiceName, tt.wantServiceName)
+			}
+			if got.SamplingRate != tt.wantRate {
+				t.Errorf("SamplingRate = %v, want %v", got.SamplingRate, tt.wantRate)
+			}
+			if got.Debug != tt.wantDebug {
+				t.Errorf("Debug = %v, want %v", got.Debug, tt.wantDebug)
+			}
+			if got.APIKey != "test-key" || len(got.Profiles) != len(profiles) {
+				t.Errorf("WithProfile() lost base fields: APIKey = %q, %d profiles", got.APIKey, len(got.Profiles))
+			}
+		})
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseURL     string
+		wantBaseURL string
+		wantScheme  string
+		wantHost    string
+		wantPath    string
+		wantErr     bool
+	}{
+		{
+			name:        "https URL",
+			baseURL:     "https://untrace.dev",
+			wantBaseURL: "https://untrace.dev",
+			wantScheme:  "https",
+			wantHost:    "untrace.dev",
+		},
+		{
+			name:        "http URL with port",
+			baseURL:     "http://localhost:4318",
+			wantBaseURL: "http://localhost:4318",
+			wantScheme:  "http",
+			wantHost:    "localhost:4318",
+		},
+		{
+			name:        "bare host",
+			baseURL:     "untrace.dev",
+			wantBaseURL: "https://untrace.dev",
+			wantScheme:  "https",
+			wantHost:    "untrace.dev",
+		},
+		{
+			name:        "path prefix with trailing slash",
+			baseURL:     "https://collector.internal/otlp/",
+			wantBaseURL: "https://collector.internal/otlp",
+			wantScheme:  "https",
+			wantHost:    "collector.internal",
+			wantPath:    "/otlp",
+		},
+		{name: "unsupported scheme", baseURL: "ftp://untrace.dev", wantErr: true},
+		{name: "no host", baseURL: "https://", wantErr: true},
+		{name: "query", baseURL: "https://untrace.dev?region=eu", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig("test-key")
+			config.BaseURL = tt.baseURL
+
+			err := config.Validate()
+			if tt.wantErr {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || validationErr.Field != "BaseURL" {
+					t.Errorf("Validate() error = %v, want a BaseURL ValidationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if config.BaseURL != tt.wantBaseURL {
+				t.Errorf("BaseURL = %q, want %q", config.BaseURL, tt.wantBaseURL)
+			}
+
+			// Both exporters must resolve the same endpoint
+			endpoint, ok := parseOTLPEndpoint(tt.baseURL)
+			if !ok {
+				t.Fatalf("parseOTLPEndpoint(%q) failed", tt.baseURL)
+			}
+			if endpoint.Scheme != tt.wantScheme || endpoint.Host != tt.wantHost || endpoint.Path != tt.wantPath {
+				t.Errorf("OTLP endpoint = %s %s %q, want %s %s %q",
+					endpoint.Scheme, endpoint.Host, endpoint.Path, tt.wantScheme, tt.wantHost, tt.wantPath)
+			}
+			if got, want := otlpURL(tt.baseURL, "/v1/traces"), tt.wantBaseURL+"/v1/traces"; got != want {
+				t.Errorf("traces URL = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestServiceNameValidation(t *testing.T) {
+	tests := []struct {
+		name            string
+		serviceName     string
+		wantValidateErr bool
+		wantInitErr     bool
+		wantServiceName string
+	}{
+		{name: "valid", serviceName: "checkout", wantServiceName: "checkout"},
+		{name: "valid with spaces", serviceName: "checkout api", wantServiceName: "checkout api"},
+		{name: "empty, defaulted by Init", serviceName: "", wantValidateErr: true, wantServiceName: DefaultServiceName},
+		{name: "whitespace", serviceName: "   ", wantValidateErr: true, wantInitErr: true},
+		{name: "control character", serviceName: "check\nout", wantValidateErr: true, wantInitErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig("test-key")
+			config.ServiceName = tt.serviceName
+
+			err := config.Validate()
+			if tt.wantValidateErr {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || validationErr.Field != "ServiceName" {
+					t.Errorf("Validate() error = %v, want a ServiceName ValidationError", err)
+				}
+			} else if err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+
+			config.SpanExporter = tracetest.NewInMemoryExporter()
+			client, err := Init(config)
+			if tt.wantInitErr {
+				if err == nil {
+					_ = client.Shutdown(context.Background())
+					_ = Reset()
+					t.Fatalf("Init() succeeded, want a validation error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+			t.Cleanup(func() {
+				_ = client.Shutdown(context.Background())
+				_ = Reset()
+			})
+
+			if got := client.(*untraceClient).config.ServiceName; got != tt.wantServiceName {
+				t.Errorf("ServiceName = %q, want %q", got, tt.wantServiceName)
+			}
+		})
+	}
+}
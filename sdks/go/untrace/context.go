@@ -3,9 +3,11 @@ package untrace
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"sync"
 
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -13,17 +15,41 @@ import (
 type untraceContext struct {
 	mu        sync.RWMutex
 	workflows map[string]Workflow
+	tracer    trace.Tracer
+	metrics   Metrics
 }
 
 // NewContext creates a new Untrace context manager
 func NewContext() Context {
+	return newContext(nil, nil)
+}
+
+// newContext creates a context manager whose workflows are traced as spans
+// with the given tracer and counted as active on the given metrics, if any
+func newContext(tracer trace.Tracer, metrics Metrics) *untraceContext {
 	return &untraceContext{
 		workflows: make(map[string]Workflow),
+		tracer:    tracer,
+		metrics:   metrics,
 	}
 }
 
-// StartWorkflow starts a new workflow
+// StartWorkflow starts a new top-level workflow
 func (c *untraceContext) StartWorkflow(name, runID string, opts WorkflowOptions) Workflow {
+	return c.StartWorkflowContext(context.Background(), name, runID, opts)
+}
+
+// StartWorkflowContext starts a new workflow within ctx. If ctx belongs to a
+// workflow, the new one is nested under it: its span is a child of the
+// parent's and its parent ID defaults to the parent's run ID.
+func (c *untraceContext) StartWorkflowContext(ctx context.Context, name, runID string, opts WorkflowOptions) Workflow {
+	if parent, ok := ctx.Value(workflowKey{}).(*untraceWorkflow); ok && opts.ParentID == "" {
+		opts.ParentID = parent.runID
+	}
+	if opts.PropagateWorkflowBaggage {
+		ctx = withWorkflowBaggage(ctx, runID, opts)
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -31,7 +57,7 @@ func (c *untraceContext) StartWorkflow(name, runID string, opts WorkflowOptions)
 		name:    name,
 		runID:   runID,
 		opts:    opts,
-		ctx:     context.Background(),
+		ctx:     ctx,
 		attrs:   make(map[string]interface{}),
 		context: c,
 	}
@@ -52,22 +78,66 @@ func (c *untraceContext) StartWorkflow(name, runID string, opts WorkflowOptions)
 		workflow.attrs["workflow.session_id"] = opts.SessionID
 	}
 
-	// Add custom metadata
-	for key, value := range opts.Metadata {
+	// Add custom metadata, masking secrets it may carry
+	for key, value := range SanitizeAttributes(opts.Metadata) {
 		workflow.attrs["workflow.metadata."+key] = value
 	}
 
+	// Trace the workflow as a span covering it until End
+	if c.tracer != nil {
+		workflow.ctx, workflow.span = c.tracer.Start(workflow.ctx, fmt.Sprintf("workflow.%s", name),
+			trace.WithAttributes(workflow.buildAttributes()...),
+		)
+	}
+
+	// Bind the workflow to its own context so concurrent workflows can each
+	// find theirs
+	workflow.ctx = context.WithValue(workflow.ctx, workflowKey{}, workflow)
+
 	c.workflows[runID] = workflow
+	if c.metrics != nil {
+		c.metrics.WithContext(workflow.ctx).RecordActiveWorkflows(1)
+	}
 	return workflow
 }
 
-// GetCurrentWorkflow returns the current workflow if any
+// workflowKey is the context key for the workflow a context belongs to
+type workflowKey struct{}
+
+// WorkflowFromContext returns the workflow whose Context ctx derives from, or
+// nil if ctx is not part of a workflow
+func WorkflowFromContext(ctx context.Context) Workflow {
+	if workflow, ok := ctx.Value(workflowKey{}).(*untraceWorkflow); ok {
+		return workflow
+	}
+	return nil
+}
+
+// abandonWorkflows ends every workflow still running, tagging it as
+// abandoned so its span is exported instead of staying open
+func (c *untraceContext) abandonWorkflows() {
+	c.mu.RLock()
+	live := make([]*untraceWorkflow, 0, len(c.workflows))
+	for _, workflow := range c.workflows {
+		if w, ok := workflow.(*untraceWorkflow); ok {
+			live = append(live, w)
+		}
+	}
+	c.mu.RUnlock()
+
+	for _, w := range live {
+		w.end(WorkflowStatusAbandoned)
+	}
+}
+
+// GetCurrentWorkflow returns an arbitrary running workflow, if any.
+//
+// Deprecated: with concurrent workflows the result is not necessarily the
+// caller's; use WorkflowFromContext.
 func (c *untraceContext) GetCurrentWorkflow() Workflow {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	// For simplicity, return the first workflow
-	// In a real implementation, you might track the current workflow per goroutine
 	for _, workflow := range c.workflows {
 		return workflow
 	}
@@ -86,6 +156,122 @@ func (c *untraceContext) SetAttributes(attrs map[string]interface{}) {
 	// For now, this is a placeholder
 }
 
+// contextAttributesKey is the context key for attributes applied to every
+// span started in a context
+type contextAttributesKey struct{}
+
+// withContextAttributes returns a copy of ctx whose spans get the given
+// attributes in addition to any already attached
+func withContextAttributes(ctx context.Context, attrs ...attribute.KeyValue) context.Context {
+	existing := contextAttributes(ctx)
+	merged := make([]attribute.KeyValue, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, contextAttributesKey{}, merged)
+}
+
+// contextAttributes returns the attributes attached to ctx for its spans
+func contextAttributes(ctx context.Context) []attribute.KeyValue {
+	attrs, _ := ctx.Value(contextAttributesKey{}).([]attribute.KeyValue)
+	return attrs
+}
+
+// WithEvalRun returns a copy of ctx whose spans are tagged with the
+// evaluation dataset and run they belong to
+func WithEvalRun(ctx context.Context, datasetID, runID string) context.Context {
+	return withContextAttributes(ctx,
+		attribute.String(EvalDatasetIDKey, datasetID),
+		attribute.String(EvalRunIDKey, runID),
+	)
+}
+
+// WithConversationID returns a copy of ctx whose spans are tagged with the
+// conversation (thread) they belong to
+func WithConversationID(ctx context.Context, id string) context.Context {
+	return withContextAttributes(ctx, attribute.String(ConversationIDKey, id))
+}
+
+// WithConversationIDBaggage is like WithConversationID but also seeds the ID
+// into baggage so it propagates to downstream services. It returns an error
+// if the ID is not a valid baggage value.
+func WithConversationIDBaggage(ctx context.Context, id string) (context.Context, error) {
+	member, err := baggage.NewMember(ConversationIDKey, id)
+	if err != nil {
+		return ctx, fmt.Errorf("invalid conversation ID: %w", err)
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to set conversation baggage: %w", err)
+	}
+	return WithConversationID(baggage.ContextWithBaggage(ctx, bag), id), nil
+}
+
+// WithBaggage returns a copy of ctx whose baggage carries key set to value,
+// propagated to downstream services with the trace context. The value is
+// percent-encoded as needed. It returns an error if the key is not a valid
+// baggage key.
+func WithBaggage(ctx context.Context, key, value string) (context.Context, error) {
+	member, err := baggage.NewMember(key, url.PathEscape(value))
+	if err != nil {
+		return ctx, fmt.Errorf("invalid baggage member %q: %w", key, err)
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to set baggage %q: %w", key, err)
+	}
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
+
+// BaggageFromContext returns the value of key in the baggage of ctx, or ""
+// if it is not set
+func BaggageFromContext(ctx context.Context, key string) string {
+	return baggage.FromContext(ctx).Member(key).Value()
+}
+
+// withWorkflowBaggage places the workflow's IDs in the baggage of ctx,
+// skipping any that cannot be set, e.g. because the baggage is full
+func withWorkflowBaggage(ctx context.Context, runID string, opts WorkflowOptions) context.Context {
+	ids := map[string]string{
+		WorkflowRunIDKey:     runID,
+		WorkflowUserIDKey:    opts.UserID,
+		WorkflowSessionIDKey: opts.SessionID,
+	}
+	for key, value := range ids {
+		if value == "" {
+			continue
+		}
+		if next, err := WithBaggage(ctx, key, value); err == nil {
+			ctx = next
+		}
+	}
+	return ctx
+}
+
+// conversationIDFromBaggage returns the conversation ID propagated in the
+// baggage of ctx, if any
+func conversationIDFromBaggage(ctx context.Context) (string, bool) {
+	member := baggage.FromContext(ctx).Member(ConversationIDKey)
+	if member.Key() == "" {
+		return "", false
+	}
+	return member.Value(), true
+}
+
+// Detach returns a context for background work that outlives the request
+// in ctx. It keeps ctx's values, so spans started from it stay in the same
+// trace with the same baggage and context attributes, but it is never
+// cancelled and has no deadline.
+func Detach(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
+
+// Workflow statuses
+const (
+	// WorkflowStatusAbandoned marks a workflow ended by Client.Shutdown
+	// because End was never called
+	WorkflowStatusAbandoned = "abandoned"
+)
+
 // untraceWorkflow implements the Workflow interface
 type untraceWorkflow struct {
 	name    string
@@ -94,12 +280,18 @@ type untraceWorkflow struct {
 	ctx     context.Context
 	attrs   map[string]interface{}
 	context *untraceContext
+	span    trace.Span
 	ended   bool
 	mu      sync.RWMutex
 }
 
 // End ends the workflow
 func (w *untraceWorkflow) End() {
+	w.end("")
+}
+
+// end ends the workflow and its span, tagging the span with status if set
+func (w *untraceWorkflow) end(status string) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
@@ -109,6 +301,17 @@ func (w *untraceWorkflow) End() {
 
 	w.ended = true
 
+	if w.context.metrics != nil {
+		w.context.metrics.WithContext(w.ctx).RecordActiveWorkflows(-1)
+	}
+
+	if w.span != nil {
+		if status != "" {
+			w.span.SetAttributes(attribute.String(WorkflowStatusKey, status))
+		}
+		w.span.End()
+	}
+
 	// Remove from context
 	w.context.mu.Lock()
 	delete(w.context.workflows, w.runID)
@@ -126,6 +329,9 @@ func (w *untraceWorkflow) SetAttribute(key string, value interface{}) {
 	defer w.mu.Unlock()
 
 	w.attrs[key] = value
+	if w.span != nil && !w.ended {
+		w.span.SetAttributes(toAttribute(key, value))
+	}
 }
 
 // SetAttributes sets multiple attributes on the workflow
@@ -135,6 +341,9 @@ func (w *untraceWorkflow) SetAttributes(attrs map[string]interface{}) {
 
 	for key, value := range attrs {
 		w.attrs[key] = value
+		if w.span != nil && !w.ended {
+			w.span.SetAttributes(toAttribute(key, value))
+		}
 	}
 }
 
@@ -156,29 +365,49 @@ func (w *untraceWorkflow) BuildAttributes() []attribute.KeyValue {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 
+	return w.buildAttributes()
+}
+
+// buildAttributes converts workflow attributes to OpenTelemetry attributes;
+// the caller must hold w.mu
+func (w *untraceWorkflow) buildAttributes() []attribute.KeyValue {
 	var result []attribute.KeyValue
 	for key, value := range w.attrs {
-		switch v := value.(type) {
-		case string:
-			result = append(result, attribute.String(key, v))
-		case int:
-			result = append(result, attribute.Int(key, v))
-		case int64:
-			result = append(result, attribute.Int64(key, v))
-		case float64:
-			result = append(result, attribute.Float64(key, v))
-		case bool:
-			result = append(result, attribute.Bool(key, v))
-		case []string:
-			result = append(result, attribute.StringSlice(key, v))
-		case []int:
-			result = append(result, attribute.IntSlice(key, v))
-		case []float64:
-			result = append(result, attribute.Float64Slice(key, v))
-		default:
-			// Convert to string as fallback
-			result = append(result, attribute.String(key, fmt.Sprintf("%v", v)))
-		}
+		result = append(result, toAttribute(key, value))
 	}
 	return result
 }
+
+// AddEvent adds a timestamped event with the given attributes to a span
+func AddEvent(span trace.Span, name string, attrs map[string]interface{}) {
+	eventAttrs := make([]attribute.KeyValue, 0, len(attrs))
+	for key, value := range attrs {
+		eventAttrs = append(eventAttrs, toAttribute(key, value))
+	}
+	span.AddEvent(name, trace.WithAttributes(eventAttrs...))
+}
+
+// toAttribute converts a workflow attribute to an OpenTelemetry attribute
+func toAttribute(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case []string:
+		return attribute.StringSlice(key, v)
+	case []int:
+		return attribute.IntSlice(key, v)
+	case []float64:
+		return attribute.Float64Slice(key, v)
+	default:
+		// Convert to string as fallback
+		return attribute.String(key, fmt.Sprintf("%v", v))
+	}
+}
@@ -0,0 +1,67 @@
+package untrace
+
+import (
+	"sync"
+)
+
+// DefaultContextUtilizationThreshold is the default
+// Config.ContextUtilizationThreshold
+const DefaultContextUtilizationThreshold = 0.9
+
+// defaultContextWindows holds the context window sizes in tokens of common
+// OpenAI and Anthropic models, keyed by provider and model
+var defaultContextWindows = map[string]map[string]int{
+	"openai": {
+		"gpt-4":         8192,
+		"gpt-4-32k":     32768,
+		"gpt-4-turbo":   128000,
+		"gpt-4o":        128000,
+		"gpt-4o-mini":   128000,
+		"gpt-3.5-turbo": 16385,
+		"o1":            200000,
+	},
+	"anthropic": {
+		"claude-3-opus-20240229":     200000,
+		"claude-3-sonnet-20240229":   200000,
+		"claude-3-haiku-20240307":    200000,
+		"claude-3-5-sonnet-20240620": 200000,
+		"claude-3-5-sonnet-20241022": 200000,
+		"claude-3-5-haiku-20241022":  200000,
+	},
+}
+
+// Context window table management
+var (
+	contextWindowTable = newContextWindowTable()
+	contextWindowMu    sync.RWMutex
+)
+
+// newContextWindowTable creates the context window table seeded with the
+// default sizes
+func newContextWindowTable() map[string]int {
+	table := make(map[string]int)
+	for provider, models := range defaultContextWindows {
+		for model, tokens := range models {
+			table[pricingKey(provider, model)] = tokens
+		}
+	}
+	return table
+}
+
+// RegisterContextWindow adds or overrides the context window size of a model
+// in tokens
+func RegisterContextWindow(provider, model string, tokens int) {
+	contextWindowMu.Lock()
+	defer contextWindowMu.Unlock()
+
+	contextWindowTable[pricingKey(provider, model)] = tokens
+}
+
+// LookupContextWindow returns the context window size of a model in tokens
+func LookupContextWindow(provider, model string) (int, bool) {
+	contextWindowMu.RLock()
+	defer contextWindowMu.RUnlock()
+
+	tokens, ok := contextWindowTable[pricingKey(provider, model)]
+	return tokens, ok
+}
@@ -0,0 +1,63 @@
+package untrace
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestContextUtilization(t *testing.T) {
+	tests := []struct {
+		name            string
+		model           string
+		promptTokens    *int
+		threshold       float64
+		wantUtilization float64
+		wantHigh        bool
+		wantAbsent      bool
+	}{
+		{name: "half full", model: "gpt-4", promptTokens: tokenCount(4096), wantUtilization: 0.5},
+		{name: "above the default threshold", model: "gpt-4", promptTokens: tokenCount(8000), wantUtilization: 8000.0 / 8192, wantHigh: true},
+		{name: "custom threshold", model: "gpt-4", promptTokens: tokenCount(4096), threshold: 0.5, wantUtilization: 0.5, wantHigh: true},
+		{name: "unknown model", model: "gpt-unknown", promptTokens: tokenCount(4096), wantAbsent: true},
+		{name: "no prompt tokens", model: "gpt-4", wantAbsent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.ContextUtilizationThreshold = tt.threshold
+			})
+
+			_, span := client.Tracer().StartLLMSpan(context.Background(), "llm.chat", LLMSpanOptions{
+				Provider:     "openai",
+				Model:        tt.model,
+				PromptTokens: tt.promptTokens,
+			})
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.chat")
+			utilization, ok := attrValue(got.Attributes, LLMContextUtilizationKey)
+			if tt.wantAbsent {
+				if ok {
+					t.Errorf("%s = %v, want it absent", LLMContextUtilizationKey, utilization.AsFloat64())
+				}
+				return
+			}
+			if !ok || math.Abs(utilization.AsFloat64()-tt.wantUtilization) > 1e-9 {
+				t.Errorf("%s = %v, want %v", LLMContextUtilizationKey, utilization.AsFloat64(), tt.wantUtilization)
+			}
+			if window, _ := attrValue(got.Attributes, LLMContextWindowKey); window.AsInt64() != 8192 {
+				t.Errorf("%s = %d, want 8192", LLMContextWindowKey, window.AsInt64())
+			}
+			if high, _ := attrValue(got.Attributes, LLMContextHighUtilizationKey); high.AsBool() != tt.wantHigh {
+				t.Errorf("%s = %v, want %v", LLMContextHighUtilizationKey, high.AsBool(), tt.wantHigh)
+			}
+		})
+	}
+}
+
+// tokenCount returns a pointer to a token count, for LLMSpanOptions
+func tokenCount(n int) *int {
+	return &n
+}
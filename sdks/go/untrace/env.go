@@ -0,0 +1,112 @@
+package untrace
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Environment variables read by InitFromEnv
+const (
+	EnvAPIKey         = "UNTRACE_API_KEY"
+	EnvServiceName    = "UNTRACE_SERVICE_NAME"
+	EnvEnvironment    = "UNTRACE_ENVIRONMENT"
+	EnvVersion        = "UNTRACE_VERSION"
+	EnvBaseURL        = "UNTRACE_BASE_URL"
+	EnvDebug          = "UNTRACE_DEBUG"
+	EnvSamplingRate   = "UNTRACE_SAMPLING_RATE"
+	EnvMaxBatchSize   = "UNTRACE_MAX_BATCH_SIZE"
+	EnvExportInterval = "UNTRACE_EXPORT_INTERVAL"
+)
+
+// InitFromEnv initializes the Untrace SDK from environment variables. Only
+// UNTRACE_API_KEY is required; unset variables keep their DefaultConfig
+// value. UNTRACE_DEBUG takes a boolean, UNTRACE_SAMPLING_RATE a float,
+// UNTRACE_MAX_BATCH_SIZE an integer and UNTRACE_EXPORT_INTERVAL a Go
+// duration such as "5s". A variable that fails to parse is reported as a
+// ConfigurationError naming it.
+func InitFromEnv() (Client, error) {
+	config, err := configFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return Init(config)
+}
+
+// MustInit is like Init but panics if initialization fails
+func MustInit(config Config) Client {
+	client, err := Init(config)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// MustInitFromEnv is like InitFromEnv but panics if initialization fails
+func MustInitFromEnv() Client {
+	client, err := InitFromEnv()
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// configFromEnv builds a config from the environment on top of the defaults
+func configFromEnv() (Config, error) {
+	apiKey := os.Getenv(EnvAPIKey)
+	if apiKey == "" {
+		return Config{}, NewConfigurationError(fmt.Sprintf("%s is not set", EnvAPIKey), nil)
+	}
+
+	config := DefaultConfig(apiKey)
+
+	if value := os.Getenv(EnvServiceName); value != "" {
+		config.ServiceName = value
+	}
+	if value := os.Getenv(EnvEnvironment); value != "" {
+		config.Environment = value
+	}
+	if value := os.Getenv(EnvVersion); value != "" {
+		config.Version = value
+	}
+	if value := os.Getenv(EnvBaseURL); value != "" {
+		config.BaseURL = value
+	}
+
+	if value := os.Getenv(EnvDebug); value != "" {
+		debug, err := strconv.ParseBool(value)
+		if err != nil {
+			return Config{}, envError(EnvDebug, value, err)
+		}
+		config.Debug = debug
+	}
+	if value := os.Getenv(EnvSamplingRate); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Config{}, envError(EnvSamplingRate, value, err)
+		}
+		config.SamplingRate = rate
+	}
+	if value := os.Getenv(EnvMaxBatchSize); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return Config{}, envError(EnvMaxBatchSize, value, err)
+		}
+		config.MaxBatchSize = size
+	}
+	if value := os.Getenv(EnvExportInterval); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return Config{}, envError(EnvExportInterval, value, err)
+		}
+		config.ExportInterval = interval
+	}
+
+	return config, nil
+}
+
+// envError reports an environment variable that failed to parse
+func envError(name, value string, err error) *ConfigurationError {
+	return NewConfigurationError(fmt.Sprintf("invalid %s %q", name, value), err)
+}
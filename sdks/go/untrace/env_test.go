@@ -0,0 +1,109 @@
+package untrace
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		check       func(t *testing.T, config Config)
+		wantErrName string
+	}{
+		{
+			name: "API key only",
+			env:  map[string]string{EnvAPIKey: "env-key"},
+			check: func(t *testing.T, config Config) {
+				defaults := DefaultConfig("env-key")
+				if config.APIKey != "env-key" || config.ServiceName != defaults.ServiceName || config.SamplingRate != defaults.SamplingRate {
+					t.Errorf("config = %+v, want the defaults with the API key", config)
+				}
+			},
+		},
+		{
+			name: "all variables",
+			env: map[string]string{
+				EnvAPIKey:         "env-key",
+				EnvServiceName:    "checkout",
+				EnvEnvironment:    "staging",
+				EnvVersion:        "1.4.0",
+				EnvBaseURL:        "https://collector.example.com",
+				EnvDebug:          "true",
+				EnvSamplingRate:   "0.25",
+				EnvMaxBatchSize:   "64",
+				EnvExportInterval: "2s",
+			},
+			check: func(t *testing.T, config Config) {
+				if config.ServiceName != "checkout" || config.Environment != "staging" || config.Version != "1.4.0" ||
+					config.BaseURL != "https://collector.example.com" || !config.Debug || config.SamplingRate != 0.25 ||
+					config.MaxBatchSize != 64 || config.ExportInterval != 2*time.Second {
+					t.Errorf("config = %+v", config)
+				}
+			},
+		},
+		{name: "missing API key", env: map[string]string{EnvServiceName: "checkout"}, wantErrName: EnvAPIKey},
+		{name: "invalid debug", env: map[string]string{EnvAPIKey: "k", EnvDebug: "maybe"}, wantErrName: EnvDebug},
+		{name: "invalid sampling rate", env: map[string]string{EnvAPIKey: "k", EnvSamplingRate: "half"}, wantErrName: EnvSamplingRate},
+		{name: "invalid batch size", env: map[string]string{EnvAPIKey: "k", EnvMaxBatchSize: "1.5"}, wantErrName: EnvMaxBatchSize},
+		{name: "invalid export interval", env: map[string]string{EnvAPIKey: "k", EnvExportInterval: "5"}, wantErrName: EnvExportInterval},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{EnvAPIKey, EnvServiceName, EnvEnvironment, EnvVersion, EnvBaseURL, EnvDebug, EnvSamplingRate, EnvMaxBatchSize, EnvExportInterval} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			config, err := configFromEnv()
+			if tt.wantErrName != "" {
+				var configErr *ConfigurationError
+				if !errors.As(err, &configErr) || !strings.Contains(err.Error(), tt.wantErrName) {
+					t.Fatalf("configFromEnv() error = %v, want a ConfigurationError naming %s", err, tt.wantErrName)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("configFromEnv() error = %v", err)
+			}
+			tt.check(t, config)
+		})
+	}
+}
+
+func TestMustInit(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		wantPanic bool
+	}{
+		{name: "valid config", config: DefaultConfig("test-key")},
+		{name: "missing API key", config: DefaultConfig(""), wantPanic: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if panicked := recover() != nil; panicked != tt.wantPanic {
+					t.Errorf("MustInit() panicked = %v, want %v", panicked, tt.wantPanic)
+				}
+			}()
+
+			tt.config.SpanExporter = tracetest.NewInMemoryExporter()
+			client := MustInit(tt.config)
+			if client == nil {
+				t.Fatal("MustInit() = nil")
+			}
+			t.Cleanup(func() {
+				_ = client.Shutdown(context.Background())
+				_ = Reset()
+			})
+		})
+	}
+}
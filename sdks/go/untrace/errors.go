@@ -1,6 +1,19 @@
 package untrace
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrAlreadyInitialized is wrapped by the ConfigurationError returned when
+// Init is called again with a configuration conflicting with the active one
+var ErrAlreadyInitialized = errors.New("untrace: SDK already initialized")
 
 // UntraceError represents a base error for all Untrace SDK errors
 type UntraceError struct {
@@ -81,3 +94,83 @@ func NewInstrumentationError(message, provider string, err error) *Instrumentati
 		Provider: provider,
 	}
 }
+
+// ExportError represents a failed span export
+type ExportError struct {
+	UntraceError
+	// TraceIDs holds a capped sample of the trace IDs in the failed batch
+	TraceIDs []string
+}
+
+func NewExportError(message string, traceIDs []string, err error) *ExportError {
+	return &ExportError{
+		UntraceError: UntraceError{
+			Message: message,
+			Err:     err,
+		},
+		TraceIDs: traceIDs,
+	}
+}
+
+// LLMError represents a structured error returned by an LLM provider
+type LLMError struct {
+	Code      string
+	Type      string
+	Param     string
+	RequestID string
+	Message   string
+}
+
+func (e *LLMError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Message)
+	}
+	return e.Message
+}
+
+// RecordLLMError records a structured provider error on the span in ctx,
+// mapping each field to its own llm.error.* attribute and marking the span
+// failed, and counts it on the typed LLM error metric. Use it to report errors
+// that only become known after StartLLMSpan.
+func RecordLLMError(ctx context.Context, llmErr LLMError) {
+	attrs := []attribute.KeyValue{attribute.String(LLMErrorKey, llmErr.Message)}
+	if llmErr.Code != "" {
+		attrs = append(attrs, attribute.String(LLMErrorCodeKey, llmErr.Code))
+	}
+	if llmErr.Type != "" {
+		attrs = append(attrs, attribute.String(LLMErrorTypeKey, llmErr.Type))
+	}
+	if llmErr.Param != "" {
+		attrs = append(attrs, attribute.String(LLMErrorParamKey, llmErr.Param))
+	}
+	if llmErr.RequestID != "" {
+		attrs = append(attrs, attribute.String(LLMErrorRequestIDKey, llmErr.RequestID))
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attrs...)
+	span.RecordError(&llmErr)
+	span.SetStatus(codes.Error, llmErr.Error())
+
+	if client := GetInstance(); client != nil {
+		client.Metrics().WithContext(ctx).RecordLLMError(llmErr)
+	}
+}
+
+// errorType classifies err into a stable, low-cardinality label: the type of
+// a structured provider error, or else the Go type of the innermost error in
+// its wrap chain
+func errorType(err error) string {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) && llmErr.Type != "" {
+		return llmErr.Type
+	}
+
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return reflect.TypeOf(err).String()
+		}
+		err = inner
+	}
+}
@@ -0,0 +1,87 @@
+package untrace
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestRecordLLMError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        LLMError
+		wantAttrs  map[string]string
+		absent     []string
+		wantStatus string
+	}{
+		{
+			name: "all fields",
+			err: LLMError{
+				Code:      "context_length_exceeded",
+				Type:      "invalid_request_error",
+				Param:     "messages",
+				RequestID: "req_123",
+				Message:   "maximum context length is 8192 tokens",
+			},
+			wantAttrs: map[string]string{
+				LLMErrorKey:          "maximum context length is 8192 tokens",
+				LLMErrorCodeKey:      "context_length_exceeded",
+				LLMErrorTypeKey:      "invalid_request_error",
+				LLMErrorParamKey:     "messages",
+				LLMErrorRequestIDKey: "req_123",
+			},
+			wantStatus: "context_length_exceeded: maximum context length is 8192 tokens",
+		},
+		{
+			name: "message and type only",
+			err:  LLMError{Type: "server_error", Message: "overloaded"},
+			wantAttrs: map[string]string{
+				LLMErrorKey:     "overloaded",
+				LLMErrorTypeKey: "server_error",
+			},
+			absent:     []string{LLMErrorCodeKey, LLMErrorParamKey, LLMErrorRequestIDKey},
+			wantStatus: "overloaded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			ctx, span := client.Tracer().StartLLMSpan(context.Background(), "llm.chat", LLMSpanOptions{
+				Provider: "openai",
+				Model:    "gpt-4",
+			})
+			RecordLLMError(ctx, tt.err)
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.chat")
+			for key, want := range tt.wantAttrs {
+				if value, _ := attrValue(got.Attributes, key); value.AsString() != want {
+					t.Errorf("%s = %q, want %q", key, value.AsString(), want)
+				}
+			}
+			for _, key := range tt.absent {
+				if _, ok := attrValue(got.Attributes, key); ok {
+					t.Errorf("%s is set, want it absent", key)
+				}
+			}
+			if got.Status.Code != codes.Error || got.Status.Description != tt.wantStatus {
+				t.Errorf("status = %v %q, want Error %q", got.Status.Code, got.Status.Description, tt.wantStatus)
+			}
+
+			var counted bool
+			for _, point := range sumPoints(t, collectMetric(t, client.snapshotReader, "llm.errors")) {
+				errType, _ := point.Attributes.Value("error.type")
+				errCode, _ := point.Attributes.Value("error.code")
+				if errType.AsString() == tt.err.Type && errCode.AsString() == tt.err.Code && point.Value == 1 {
+					counted = true
+				}
+			}
+			if !counted {
+				t.Errorf("error not counted with type %q and code %q", tt.err.Type, tt.err.Code)
+			}
+		})
+	}
+}
@@ -2,15 +2,26 @@ package untrace
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
@@ -21,6 +32,9 @@ type UntraceExporter struct {
 	config     Config
 	httpClient *http.Client
 	baseURL    string
+
+	// schemaNotice logs a schema downgrade requested by the server once
+	schemaNotice sync.Once
 }
 
 // NewUntraceExporter creates a new Untrace exporter
@@ -32,7 +46,7 @@ func NewUntraceExporter(config Config) (*UntraceExporter, error) {
 	return &UntraceExporter{
 		config:     config,
 		httpClient: client,
-		baseURL:    config.BaseURL + "/v1/traces",
+		baseURL:    otlpURL(config.BaseURL, "/v1/traces"),
 	}, nil
 }
 
@@ -42,8 +56,21 @@ func (e *UntraceExporter) ExportSpans(ctx context.Context, spans []sdktrace.Read
 		return nil
 	}
 
-	// Convert spans to the format expected by Untrace API
-	payload, err := e.convertSpansToPayload(spans)
+	// Don't start an export the caller already gave up on, e.g. on shutdown
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Bound the export by the earlier of the caller's deadline and the
+	// client timeout
+	if e.httpClient.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.httpClient.Timeout)
+		defer cancel()
+	}
+
+	// Convert spans to the configured export format
+	payload, err := e.buildPayload(spans)
 	if err != nil {
 		return fmt.Errorf("failed to convert spans: %w", err)
 	}
@@ -58,6 +85,14 @@ func (e *UntraceExporter) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// buildPayload converts spans to the payload of the configured export format
+func (e *UntraceExporter) buildPayload(spans []sdktrace.ReadOnlySpan) (map[string]interface{}, error) {
+	if e.config.ExportFormat == ExportFormatOTLPJSON {
+		return e.convertSpansToOTLPJSON(spans)
+	}
+	return e.convertSpansToPayload(spans)
+}
+
 // convertSpansToPayload converts OpenTelemetry spans to Untrace API format
 func (e *UntraceExporter) convertSpansToPayload(spans []sdktrace.ReadOnlySpan) (map[string]interface{}, error) {
 	// This is a simplified conversion - in a real implementation,
@@ -94,15 +129,29 @@ func (e *UntraceExporter) sendToAPI(ctx context.Context, payload map[string]inte
 		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", e.baseURL, bytes.NewBuffer(jsonData))
+	// Compress large payloads; tiny batches are not worth the overhead
+	body := jsonData
+	compressed := false
+	if threshold := e.compressionThreshold(); threshold >= 0 && len(jsonData) > threshold {
+		if body, err = gzipBytes(jsonData); err != nil {
+			return fmt.Errorf("failed to compress payload: %w", err)
+		}
+		compressed = true
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.baseURL, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 	req.Header.Set("Authorization", "Bearer "+e.config.APIKey)
-	req.Header.Set("User-Agent", "untrace-sdk-go/0.1.0")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(schemaVersionHeader, PayloadSchemaVersion)
 
 	// Add custom headers
 	for key, value := range e.config.Headers {
@@ -120,6 +169,14 @@ func (e *UntraceExporter) sendToAPI(ctx context.Context, payload map[string]inte
 	}
 	defer resp.Body.Close()
 
+	// The server may ask for an older schema; the SDK only emits the current
+	// one, so surface the mismatch for an upgrade or downgrade decision
+	if accepted := resp.Header.Get(acceptSchemaVersionHeader); accepted != "" && accepted != PayloadSchemaVersion {
+		e.schemaNotice.Do(func() {
+			log.Printf("[Untrace] Warning: server accepts payload schema version %s, SDK emits %s", accepted, PayloadSchemaVersion)
+		})
+	}
+
 	if resp.StatusCode >= 400 {
 		body, _ := io.ReadAll(resp.Body)
 		return NewAPIError(
@@ -133,18 +190,410 @@ func (e *UntraceExporter) sendToAPI(ctx context.Context, payload map[string]inte
 	return nil
 }
 
+// compressionThreshold returns the payload size above which exports are
+// compressed, or a negative value if compression is disabled
+func (e *UntraceExporter) compressionThreshold() int {
+	if e.config.CompressionThreshold == 0 {
+		return DefaultCompressionThreshold
+	}
+	return e.config.CompressionThreshold
+}
+
+// gzipBytes returns the gzip compression of data
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// dryRunExporter converts and serializes spans like a real export but never
+// sends them
+type dryRunExporter struct {
+	converter *UntraceExporter
+	debug     bool
+}
+
+// newDryRunExporter creates a dry-run exporter
+func newDryRunExporter(config Config) *dryRunExporter {
+	converter, _ := NewUntraceExporter(config)
+	return &dryRunExporter{
+		converter: converter,
+		debug:     config.Debug,
+	}
+}
+
+// ExportSpans serializes spans and logs what would have been exported
+func (e *dryRunExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	payload, err := e.converter.buildPayload(spans)
+	if err != nil {
+		return fmt.Errorf("failed to convert spans: %w", err)
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	if e.debug {
+		log.Printf("[Untrace] Dry run: would export %d spans (%d bytes)", len(spans), len(jsonData))
+	}
+
+	return nil
+}
+
+// Shutdown shuts down the exporter
+func (e *dryRunExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// countingExporter wraps a span exporter and counts successfully exported spans
+type countingExporter struct {
+	sdktrace.SpanExporter
+	exported atomic.Int64
+}
+
+// newCountingExporter wraps the given exporter with an export counter
+func newCountingExporter(exporter sdktrace.SpanExporter) *countingExporter {
+	return &countingExporter{SpanExporter: exporter}
+}
+
+// ExportSpans exports spans and counts them when the export succeeds
+func (e *countingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if err := e.SpanExporter.ExportSpans(ctx, spans); err != nil {
+		return err
+	}
+	e.exported.Add(int64(len(spans)))
+	return nil
+}
+
+// Exported returns the total number of spans exported so far
+func (e *countingExporter) Exported() int64 {
+	return e.exported.Load()
+}
+
+// maxReportedTraceIDs caps the trace IDs sampled into an export error
+const maxReportedTraceIDs = 5
+
+// errorReportingExporter reports failed exports with the affected trace IDs
+// and tracks whether the latest export succeeded
+type errorReportingExporter struct {
+	sdktrace.SpanExporter
+	config  Config
+	failing atomic.Bool
+}
+
+// newErrorReportingExporter wraps the given exporter with export error reporting
+func newErrorReportingExporter(exporter sdktrace.SpanExporter, config Config) *errorReportingExporter {
+	return &errorReportingExporter{
+		SpanExporter: exporter,
+		config:       config,
+	}
+}
+
+// ExportSpans exports spans and reports failures with a sample of trace IDs
+func (e *errorReportingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.failing.Store(err != nil)
+	if err == nil {
+		if e.config.OnExport != nil {
+			e.config.OnExport(len(spans), nil)
+		}
+		return nil
+	}
+
+	traceIDs := sampleTraceIDs(spans, maxReportedTraceIDs)
+	exportErr := NewExportError(fmt.Sprintf("failed to export %d spans (traces %v)", len(spans), traceIDs), traceIDs, err)
+
+	if e.config.Debug {
+		log.Printf("[Untrace] %v", exportErr)
+	}
+	if e.config.OnExportError != nil {
+		e.config.OnExportError(exportErr)
+	}
+	if e.config.OnExport != nil {
+		e.config.OnExport(len(spans), exportErr)
+	}
+
+	return exportErr
+}
+
+// Healthy reports whether the latest export attempt succeeded
+func (e *errorReportingExporter) Healthy() bool {
+	return !e.failing.Load()
+}
+
+// sampleTraceIDs returns up to limit distinct trace IDs from the spans
+func sampleTraceIDs(spans []sdktrace.ReadOnlySpan, limit int) []string {
+	seen := make(map[string]bool)
+	traceIDs := make([]string, 0, limit)
+	for _, span := range spans {
+		if len(traceIDs) == limit {
+			break
+		}
+		traceID := span.SpanContext().TraceID().String()
+		if !seen[traceID] {
+			seen[traceID] = true
+			traceIDs = append(traceIDs, traceID)
+		}
+	}
+	return traceIDs
+}
+
+// spanLimitExporter truncates oversized spans before handing them to the
+// wrapped exporter
+type spanLimitExporter struct {
+	sdktrace.SpanExporter
+	maxSpanBytes int
+}
+
+// newSpanLimitExporter wraps the given exporter with a per-span size limit
+func newSpanLimitExporter(exporter sdktrace.SpanExporter, maxSpanBytes int) *spanLimitExporter {
+	return &spanLimitExporter{
+		SpanExporter: exporter,
+		maxSpanBytes: maxSpanBytes,
+	}
+}
+
+// ExportSpans truncates any span over the limit and exports the batch
+func (e *spanLimitExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	limited := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, span := range spans {
+		limited[i] = truncateSpan(span, e.maxSpanBytes)
+	}
+	return e.SpanExporter.ExportSpans(ctx, limited)
+}
+
+// attributeOverrideSpan replaces the attributes, and optionally the events,
+// of a read-only span
+type attributeOverrideSpan struct {
+	sdktrace.ReadOnlySpan
+	attrs  []attribute.KeyValue
+	events []sdktrace.Event
+}
+
+// Attributes returns the replacement attributes
+func (s *attributeOverrideSpan) Attributes() []attribute.KeyValue {
+	return s.attrs
+}
+
+// Events returns the replacement events, if any were set
+func (s *attributeOverrideSpan) Events() []sdktrace.Event {
+	if s.events == nil {
+		return s.ReadOnlySpan.Events()
+	}
+	return s.events
+}
+
+// withAttributes returns the span with extra attributes appended
+func withAttributes(span sdktrace.ReadOnlySpan, extra ...attribute.KeyValue) sdktrace.ReadOnlySpan {
+	attrs := make([]attribute.KeyValue, 0, len(span.Attributes())+len(extra))
+	attrs = append(attrs, span.Attributes()...)
+	attrs = append(attrs, extra...)
+	return &attributeOverrideSpan{
+		ReadOnlySpan: span,
+		attrs:        attrs,
+	}
+}
+
+// untruncatedKeys identify what a span describes, so they are kept whole
+// when the span is truncated
+var untruncatedKeys = map[attribute.Key]bool{
+	LLMProviderKey:      true,
+	LLMModelKey:         true,
+	LLMOperationTypeKey: true,
+}
+
+// truncateSpan shortens the largest string attributes of a span and its
+// events until its estimated size fits within maxBytes
+func truncateSpan(span sdktrace.ReadOnlySpan, maxBytes int) sdktrace.ReadOnlySpan {
+	excess := estimateSpanSize(span) - maxBytes
+	if excess <= 0 {
+		return span
+	}
+
+	attrs := make([]attribute.KeyValue, len(span.Attributes()), len(span.Attributes())+1)
+	copy(attrs, span.Attributes())
+	events := make([]sdktrace.Event, len(span.Events()))
+	for i, event := range span.Events() {
+		event.Attributes = append([]attribute.KeyValue(nil), event.Attributes...)
+		events[i] = event
+	}
+
+	// Truncate content first: the largest string values give back the most,
+	// wherever they are
+	var values []*attribute.KeyValue
+	collect := func(kvs []attribute.KeyValue) {
+		for i := range kvs {
+			if kvs[i].Value.Type() == attribute.STRING && !untruncatedKeys[kvs[i].Key] {
+				values = append(values, &kvs[i])
+			}
+		}
+	}
+	collect(attrs)
+	for i := range events {
+		collect(events[i].Attributes)
+	}
+	sort.SliceStable(values, func(a, b int) bool {
+		return len(values[a].Value.AsString()) > len(values[b].Value.AsString())
+	})
+
+	for _, kv := range values {
+		if excess <= 0 {
+			break
+		}
+		value := kv.Value.AsString()
+		keep := len(value) - excess
+		if keep < 0 {
+			keep = 0
+		}
+		for keep > 0 && !utf8.RuneStart(value[keep]) {
+			keep--
+		}
+		*kv = attribute.String(string(kv.Key), value[:keep])
+		excess -= len(value) - keep
+	}
+
+	attrs = append(attrs, attribute.Bool(UntraceSpanTruncatedKey, true))
+
+	return &attributeOverrideSpan{
+		ReadOnlySpan: span,
+		attrs:        attrs,
+		events:       events,
+	}
+}
+
+// estimateSpanSize estimates the serialized size of a span in bytes
+func estimateSpanSize(span sdktrace.ReadOnlySpan) int {
+	size := len(span.Name())
+	for _, attr := range span.Attributes() {
+		size += len(attr.Key) + estimateValueSize(attr.Value)
+	}
+	for _, event := range span.Events() {
+		size += len(event.Name)
+		for _, attr := range event.Attributes {
+			size += len(attr.Key) + estimateValueSize(attr.Value)
+		}
+	}
+	return size
+}
+
+// estimateValueSize estimates the serialized size of an attribute value
+func estimateValueSize(value attribute.Value) int {
+	switch value.Type() {
+	case attribute.STRING:
+		return len(value.AsString())
+	case attribute.STRINGSLICE:
+		size := 0
+		for _, s := range value.AsStringSlice() {
+			size += len(s)
+		}
+		return size
+	case attribute.BOOLSLICE:
+		return len(value.AsBoolSlice())
+	case attribute.INT64SLICE:
+		return 8 * len(value.AsInt64Slice())
+	case attribute.FLOAT64SLICE:
+		return 8 * len(value.AsFloat64Slice())
+	default:
+		return 8
+	}
+}
+
 // CreateOTLPExporter creates an OTLP exporter configured for Untrace
 func CreateOTLPExporter(config Config) (otlptrace.Client, error) {
 	// Create HTTP client with custom headers
-	client := otlptracehttp.NewClient(
-		otlptracehttp.WithEndpoint(config.BaseURL),
+	opts := []otlptracehttp.Option{
 		otlptracehttp.WithHeaders(map[string]string{
-			"Authorization": "Bearer " + config.APIKey,
-			"User-Agent":    "untrace-sdk-go/0.1.0",
+			"Authorization":     "Bearer " + config.APIKey,
+			"User-Agent":        userAgent,
+			schemaVersionHeader: PayloadSchemaVersion,
 		}),
-	)
+	}
+	if endpoint, ok := parseOTLPEndpoint(config.BaseURL); ok {
+		opts = append(opts,
+			otlptracehttp.WithEndpoint(endpoint.Host),
+			otlptracehttp.WithURLPath(endpoint.Path+"/v1/traces"),
+		)
+		if endpoint.Scheme == "http" {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+	}
+
+	return otlptracehttp.NewClient(opts...), nil
+}
+
+// CreateOTLPMetricExporter creates an OTLP metric exporter configured for
+// Untrace, using the configured metric temporality
+func CreateOTLPMetricExporter(ctx context.Context, config Config) (sdkmetric.Exporter, error) {
+	opts := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithHeaders(map[string]string{
+			"Authorization":     "Bearer " + config.APIKey,
+			"User-Agent":        userAgent,
+			schemaVersionHeader: PayloadSchemaVersion,
+		}),
+		otlpmetrichttp.WithTemporalitySelector(TemporalitySelector(config.MetricTemporality)),
+	}
+	if endpoint, ok := parseOTLPEndpoint(config.BaseURL); ok {
+		opts = append(opts,
+			otlpmetrichttp.WithEndpoint(endpoint.Host),
+			otlpmetrichttp.WithURLPath(endpoint.Path+"/v1/metrics"),
+		)
+		if endpoint.Scheme == "http" {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+	}
+
+	return otlpmetrichttp.New(ctx, opts...)
+}
 
-	return client, nil
+// parseOTLPEndpoint splits a base URL into the scheme, host and path prefix
+// the OTLP exporters take separately. It reports false for an empty or
+// invalid URL, leaving the exporters on their defaults.
+func parseOTLPEndpoint(baseURL string) (*url.URL, bool) {
+	if baseURL == "" {
+		return nil, false
+	}
+	normalized, err := normalizeBaseURL(baseURL)
+	if err != nil {
+		return nil, false
+	}
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return nil, false
+	}
+	return u, true
+}
+
+// otlpURL returns the full URL of an OTLP path under the base URL
+func otlpURL(baseURL, path string) string {
+	if normalized, err := normalizeBaseURL(baseURL); err == nil {
+		baseURL = normalized
+	}
+	return baseURL + path
+}
+
+// TemporalitySelector returns the metric temporality selector for the given
+// temporality. Delta applies to counters and histograms; up-down counters stay
+// cumulative since their deltas are not meaningful to most backends.
+func TemporalitySelector(temporality MetricTemporality) sdkmetric.TemporalitySelector {
+	if temporality != MetricTemporalityDelta {
+		return sdkmetric.DefaultTemporalitySelector
+	}
+
+	return func(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+		switch kind {
+		case sdkmetric.InstrumentKindUpDownCounter, sdkmetric.InstrumentKindObservableUpDownCounter:
+			return metricdata.CumulativeTemporality
+		default:
+			return metricdata.DeltaTemporality
+		}
+	}
 }
 
 // CreateResource creates an OpenTelemetry resource for Untrace
@@ -153,6 +602,9 @@ func CreateResource(config Config) *resource.Resource {
 		semconv.ServiceNameKey.String(config.ServiceName),
 		semconv.ServiceVersionKey.String(config.Version),
 		semconv.DeploymentEnvironmentKey.String(config.Environment),
+		semconv.TelemetrySDKNameKey.String("untrace"),
+		semconv.TelemetrySDKLanguageGo,
+		semconv.TelemetrySDKVersionKey.String(SDKVersion),
 	}
 
 	// Add custom resource attributes
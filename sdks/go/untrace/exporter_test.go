@@ -0,0 +1,721 @@
+package untrace
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMaxSpanBytes(t *testing.T) {
+	const maxBytes = 1024
+	longModel := strings.Repeat("m", 600)
+
+	tests := []struct {
+		name          string
+		attrs         []attribute.KeyValue
+		eventAttrs    []attribute.KeyValue
+		wantTruncated bool
+		wantWhole     map[string]string
+	}{
+		{
+			name:  "small span",
+			attrs: []attribute.KeyValue{attribute.String(LLMPromptKey, "hello")},
+			wantWhole: map[string]string{
+				LLMPromptKey: "hello",
+			},
+		},
+		{
+			name: "oversized attribute",
+			attrs: []attribute.KeyValue{
+				attribute.String(LLMPromptKey, strings.Repeat("p", 4000)),
+				attribute.String(LLMModelKey, "gpt-4"),
+			},
+			wantTruncated: true,
+			wantWhole:     map[string]string{LLMModelKey: "gpt-4"},
+		},
+		{
+			name:          "oversized event attribute",
+			eventAttrs:    []attribute.KeyValue{attribute.String("output", strings.Repeat("ü", 2000))},
+			wantTruncated: true,
+		},
+		{
+			name: "identity kept whole",
+			attrs: []attribute.KeyValue{
+				attribute.String(LLMModelKey, longModel),
+				attribute.String(LLMCompletionKey, strings.Repeat("c", 3000)),
+			},
+			wantTruncated: true,
+			wantWhole:     map[string]string{LLMModelKey: longModel},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.MaxSpanBytes = maxBytes
+			})
+
+			_, span := client.Tracer().StartSpan(context.Background(), "llm.call", SpanOptions{})
+			span.SetAttributes(tt.attrs...)
+			if len(tt.eventAttrs) > 0 {
+				span.AddEvent("llm.output", trace.WithAttributes(tt.eventAttrs...))
+			}
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.call")
+
+			truncated, _ := attrValue(got.Attributes, UntraceSpanTruncatedKey)
+			if truncated.AsBool() != tt.wantTruncated {
+				t.Errorf("%s = %v, want %v", UntraceSpanTruncatedKey, truncated.AsBool(), tt.wantTruncated)
+			}
+			if tt.wantTruncated {
+				marker := len(UntraceSpanTruncatedKey) + estimateValueSize(attribute.BoolValue(true))
+				if size := estimateSpanSize(got.Snapshot()) - marker; size > maxBytes {
+					t.Errorf("truncated span is %d bytes, want at most %d", size, maxBytes)
+				}
+			}
+			for key, want := range tt.wantWhole {
+				if value, _ := attrValue(got.Attributes, key); value.AsString() != want {
+					t.Errorf("%s was truncated to %d bytes, want it whole", key, len(value.AsString()))
+				}
+			}
+			for _, event := range got.Events {
+				for _, attr := range event.Attributes {
+					if !utf8.ValidString(attr.Value.AsString()) {
+						t.Errorf("event attribute %s was cut mid-rune", attr.Key)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMetricTemporality(t *testing.T) {
+	tests := []struct {
+		name        string
+		temporality MetricTemporality
+		want        map[sdkmetric.InstrumentKind]metricdata.Temporality
+	}{
+		{
+			name:        "cumulative",
+			temporality: MetricTemporalityCumulative,
+			want: map[sdkmetric.InstrumentKind]metricdata.Temporality{
+				sdkmetric.InstrumentKindCounter:       metricdata.CumulativeTemporality,
+				sdkmetric.InstrumentKindHistogram:     metricdata.CumulativeTemporality,
+				sdkmetric.InstrumentKindUpDownCounter: metricdata.CumulativeTemporality,
+			},
+		},
+		{
+			name:        "delta",
+			temporality: MetricTemporalityDelta,
+			want: map[sdkmetric.InstrumentKind]metricdata.Temporality{
+				sdkmetric.InstrumentKindCounter:       metricdata.DeltaTemporality,
+				sdkmetric.InstrumentKindHistogram:     metricdata.DeltaTemporality,
+				sdkmetric.InstrumentKindUpDownCounter: metricdata.CumulativeTemporality,
+			},
+		},
+		{
+			name: "unset",
+			want: map[sdkmetric.InstrumentKind]metricdata.Temporality{
+				sdkmetric.InstrumentKindCounter:   metricdata.CumulativeTemporality,
+				sdkmetric.InstrumentKindHistogram: metricdata.CumulativeTemporality,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig("test-key")
+			config.MetricTemporality = tt.temporality
+
+			exporter, err := CreateOTLPMetricExporter(context.Background(), config)
+			if err != nil {
+				t.Fatalf("CreateOTLPMetricExporter() error = %v", err)
+			}
+			defer exporter.Shutdown(context.Background())
+
+			for kind, want := range tt.want {
+				if got := exporter.Temporality(kind); got != want {
+					t.Errorf("Temporality(%v) = %v, want %v", kind, got, want)
+				}
+			}
+		})
+	}
+}
+
+// failingExporter fails every export with err
+type failingExporter struct {
+	err error
+}
+
+// ExportSpans fails
+func (e failingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return e.err
+}
+
+// Shutdown does nothing
+func (e failingExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func TestExportErrorTraceIDs(t *testing.T) {
+	tests := []struct {
+		name   string
+		traces int
+		want   int
+	}{
+		{name: "one trace", traces: 1, want: 1},
+		{name: "several traces", traces: 3, want: 3},
+		{name: "capped sample", traces: maxReportedTraceIDs + 3, want: maxReportedTraceIDs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cause := errors.New("connection refused")
+			var reported []*ExportError
+			client, _ := newTestClient(t, func(c *Config) {
+				c.SpanExporter = failingExporter{err: cause}
+				c.OnExportError = func(err *ExportError) { reported = append(reported, err) }
+			})
+
+			traceIDs := make(map[string]bool)
+			for i := 0; i < tt.traces; i++ {
+				_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{})
+				traceIDs[span.SpanContext().TraceID().String()] = true
+				span.End()
+			}
+
+			if err := client.Flush(context.Background()); !errors.Is(err, cause) {
+				t.Fatalf("Flush() error = %v, want it to wrap %v", err, cause)
+			}
+			if len(reported) != 1 {
+				t.Fatalf("got %d export error reports, want 1", len(reported))
+			}
+
+			got := reported[0]
+			if !errors.Is(got, cause) {
+				t.Errorf("reported error %v does not wrap %v", got, cause)
+			}
+			if len(got.TraceIDs) != tt.want {
+				t.Fatalf("reported %d trace IDs, want %d", len(got.TraceIDs), tt.want)
+			}
+			for _, id := range got.TraceIDs {
+				if !traceIDs[id] {
+					t.Errorf("reported trace ID %s is not from the batch", id)
+				}
+				if !strings.Contains(got.Error(), id) {
+					t.Errorf("error message %q lacks trace ID %s", got.Error(), id)
+				}
+			}
+		})
+	}
+}
+
+// toggleExporter fails exports while failing is set
+type toggleExporter struct {
+	failing atomic.Bool
+}
+
+// ExportSpans fails while the exporter is toggled to failing
+func (e *toggleExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if e.failing.Load() {
+		return errors.New("export failed")
+	}
+	return nil
+}
+
+// Shutdown does nothing
+func (e *toggleExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func TestExporterUpGauge(t *testing.T) {
+	// Each step exports one span, failing or not, then reads the gauge
+	tests := []struct {
+		name  string
+		steps []bool
+		want  []int64
+	}{
+		{name: "healthy", steps: []bool{false, false}, want: []int64{1, 1}},
+		{name: "goes down", steps: []bool{false, true}, want: []int64{1, 0}},
+		{name: "recovers", steps: []bool{true, true, false}, want: []int64{0, 0, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := &toggleExporter{}
+			client, _ := newTestClient(t, func(c *Config) {
+				c.SpanExporter = exporter
+				c.ExporterUpGauge = true
+			})
+
+			if got := gaugeValue(t, client, "untrace.exporter.up"); got != 1 {
+				t.Errorf("before any export: gauge = %d, want 1", got)
+			}
+
+			for i, failing := range tt.steps {
+				exporter.failing.Store(failing)
+				_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{})
+				span.End()
+				_ = client.Flush(context.Background())
+
+				if got := gaugeValue(t, client, "untrace.exporter.up"); got != tt.want[i] {
+					t.Errorf("step %d: gauge = %d, want %d", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// gaugeValue reads the single value of an int64 gauge from the client
+func gaugeValue(t *testing.T, client *untraceClient, name string) int64 {
+	t.Helper()
+
+	m := collectMetric(t, client.snapshotReader, name)
+	gauge, ok := m.Data.(metricdata.Gauge[int64])
+	if !ok || len(gauge.DataPoints) != 1 {
+		t.Fatalf("metric %q is %#v, want one int64 gauge point", name, m.Data)
+	}
+	return gauge.DataPoints[0].Value
+}
+
+func TestDryRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		spans int
+	}{
+		{name: "one span", spans: 1},
+		{name: "batch", spans: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+			}))
+			defer server.Close()
+
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			var exported []int
+			client, _ := newTestClient(t, func(c *Config) {
+				c.SpanExporter = nil
+				c.BaseURL = server.URL
+				c.DryRun = true
+				c.Debug = true
+				c.OnExport = func(spans int, err error) {
+					if err != nil {
+						t.Errorf("OnExport() error = %v", err)
+					}
+					exported = append(exported, spans)
+				}
+			})
+
+			for i := 0; i < tt.spans; i++ {
+				_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{})
+				span.End()
+			}
+			if err := client.Flush(context.Background()); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			if len(exported) != 1 || exported[0] != tt.spans {
+				t.Errorf("OnExport saw batches %v, want [%d]", exported, tt.spans)
+			}
+			if want := fmt.Sprintf("Dry run: would export %d spans", tt.spans); !strings.Contains(logs.String(), want) {
+				t.Errorf("logs lack %q:\n%s", want, logs.String())
+			}
+			if n := requests.Load(); n != 0 {
+				t.Errorf("server got %d requests, want none", n)
+			}
+		})
+	}
+}
+
+func TestSDKVersionReporting(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  ExportFormat
+		version string
+	}{
+		{name: "otlp proto", format: ExportFormatOTLPProto, version: "2.3.1"},
+		{name: "untrace json", format: ExportFormatJSON, version: "2.3.1"},
+		{name: "otlp json without a service version", format: ExportFormatOTLPJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userAgents := make(chan string, 10)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case userAgents <- r.Header.Get("User-Agent"):
+				default:
+				}
+			}))
+			// Closed after the client's cleanup has flushed its metrics
+			t.Cleanup(server.Close)
+
+			client, _ := newTestClient(t, func(c *Config) {
+				c.SpanExporter = nil
+				c.BaseURL = server.URL
+				c.ExportFormat = tt.format
+				c.Version = tt.version
+			})
+
+			_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{})
+			span.End()
+			if err := client.Flush(context.Background()); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			select {
+			case ua := <-userAgents:
+				if want := "untrace-sdk-go/" + SDKVersion; !strings.HasPrefix(ua, want) {
+					t.Errorf("User-Agent = %q, want %q", ua, want)
+				}
+			default:
+				t.Fatal("no export request received")
+			}
+
+			attrs := CreateResource(client.config).Set()
+			want := map[attribute.Key]string{
+				"service.version":        tt.version,
+				"telemetry.sdk.name":     "untrace",
+				"telemetry.sdk.language": "go",
+				"telemetry.sdk.version":  SDKVersion,
+			}
+			for key, want := range want {
+				if got, _ := attrs.Value(key); got.AsString() != want {
+					t.Errorf("%s = %q, want %q", key, got.AsString(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaVersionHeader(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      ExportFormat
+		accept      string
+		wantWarning bool
+	}{
+		{name: "otlp proto", format: ExportFormatOTLPProto},
+		{name: "untrace json", format: ExportFormatJSON},
+		{name: "otlp json", format: ExportFormatOTLPJSON},
+		{name: "server accepts the current schema", format: ExportFormatJSON, accept: PayloadSchemaVersion},
+		{name: "server prefers an older schema", format: ExportFormatJSON, accept: "0", wantWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions := make(chan string, 10)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/v1/traces") || r.URL.Path == "/" {
+					select {
+					case versions <- r.Header.Get(schemaVersionHeader):
+					default:
+					}
+				}
+				if tt.accept != "" {
+					w.Header().Set(acceptSchemaVersionHeader, tt.accept)
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+			client, _ := newTestClient(t, func(c *Config) {
+				c.SpanExporter = nil
+				c.BaseURL = server.URL
+				c.ExportFormat = tt.format
+			})
+
+			// Two exports, so a downgrade warning shows up only once
+			for i := 0; i < 2; i++ {
+				_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{})
+				span.End()
+				if err := client.Flush(context.Background()); err != nil {
+					t.Fatalf("Flush() error = %v", err)
+				}
+			}
+
+			select {
+			case version := <-versions:
+				if version != PayloadSchemaVersion {
+					t.Errorf("%s = %q, want %q", schemaVersionHeader, version, PayloadSchemaVersion)
+				}
+			default:
+				t.Fatal("no span export request received")
+			}
+
+			warnings := strings.Count(logs.String(), "server accepts payload schema version")
+			if want := map[bool]int{true: 1}[tt.wantWarning]; warnings != want {
+				t.Errorf("logged %d schema warnings, want %d:\n%s", warnings, want, logs.String())
+			}
+		})
+	}
+}
+
+func TestCompressionThreshold(t *testing.T) {
+	tests := []struct {
+		name           string
+		threshold      int
+		spans          int
+		wantCompressed bool
+	}{
+		{name: "small payload under the default", spans: 1},
+		{name: "large payload over the default", spans: 20, wantCompressed: true},
+		{name: "low threshold", threshold: 10, spans: 1, wantCompressed: true},
+		{name: "compression disabled", threshold: -1, spans: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			type request struct {
+				encoding string
+				body     []byte
+			}
+			requests := make(chan request, 10)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/traces" {
+					return
+				}
+				body, _ := io.ReadAll(r.Body)
+				requests <- request{encoding: r.Header.Get("Content-Encoding"), body: body}
+			}))
+			t.Cleanup(server.Close)
+
+			client, _ := newTestClient(t, func(c *Config) {
+				c.SpanExporter = nil
+				c.BaseURL = server.URL
+				c.ExportFormat = ExportFormatJSON
+				c.CompressionThreshold = tt.threshold
+			})
+
+			for i := 0; i < tt.spans; i++ {
+				_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{})
+				span.End()
+			}
+			if err := client.Flush(context.Background()); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			var req request
+			select {
+			case req = <-requests:
+			default:
+				t.Fatal("no export request received")
+			}
+
+			if compressed := req.encoding == "gzip"; compressed != tt.wantCompressed {
+				t.Fatalf("Content-Encoding = %q, want compressed: %v", req.encoding, tt.wantCompressed)
+			}
+			body := req.body
+			if tt.wantCompressed {
+				reader, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("body is not gzip: %v", err)
+				}
+				if body, err = io.ReadAll(reader); err != nil {
+					t.Fatalf("failed to decompress body: %v", err)
+				}
+			}
+
+			var payload struct {
+				Spans []json.RawMessage `json:"spans"`
+			}
+			if err := json.Unmarshal(body, &payload); err != nil {
+				t.Fatalf("payload is not JSON: %v", err)
+			}
+			if len(payload.Spans) != tt.spans {
+				t.Errorf("payload has %d spans, want %d", len(payload.Spans), tt.spans)
+			}
+		})
+	}
+}
+
+func TestExportSpansHonorsContext(t *testing.T) {
+	tests := []struct {
+		name         string
+		ctx          func() (context.Context, context.CancelFunc)
+		serverDelay  time.Duration
+		wantErr      error
+		wantRequests int64
+	}{
+		{
+			name:         "no deadline",
+			ctx:          func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			wantRequests: 1,
+		},
+		{
+			name: "already cancelled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+		{
+			name: "deadline shorter than the server",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			serverDelay:  time.Second,
+			wantErr:      context.DeadlineExceeded,
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int64
+			release := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				select {
+				case <-time.After(tt.serverDelay):
+				case <-release:
+				}
+			}))
+			defer server.Close()
+			defer close(release)
+
+			config := DefaultConfig("test-key")
+			config.BaseURL = server.URL
+			config.ExportFormat = ExportFormatJSON
+			exporter, err := NewUntraceExporter(config)
+			if err != nil {
+				t.Fatalf("NewUntraceExporter() error = %v", err)
+			}
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			start := time.Now()
+			err = exporter.ExportSpans(ctx, tracetest.SpanStubs{{Name: "work"}}.Snapshots())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExportSpans() error = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); tt.serverDelay > 0 && elapsed >= tt.serverDelay {
+				t.Errorf("ExportSpans() took %s, past the caller's deadline", elapsed)
+			}
+			if n := requests.Load(); n != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestOTLPExporterEndpoint(t *testing.T) {
+	exportTraces := func(ctx context.Context, config Config) error {
+		client, err := CreateOTLPExporter(config)
+		if err != nil {
+			return err
+		}
+		exporter, err := otlptrace.New(ctx, client)
+		if err != nil {
+			return err
+		}
+		defer exporter.Shutdown(ctx)
+		return exporter.ExportSpans(ctx, tracetest.SpanStubs{{Name: "work"}}.Snapshots())
+	}
+
+	tests := []struct {
+		name     string
+		prefix   string
+		export   func(ctx context.Context, config Config) error
+		wantPath string
+	}{
+		{
+			name:     "traces",
+			export:   exportTraces,
+			wantPath: "/v1/traces",
+		},
+		{
+			name:     "traces under a path prefix",
+			prefix:   "/otlp/",
+			export:   exportTraces,
+			wantPath: "/otlp/v1/traces",
+		},
+		{
+			name: "metrics",
+			export: func(ctx context.Context, config Config) error {
+				exporter, err := CreateOTLPMetricExporter(ctx, config)
+				if err != nil {
+					return err
+				}
+				defer exporter.Shutdown(ctx)
+				return exporter.Export(ctx, &metricdata.ResourceMetrics{
+					ScopeMetrics: []metricdata.ScopeMetrics{{
+						Metrics: []metricdata.Metrics{{
+							Name: "llm.requests",
+							Data: metricdata.Sum[int64]{
+								Temporality: metricdata.CumulativeTemporality,
+								IsMonotonic: true,
+								DataPoints:  []metricdata.DataPoint[int64]{{Value: 1}},
+							},
+						}},
+					}},
+				})
+			},
+			wantPath: "/v1/metrics",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath, gotAuth atomic.Value
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath.Store(r.URL.Path)
+				gotAuth.Store(r.Header.Get("Authorization"))
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			// A plain http URL, as for a local collector, must not be sent TLS
+			config := DefaultConfig("test-key")
+			config.BaseURL = server.URL + tt.prefix
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tt.export(ctx, config); err != nil {
+				t.Fatalf("export error = %v", err)
+			}
+
+			if path, _ := gotPath.Load().(string); path != tt.wantPath {
+				t.Errorf("request path = %q, want %q", path, tt.wantPath)
+			}
+			if auth, _ := gotAuth.Load().(string); auth != "Bearer test-key" {
+				t.Errorf("Authorization = %q, want %q", auth, "Bearer test-key")
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package untrace
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTestClient initializes the SDK with spans exported to memory, applying
+// configure to the config first. The client is shut down and the SDK state
+// reset when the test ends.
+func newTestClient(t *testing.T, configure func(*Config), opts ...Option) (*untraceClient, *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	config := DefaultConfig("test-key")
+	config.SpanExporter = exporter
+	if configure != nil {
+		configure(&config)
+	}
+
+	client, err := InitWithOptions(config, opts...)
+	if err != nil {
+		t.Fatalf("InitWithOptions() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if err := client.Shutdown(context.Background()); err != nil {
+			t.Errorf("Shutdown() error = %v", err)
+		}
+		if err := Reset(); err != nil {
+			t.Errorf("Reset() error = %v", err)
+		}
+	})
+
+	return client.(*untraceClient), exporter
+}
+
+// exportedSpans flushes the client and returns the spans exported so far
+func exportedSpans(t *testing.T, client Client, exporter *tracetest.InMemoryExporter) tracetest.SpanStubs {
+	t.Helper()
+
+	if err := client.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	return exporter.GetSpans()
+}
+
+// findSpan returns the exported span with the given name
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("span %q not exported; got %v", name, spanNames(spans))
+	return tracetest.SpanStub{}
+}
+
+// spanNames returns the names of the spans, for failure messages
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	return names
+}
+
+// attrValue returns the value of the attribute with the given key
+func attrValue(attrs []attribute.KeyValue, key string) (attribute.Value, bool) {
+	for _, attr := range attrs {
+		if string(attr.Key) == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// newTestMetrics creates metrics for the config recorded by a manual reader
+func newTestMetrics(t *testing.T, config Config) (*untraceMetrics, *sdkmetric.ManualReader) {
+	t.Helper()
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithView(latencyView(config.LatencyBuckets)),
+	)
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	metrics, err := newMetrics(provider.Meter("test"), config)
+	if err != nil {
+		t.Fatalf("newMetrics() error = %v", err)
+	}
+	return metrics, reader
+}
+
+// collectMetric collects the reader and returns the metric with the given
+// name, failing the test if it was not recorded
+func collectMetric(t *testing.T, reader sdkmetric.Reader, name string) metricdata.Metrics {
+	t.Helper()
+
+	m, ok := findMetric(t, reader, name)
+	if !ok {
+		t.Fatalf("metric %q not recorded", name)
+	}
+	return m
+}
+
+// findMetric collects the reader and returns the metric with the given name
+func findMetric(t *testing.T, reader sdkmetric.Reader, name string) (metricdata.Metrics, bool) {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name == name {
+				return m, true
+			}
+		}
+	}
+	return metricdata.Metrics{}, false
+}
+
+// sumPoints returns the data points of a sum metric of either number type,
+// as float64 values
+func sumPoints(t *testing.T, m metricdata.Metrics) []metricdata.DataPoint[float64] {
+	t.Helper()
+
+	switch data := m.Data.(type) {
+	case metricdata.Sum[float64]:
+		return data.DataPoints
+	case metricdata.Sum[int64]:
+		points := make([]metricdata.DataPoint[float64], len(data.DataPoints))
+		for i, dp := range data.DataPoints {
+			points[i] = metricdata.DataPoint[float64]{Attributes: dp.Attributes, Value: float64(dp.Value)}
+		}
+		return points
+	default:
+		t.Fatalf("metric %q is a %T, not a sum", m.Name, m.Data)
+		return nil
+	}
+}
+
+// sumValue returns the total of a sum metric across its data points
+func sumValue(t *testing.T, m metricdata.Metrics) float64 {
+	t.Helper()
+
+	var total float64
+	for _, dp := range sumPoints(t, m) {
+		total += dp.Value
+	}
+	return total
+}
+
+// memoryMetricExporter keeps the metrics exported to it in memory, failing
+// exports while err is set
+type memoryMetricExporter struct {
+	mu       sync.Mutex
+	exported []metricdata.ResourceMetrics
+	err      error
+}
+
+// Temporality uses the default temporality
+func (e *memoryMetricExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
+
+// Aggregation uses the default aggregation
+func (e *memoryMetricExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+// Export records the metrics
+func (e *memoryMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.err != nil {
+		return e.err
+	}
+	e.exported = append(e.exported, *rm)
+	return nil
+}
+
+// ForceFlush does nothing
+func (e *memoryMetricExporter) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// Shutdown does nothing
+func (e *memoryMetricExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// setErr makes exports fail with err, or succeed again if it is nil
+func (e *memoryMetricExporter) setErr(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.err = err
+}
+
+// metricNames returns the names of the metrics exported so far
+func (e *memoryMetricExporter) metricNames() map[string]bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	names := make(map[string]bool)
+	for _, rm := range e.exported {
+		for _, scope := range rm.ScopeMetrics {
+			for _, m := range scope.Metrics {
+				names[m.Name] = true
+			}
+		}
+	}
+	return names
+}
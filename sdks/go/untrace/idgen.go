@@ -0,0 +1,52 @@
+package untrace
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// seededIDGenerator generates trace and span IDs from a seeded pseudo-random
+// source, so a run with the same seed produces the same IDs
+type seededIDGenerator struct {
+	mu     sync.Mutex
+	source *rand.Rand
+}
+
+// NewSeededIDGenerator returns an ID generator producing the same sequence of
+// trace and span IDs for the same seed. Since sampling is decided by trace ID,
+// a run with the same seed and spans makes the same sampling decisions, which
+// makes sampling reproducible in tests. Do not use it in production.
+func NewSeededIDGenerator(seed int64) sdktrace.IDGenerator {
+	return &seededIDGenerator{source: rand.New(rand.NewSource(seed))}
+}
+
+// NewIDs returns a new trace ID and span ID
+func (g *seededIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var traceID trace.TraceID
+	binary.BigEndian.PutUint64(traceID[:8], g.source.Uint64())
+	binary.BigEndian.PutUint64(traceID[8:], g.source.Uint64())
+	return traceID, g.newSpanID()
+}
+
+// NewSpanID returns a new span ID for a span in the given trace
+func (g *seededIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.newSpanID()
+}
+
+// newSpanID returns a new span ID; the caller must hold g.mu
+func (g *seededIDGenerator) newSpanID() trace.SpanID {
+	var spanID trace.SpanID
+	binary.BigEndian.PutUint64(spanID[:], g.source.Uint64())
+	return spanID
+}
@@ -2,13 +2,18 @@ package untrace
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -18,15 +23,33 @@ type InstrumentationConfig struct {
 	CaptureBody bool
 	CaptureArgs bool
 	MaxBodySize int
+
+	// RetryBackoff is the wait before the second attempt in TraceWithRetry,
+	// doubled for every attempt after it. Zero retries immediately
+	RetryBackoff time.Duration
+
+	// EmbeddingItemSampleRate is the fraction of items in TraceEmbeddingBatch
+	// that get their own child span. Zero records only the aggregate span
+	EmbeddingItemSampleRate float64
+
+	// AutoCost makes TraceLLMCall record token usage and, when the model has
+	// pricing, cost metrics once the call returns, from the LLMResult fn
+	// reported with SetLLMResult or else the token counts in its options.
+	// Usage and cost reported with SetLLMResult are recorded exactly once, so
+	// report them that way rather than recording them yourself, or turn
+	// AutoCost off. Defaults to true
+	AutoCost bool
 }
 
 // DefaultInstrumentationConfig returns default instrumentation configuration
 func DefaultInstrumentationConfig() InstrumentationConfig {
 	return InstrumentationConfig{
-		Enabled:     true,
-		CaptureBody: true,
-		CaptureArgs: false,
-		MaxBodySize: 1024 * 1024, // 1MB
+		Enabled:      true,
+		CaptureBody:  true,
+		CaptureArgs:  false,
+		MaxBodySize:  1024 * 1024, // 1MB
+		RetryBackoff: 100 * time.Millisecond,
+		AutoCost:     true,
 	}
 }
 
@@ -60,19 +83,133 @@ func (i *Instrumentation) TraceFunction(ctx context.Context, name string, fn fun
 	duration := time.Since(start)
 
 	// Record metrics
-	if err != nil {
-		i.client.Metrics().RecordError(err, map[string]interface{}{
-			"function": name,
-		})
-	} else {
-		i.client.Metrics().RecordLatency(duration, map[string]interface{}{
-			"function": name,
-		})
-	}
+	i.recordOutcome(ctx, err, duration, map[string]interface{}{
+		"function": name,
+	})
 
 	return err
 }
 
+// TraceFunctionWithArgs traces a function call like TraceFunction and, if
+// CaptureArgs is set, records its arguments as function.arg.N attributes.
+// Structs and maps are captured as JSON with the values of sensitive field
+// names and keys redacted, other arguments with SafeString; credential-looking
+// values are redacted and everything is truncated to MaxBodySize
+func (i *Instrumentation) TraceFunctionWithArgs(ctx context.Context, name string, args []interface{}, fn func(context.Context) error, attrs ...attribute.KeyValue) error {
+	if i.config.CaptureArgs {
+		for n, arg := range args {
+			attrs = append(attrs, attribute.String(fmt.Sprintf("%s.%d", FunctionArgKeyPrefix, n), i.captureArg(arg)))
+		}
+	}
+	return i.TraceFunction(ctx, name, fn, attrs...)
+}
+
+// maxArgDepth bounds how deep captured arguments are walked, guarding
+// against cycles
+const maxArgDepth = 8
+
+// captureArg renders a function argument for capture
+func (i *Instrumentation) captureArg(arg interface{}) string {
+	value := sanitizeArg(reflect.ValueOf(arg), 0)
+
+	var rendered string
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "[REDACTED]"
+		}
+		rendered = string(data)
+	default:
+		rendered = SafeString(value)
+	}
+
+	if isSensitiveValue(rendered) {
+		return "[REDACTED]"
+	}
+	return i.truncateBody(rendered)
+}
+
+// sanitizeArg converts structs and maps to maps keyed by field name, with
+// the values of sensitive names redacted, so they can be rendered without
+// leaking credentials. Other values are returned as they are
+func sanitizeArg(v reflect.Value, depth int) interface{} {
+	if v.IsValid() && v.CanInterface() {
+		if err, ok := v.Interface().(error); ok && !IsNil(err) {
+			return err.Error()
+		}
+	}
+
+	orig := v
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	if depth >= maxArgDepth {
+		return fmt.Sprintf("[%s]", v.Type())
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := make(map[string]interface{})
+		for n := 0; n < v.NumField(); n++ {
+			field := v.Type().Field(n)
+			if !field.IsExported() {
+				continue
+			}
+			fields[field.Name] = sanitizeField(field.Name, v.Field(n), depth)
+		}
+		// Opaque structs such as time.Time render through their String method
+		if len(fields) == 0 && orig.CanInterface() {
+			return SafeString(orig.Interface())
+		}
+		return fields
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		entries := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			entries[key] = sanitizeField(key, iter.Value(), depth)
+		}
+		return entries
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		items := make([]interface{}, v.Len())
+		for n := range items {
+			items[n] = sanitizeArg(v.Index(n), depth+1)
+		}
+		return items
+	}
+
+	if !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// sanitizeField sanitizes a named struct field or map entry, redacting it
+// if its name or value is sensitive
+func sanitizeField(name string, v reflect.Value, depth int) interface{} {
+	if isSensitiveKey(name) {
+		return "[REDACTED]"
+	}
+	value := sanitizeArg(v, depth+1)
+	if isSensitiveValue(value) {
+		return "[REDACTED]"
+	}
+	return value
+}
+
 // TraceLLMCall traces an LLM call
 func (i *Instrumentation) TraceLLMCall(ctx context.Context, name string, opts LLMSpanOptions, fn func(context.Context) error) error {
 	if !i.config.Enabled {
@@ -81,28 +218,477 @@ func (i *Instrumentation) TraceLLMCall(ctx context.Context, name string, opts LL
 
 	ctx, span := i.client.Tracer().StartLLMSpan(ctx, name, opts)
 	defer span.End()
+	i.recordQueueWait(ctx, opts)
+
+	inFlight := i.client.Metrics().WithContext(ctx)
+	inFlight.RecordInFlightLLMCalls(1)
+	defer inFlight.RecordInFlightLLMCalls(-1)
+
+	// Messages StartLLMSpan recorded under CaptureContent aren't recorded again
+	recorder, _ := i.client.Tracer().(messageRecorder)
+	startCaptured := recorder != nil && recorder.capturesContent()
+	if i.config.CaptureBody && !startCaptured {
+		i.recordBody(span, opts.Messages, nil)
+	}
+
+	// Collect the result fn reports with SetLLMResult
+	collect := awaitLLMResult(span)
 
 	start := time.Now()
 	err := fn(ctx)
 	duration := time.Since(start)
 
+	result, reported := collect()
+	if i.config.CaptureBody {
+		output := result.OutputMessages
+		if len(output) == 0 && !startCaptured {
+			output = opts.OutputMessages
+		}
+		i.recordBody(span, nil, output)
+	}
+
 	// Update span with duration
 	opts.DurationMs = int(duration.Milliseconds())
 
 	// Record metrics
-	if err != nil {
-		i.client.Metrics().RecordError(err, map[string]interface{}{
-			"provider": opts.Provider,
-			"model":    opts.Model,
-			"operation": string(opts.Operation),
-		})
+	i.recordOutcome(ctx, err, duration, map[string]interface{}{
+		"provider": opts.Provider,
+		"model":    opts.Model,
+		"operation": string(opts.Operation),
+	})
+	if i.config.AutoCost {
+		i.recordUsage(ctx, span, opts, result, reported)
+	}
+
+	return err
+}
+
+// messageRecorder is implemented by tracers that lay captured messages out
+// according to their attribute convention
+type messageRecorder interface {
+	recordMessages(span trace.Span, input, output []Message, transform func(string) string)
+	capturesContent() bool
+}
+
+// recordBody records captured LLM messages truncated to MaxBodySize, laid
+// out like CaptureContent when the tracer supports it
+func (i *Instrumentation) recordBody(span trace.Span, input, output []Message) {
+	if recorder, ok := i.client.Tracer().(messageRecorder); ok {
+		recorder.recordMessages(span, input, output, i.truncateBody)
+		return
+	}
+
+	if len(input) > 0 {
+		span.SetAttributes(attribute.String(LLMPromptKey, i.truncateBody(marshalMessages(input))))
+	}
+	if len(output) > 0 {
+		span.SetAttributes(attribute.String(LLMCompletionKey, i.truncateBody(marshalMessages(output))))
+	}
+}
+
+// recordUsage records the token usage of a call and its cost, taken from the
+// result fn reported, else the cost set in opts, else computed from the
+// pricing table. A computed cost is added to the span
+func (i *Instrumentation) recordUsage(ctx context.Context, span trace.Span, opts LLMSpanOptions, result LLMResult, reported bool) {
+	usage := TokenUsage{Model: opts.Model, Provider: opts.Provider}
+	if reported && result.PromptTokens+result.CompletionTokens+result.ReasoningTokens > 0 {
+		usage.PromptTokens = result.PromptTokens
+		usage.CompletionTokens = result.CompletionTokens
+		usage.ReasoningTokens = result.ReasoningTokens
+		usage.TotalTokens = result.TotalTokens
+		if usage.TotalTokens == 0 {
+			usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens + usage.ReasoningTokens
+		}
 	} else {
-		i.client.Metrics().RecordLatency(duration, map[string]interface{}{
-			"provider": opts.Provider,
-			"model":    opts.Model,
-			"operation": string(opts.Operation),
+		usage = usageFromOptions(opts)
+	}
+	if usage.PromptTokens+usage.CompletionTokens+usage.ReasoningTokens == 0 {
+		return
+	}
+
+	metrics := i.client.Metrics().WithContext(ctx)
+	metrics.RecordTokenUsage(usage)
+
+	if reported && result.Cost != nil {
+		metrics.RecordCost(*result.Cost)
+		return
+	}
+	if opts.CostTotal != nil {
+		metrics.RecordCost(costFromOptions(opts))
+		return
+	}
+
+	cost, err := CalculateCost(opts.Provider, opts.Model, usage)
+	if err != nil {
+		return
+	}
+	metrics.RecordCost(cost)
+	span.SetAttributes(CreateCostAttributes(cost)...)
+}
+
+// costFromOptions returns the cost the caller set in LLM span options
+func costFromOptions(opts LLMSpanOptions) Cost {
+	cost := Cost{Model: opts.Model, Provider: opts.Provider, Currency: "USD"}
+	if opts.CostPrompt != nil {
+		cost.Prompt = *opts.CostPrompt
+	}
+	if opts.CostCompletion != nil {
+		cost.Completion = *opts.CostCompletion
+	}
+	if opts.CostTotal != nil {
+		cost.Total = *opts.CostTotal
+	}
+	return cost
+}
+
+// usageFromOptions returns the token usage carried in LLM span options
+func usageFromOptions(opts LLMSpanOptions) TokenUsage {
+	usage := TokenUsage{Model: opts.Model, Provider: opts.Provider}
+	if opts.PromptTokens != nil {
+		usage.PromptTokens = *opts.PromptTokens
+	}
+	if opts.CompletionTokens != nil {
+		usage.CompletionTokens = *opts.CompletionTokens
+	}
+	if opts.ReasoningTokens != nil {
+		usage.ReasoningTokens = *opts.ReasoningTokens
+	}
+	if opts.TotalTokens != nil {
+		usage.TotalTokens = *opts.TotalTokens
+	}
+	return usage
+}
+
+// TraceCachedResponse records a response served from an application cache
+// without calling the LLM, as a zero-cost LLM span tagged llm.cache.hit. If
+// the options carry token counts and the model has pricing, the cost the
+// cache avoided is recorded as llm.cache.saved_cost.
+func (i *Instrumentation) TraceCachedResponse(ctx context.Context, opts LLMSpanOptions) {
+	if !i.config.Enabled {
+		return
+	}
+
+	zero := 0.0
+	opts.CostTotal = &zero
+
+	ctx, span := i.client.Tracer().StartLLMSpan(ctx, "llm.cache_hit", opts)
+	defer span.End()
+
+	span.SetAttributes(attribute.Bool(LLMCacheHitKey, true))
+
+	usage := TokenUsage{Model: opts.Model, Provider: opts.Provider}
+	if opts.PromptTokens != nil {
+		usage.PromptTokens = *opts.PromptTokens
+	}
+	if opts.CompletionTokens != nil {
+		usage.CompletionTokens = *opts.CompletionTokens
+	}
+	if usage.PromptTokens+usage.CompletionTokens > 0 {
+		if saved, err := CalculateCost(opts.Provider, opts.Model, usage); err == nil {
+			span.SetAttributes(attribute.Float64(LLMCacheSavedCostKey, saved.Total))
+		}
+	}
+
+	i.client.Metrics().WithContext(ctx).RecordCacheHit(map[string]interface{}{
+		"provider": opts.Provider,
+		"model":    opts.Model,
+	})
+}
+
+// recordQueueWait records the queue wait the application measured for an
+// LLM call, if any
+func (i *Instrumentation) recordQueueWait(ctx context.Context, opts LLMSpanOptions) {
+	if opts.QueueWaitMs == nil {
+		return
+	}
+
+	wait := time.Duration(*opts.QueueWaitMs) * time.Millisecond
+	i.client.Metrics().WithContext(ctx).RecordQueueWait(wait, map[string]interface{}{
+		"provider": opts.Provider,
+		"model":    opts.Model,
+	})
+}
+
+// TraceToolExecution traces the execution of a tool requested by an LLM. The
+// span in ctx is treated as the LLM span that requested the tool and is linked
+// from the tool span.
+func (i *Instrumentation) TraceToolExecution(ctx context.Context, toolName string, input interface{}, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	if !i.config.Enabled {
+		return fn(ctx)
+	}
+
+	startOpts := []trace.SpanStartOption{
+		trace.WithAttributes(
+			attribute.String(FrameworkToolNameKey, toolName),
+			attribute.String(FrameworkOperationKey, "tool_execution"),
+		),
+	}
+	if llmSpan := trace.SpanContextFromContext(ctx); llmSpan.IsValid() {
+		startOpts = append(startOpts, trace.WithLinks(trace.Link{SpanContext: llmSpan}))
+	}
+
+	ctx, span := i.client.Tracer().GetTracer().Start(ctx, fmt.Sprintf("tool.%s", toolName), startOpts...)
+	defer span.End()
+
+	if i.config.CaptureBody {
+		span.SetAttributes(attribute.String(FrameworkToolInputKey, i.truncateBody(captureValue(input))))
+	}
+
+	start := time.Now()
+	output, err := fn(ctx)
+	duration := time.Since(start)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if i.config.CaptureBody {
+		span.SetAttributes(attribute.String(FrameworkToolOutputKey, i.truncateBody(captureValue(output))))
+	}
+
+	// Record metrics
+	i.recordOutcome(ctx, err, duration, map[string]interface{}{
+		FrameworkToolNameKey: toolName,
+	})
+
+	return output, err
+}
+
+// TraceWithRetry runs fn up to maxAttempts times, wrapping each attempt in a
+// child span of a parent span for the whole loop. Attempts stop early on
+// success or when shouldRetry returns false; the final error is returned.
+func (i *Instrumentation) TraceWithRetry(ctx context.Context, name string, maxAttempts int, fn func(ctx context.Context, attempt int) error, shouldRetry func(error) bool) error {
+	if !i.config.Enabled {
+		return retryUntraced(ctx, maxAttempts, fn, shouldRetry)
+	}
+
+	ctx, span := i.client.Tracer().StartSpan(ctx, name, SpanOptions{})
+	defer span.End()
+
+	return i.retry(ctx, span, name, maxAttempts, fn, shouldRetry, map[string]interface{}{
+		"function": name,
+	})
+}
+
+// TraceLLMCallWithRetry retries an LLM call like TraceWithRetry, with an LLM
+// span for the whole loop, so retry metrics are labeled by provider and model
+func (i *Instrumentation) TraceLLMCallWithRetry(ctx context.Context, name string, opts LLMSpanOptions, maxAttempts int, fn func(ctx context.Context, attempt int) error, shouldRetry func(error) bool) error {
+	if !i.config.Enabled {
+		return retryUntraced(ctx, maxAttempts, fn, shouldRetry)
+	}
+
+	ctx, span := i.client.Tracer().StartLLMSpan(ctx, name, opts)
+	defer span.End()
+
+	return i.retry(ctx, span, name, maxAttempts, fn, shouldRetry, map[string]interface{}{
+		"provider":  opts.Provider,
+		"model":     opts.Model,
+		"operation": string(opts.Operation),
+	})
+}
+
+// retryUntraced runs the retry loop without tracing or backoff
+func retryUntraced(ctx context.Context, maxAttempts int, fn func(ctx context.Context, attempt int) error, shouldRetry func(error) bool) error {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = fn(ctx, attempt); err == nil || (shouldRetry != nil && !shouldRetry(err)) {
+			return err
+		}
+	}
+	return err
+}
+
+// retry runs the retry loop under span, recording the outcome and retry
+// metrics with the given labels
+func (i *Instrumentation) retry(ctx context.Context, span trace.Span, name string, maxAttempts int, fn func(ctx context.Context, attempt int) error, shouldRetry func(error) bool, labels map[string]interface{}) error {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	start := time.Now()
+	backoff := i.config.RetryBackoff
+	var err error
+	attempts := 0
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		var waited time.Duration
+		if attempt > 1 && backoff > 0 {
+			if waitErr := sleepContext(ctx, backoff); waitErr != nil {
+				err = waitErr
+				break
+			}
+			waited = backoff
+			backoff *= 2
+		}
+
+		attempts = attempt
+		err = i.traceAttempt(ctx, name, attempt, waited, fn)
+		if err == nil || (shouldRetry != nil && !shouldRetry(err)) {
+			break
+		}
+	}
+
+	span.SetAttributes(attribute.Int(RetryAttemptsKey, attempts))
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	// Record metrics
+	i.recordOutcome(ctx, err, time.Since(start), labels)
+	if attempts > 0 {
+		i.client.Metrics().WithContext(ctx).RecordRetries(attempts, err == nil, labels)
+	}
+
+	return err
+}
+
+// traceAttempt runs a single retry attempt in its own span
+func (i *Instrumentation) traceAttempt(ctx context.Context, name string, attempt int, backoff time.Duration, fn func(ctx context.Context, attempt int) error) error {
+	attrs := []attribute.KeyValue{attribute.Int(RetryAttemptKey, attempt)}
+	if attempt > 1 {
+		attrs = append(attrs, attribute.Int64(RetryBackoffMsKey, backoff.Milliseconds()))
+	}
+
+	ctx, span := i.client.Tracer().GetTracer().Start(ctx, fmt.Sprintf("%s.attempt", name), trace.WithAttributes(attrs...))
+	defer span.End()
+
+	err := fn(ctx, attempt)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// recordOutcome records the error and latency metrics of a traced operation.
+// Latency of an operation whose context was cancelled is labeled
+// cancelled=true so it doesn't skew latency percentiles.
+func (i *Instrumentation) recordOutcome(ctx context.Context, err error, duration time.Duration, attrs map[string]interface{}) {
+	metrics := i.client.Metrics().WithContext(ctx)
+
+	if err != nil {
+		metrics.RecordError(err, attrs)
+	}
+
+	if ctx.Err() != nil {
+		metrics.RecordLatency(duration, MergeAttributes(attrs, map[string]interface{}{
+			"cancelled": true,
+		}))
+	} else if err == nil {
+		metrics.RecordLatency(duration, attrs)
+	}
+}
+
+// sleepContext waits for the given duration or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// embeddingBatchKey is the context key for the current embedding batch
+type embeddingBatchKey struct{}
+
+// EmbeddingBatch aggregates the items of a bulk embedding job traced by
+// TraceEmbeddingBatch
+type EmbeddingBatch struct {
+	instrumentation *Instrumentation
+	tokens          atomic.Int64
+	sampleEvery     int
+}
+
+// EmbeddingBatchFromContext returns the embedding batch traced in ctx, or nil
+func EmbeddingBatchFromContext(ctx context.Context) *EmbeddingBatch {
+	batch, _ := ctx.Value(embeddingBatchKey{}).(*EmbeddingBatch)
+	return batch
+}
+
+// AddTokens adds to the batch's aggregate token usage
+func (b *EmbeddingBatch) AddTokens(tokens int) {
+	b.tokens.Add(int64(tokens))
+}
+
+// TraceItem runs fn for the item at index, in its own child span only when
+// the item is sampled
+func (b *EmbeddingBatch) TraceItem(ctx context.Context, index int, fn func(context.Context) error) error {
+	if b.sampleEvery == 0 || index%b.sampleEvery != 0 {
+		return fn(ctx)
+	}
+
+	ctx, span := b.instrumentation.client.Tracer().StartSpan(ctx, "embedding.item", SpanOptions{
+		Attributes: map[string]interface{}{
+			"embedding.item.index": index,
+		},
+	})
+	defer span.End()
+
+	err := fn(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// TraceEmbeddingBatch traces a bulk embedding job as a single span carrying
+// the item count and the aggregate token usage reported through the
+// EmbeddingBatch in fn's context. Per-item spans are only created for items
+// sampled by EmbeddingItemSampleRate.
+func (i *Instrumentation) TraceEmbeddingBatch(ctx context.Context, opts EmbeddingSpanOptions, itemCount int, fn func(context.Context) error) error {
+	batch := &EmbeddingBatch{instrumentation: i}
+	if rate := i.config.EmbeddingItemSampleRate; rate > 0 {
+		batch.sampleEvery = int(math.Max(1, math.Round(1/rate)))
+	}
+
+	if !i.config.Enabled {
+		batch.sampleEvery = 0
+		return fn(context.WithValue(ctx, embeddingBatchKey{}, batch))
+	}
+
+	opts.InputCount = itemCount
+	ctx, span := i.client.Tracer().StartEmbeddingSpan(ctx, "", opts)
+	defer span.End()
+
+	start := time.Now()
+	err := fn(context.WithValue(ctx, embeddingBatchKey{}, batch))
+	duration := time.Since(start)
+
+	tokens := int(batch.tokens.Load())
+	if tokens > 0 {
+		span.SetAttributes(
+			attribute.Int(LLMPromptTokensKey, tokens),
+			attribute.Int(LLMTotalTokensKey, tokens),
+		)
+		i.client.Metrics().WithContext(ctx).RecordTokenUsage(TokenUsage{
+			PromptTokens: tokens,
+			TotalTokens:  tokens,
+			Model:        opts.Model,
+			Provider:     opts.Provider,
 		})
 	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	// Record metrics
+	i.recordOutcome(ctx, err, duration, map[string]interface{}{
+		"provider":  opts.Provider,
+		"model":     opts.Model,
+		"operation": string(LLMOperationEmbedding),
+	})
 
 	return err
 }
@@ -128,21 +714,37 @@ func (i *Instrumentation) TraceHTTPRequest(ctx context.Context, method, url stri
 	duration := time.Since(start)
 
 	// Record metrics
-	if err != nil {
-		i.client.Metrics().RecordError(err, map[string]interface{}{
-			"http.method": method,
-			"http.url":    url,
-		})
-	} else {
-		i.client.Metrics().RecordLatency(duration, map[string]interface{}{
-			"http.method": method,
-			"http.url":    url,
-		})
-	}
+	i.recordOutcome(ctx, err, duration, map[string]interface{}{
+		"http.method": method,
+		"http.url":    url,
+	})
 
 	return err
 }
 
+// TraceHTTPRequestWithBody traces an HTTP request like TraceHTTPRequest and,
+// if CaptureBody is set, records the request body and the response body
+// returned by fn, truncated to MaxBodySize
+func (i *Instrumentation) TraceHTTPRequestWithBody(ctx context.Context, method, url string, body []byte, fn func(context.Context) ([]byte, error)) error {
+	if !i.config.Enabled {
+		_, err := fn(ctx)
+		return err
+	}
+
+	return i.TraceHTTPRequest(ctx, method, url, func(ctx context.Context) error {
+		span := trace.SpanFromContext(ctx)
+		if i.config.CaptureBody && len(body) > 0 {
+			span.SetAttributes(attribute.String(HTTPRequestBodyKey, i.truncateBody(string(body))))
+		}
+
+		response, err := fn(ctx)
+		if i.config.CaptureBody && len(response) > 0 {
+			span.SetAttributes(attribute.String(HTTPResponseBodyKey, i.truncateBody(string(response))))
+		}
+		return err
+	})
+}
+
 // TraceDatabaseQuery traces a database query
 func (i *Instrumentation) TraceDatabaseQuery(ctx context.Context, operation, table string, fn func(context.Context) error) error {
 	if !i.config.Enabled {
@@ -164,28 +766,68 @@ func (i *Instrumentation) TraceDatabaseQuery(ctx context.Context, operation, tab
 	duration := time.Since(start)
 
 	// Record metrics
+	i.recordOutcome(ctx, err, duration, map[string]interface{}{
+		"db.operation": operation,
+		"db.table":     table,
+	})
+
+	return err
+}
+
+// TraceVectorQuery traces a vector DB similarity query. fn returns the scores
+// of the returned results, which are summarized on the span.
+func (i *Instrumentation) TraceVectorQuery(ctx context.Context, system, metric string, k int, fn func(context.Context) ([]float64, error)) error {
+	if !i.config.Enabled {
+		_, err := fn(ctx)
+		return err
+	}
+
+	attrs := map[string]interface{}{
+		DBSystemKey:     system,
+		DBOperationKey:  "query",
+		VectorQueryKKey: k,
+	}
+
+	ctx, span := i.client.Tracer().StartSpan(ctx, fmt.Sprintf("%s.query", system), SpanOptions{
+		Attributes: attrs,
+	})
+	defer span.End()
+
+	start := time.Now()
+	scores, err := fn(ctx)
+	duration := time.Since(start)
+
 	if err != nil {
-		i.client.Metrics().RecordError(err, map[string]interface{}{
-			"db.operation": operation,
-			"db.table":     table,
-		})
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	} else {
-		i.client.Metrics().RecordLatency(duration, map[string]interface{}{
-			"db.operation": operation,
-			"db.table":     table,
-		})
+		span.SetAttributes(CreateVectorQueryAttributes(metric, scores)...)
 	}
 
+	// Record metrics
+	i.recordOutcome(ctx, err, duration, map[string]interface{}{
+		DBSystemKey:    system,
+		DBOperationKey: "query",
+	})
+
 	return err
 }
 
+// RecordRAGContext records on the retrieval span in ctx how much context was
+// injected into the subsequent prompt. Call it from the function passed to
+// TraceVectorQuery once the context is assembled; pass a negative token
+// count if it is unknown.
+func RecordRAGContext(ctx context.Context, chars, tokens int) {
+	trace.SpanFromContext(ctx).SetAttributes(CreateRAGContextAttributes(chars, tokens)...)
+}
+
 // TraceWorkflow traces a workflow execution
 func (i *Instrumentation) TraceWorkflow(ctx context.Context, name, runID string, opts WorkflowOptions, fn func(context.Context) error) error {
 	if !i.config.Enabled {
 		return fn(ctx)
 	}
 
-	workflow := i.client.Context().StartWorkflow(name, runID, opts)
+	workflow := i.client.Context().StartWorkflowContext(ctx, name, runID, opts)
 	defer workflow.End()
 
 	// Add workflow context to the function context
@@ -195,19 +837,18 @@ func (i *Instrumentation) TraceWorkflow(ctx context.Context, name, runID string,
 	err := fn(workflowCtx)
 	duration := time.Since(start)
 
-	// Record metrics
 	if err != nil {
-		i.client.Metrics().RecordError(err, map[string]interface{}{
-			"workflow.name": name,
-			"workflow.run_id": runID,
-		})
-	} else {
-		i.client.Metrics().RecordLatency(duration, map[string]interface{}{
-			"workflow.name": name,
-			"workflow.run_id": runID,
-		})
+		span := trace.SpanFromContext(workflowCtx)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	}
 
+	// Record metrics
+	i.recordOutcome(workflowCtx, err, duration, map[string]interface{}{
+		"workflow.name": name,
+		"workflow.run_id": runID,
+	})
+
 	return err
 }
 
@@ -242,6 +883,40 @@ func (i *Instrumentation) attributesToMap(attrs []attribute.KeyValue) map[string
 	return result
 }
 
+// captureValue renders a captured value as a string with sensitive map keys
+// redacted
+func captureValue(value interface{}) string {
+	if attrs, ok := value.(map[string]interface{}); ok {
+		value = SanitizeAttributes(attrs)
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return SafeString(value)
+	}
+	return string(data)
+}
+
+// truncateBody limits a captured body to MaxBodySize bytes, replacing binary
+// content with a placeholder noting its size
+func (i *Instrumentation) truncateBody(body string) string {
+	if !utf8.ValidString(body) {
+		return fmt.Sprintf("[binary %d bytes]", len(body))
+	}
+	if i.config.MaxBodySize > 0 && len(body) > i.config.MaxBodySize {
+		// Drop any rune cut in half by the truncation
+		return strings.ToValidUTF8(TruncateString(body, i.config.MaxBodySize), "")
+	}
+	return body
+}
+
 // GetFunctionName gets the name of the calling function
 func GetFunctionName() string {
 	pc, _, _, _ := runtime.Caller(1)
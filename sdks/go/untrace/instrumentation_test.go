@@ -0,0 +1,942 @@
+package untrace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceToolExecution(t *testing.T) {
+	tests := []struct {
+		name       string
+		fromLLM    bool
+		err        error
+		wantOutput string
+	}{
+		{name: "called by an LLM span", fromLLM: true, wantOutput: `{"temp":21}`},
+		{name: "tool error", fromLLM: true, err: errors.New("weather API down")},
+		{name: "no LLM span", wantOutput: `{"temp":21}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			instr := NewInstrumentation(client, DefaultInstrumentationConfig())
+
+			ctx := context.Background()
+			var llmSpanID string
+			if tt.fromLLM {
+				var span trace.Span
+				ctx, span = client.Tracer().StartLLMSpan(ctx, "llm.chat", LLMSpanOptions{
+					Provider: "openai",
+					Model:    "gpt-4",
+				})
+				defer span.End()
+				llmSpanID = span.SpanContext().SpanID().String()
+			}
+
+			_, err := instr.TraceToolExecution(ctx, "get_weather", map[string]string{"city": "Paris"},
+				func(context.Context) (interface{}, error) {
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return map[string]int{"temp": 21}, nil
+				})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("TraceToolExecution() error = %v, want %v", err, tt.err)
+			}
+
+			tool := findSpan(t, exportedSpans(t, client, exporter), "tool.get_weather")
+
+			if name, _ := attrValue(tool.Attributes, FrameworkToolNameKey); name.AsString() != "get_weather" {
+				t.Errorf("%s = %q, want get_weather", FrameworkToolNameKey, name.AsString())
+			}
+			if op, _ := attrValue(tool.Attributes, FrameworkOperationKey); op.AsString() != "tool_execution" {
+				t.Errorf("%s = %q, want tool_execution", FrameworkOperationKey, op.AsString())
+			}
+			if input, _ := attrValue(tool.Attributes, FrameworkToolInputKey); input.AsString() != `{"city":"Paris"}` {
+				t.Errorf("%s = %q", FrameworkToolInputKey, input.AsString())
+			}
+			output, _ := attrValue(tool.Attributes, FrameworkToolOutputKey)
+			if output.AsString() != tt.wantOutput {
+				t.Errorf("%s = %q, want %q", FrameworkToolOutputKey, output.AsString(), tt.wantOutput)
+			}
+			wantCode := codes.Unset
+			if tt.err != nil {
+				wantCode = codes.Error
+			}
+			if tool.Status.Code != wantCode {
+				t.Errorf("status = %v, want %v", tool.Status.Code, wantCode)
+			}
+
+			if !tt.fromLLM {
+				if len(tool.Links) != 0 {
+					t.Errorf("got %d links, want none", len(tool.Links))
+				}
+				return
+			}
+			if len(tool.Links) != 1 || tool.Links[0].SpanContext.SpanID().String() != llmSpanID {
+				t.Errorf("links = %v, want one to the LLM span %s", tool.Links, llmSpanID)
+			}
+		})
+	}
+}
+
+func TestTraceWithRetry(t *testing.T) {
+	errTransient := errors.New("rate limited")
+	errFatal := errors.New("invalid request")
+
+	tests := []struct {
+		name         string
+		failures     []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{name: "first attempt succeeds", wantAttempts: 1},
+		{name: "fails twice then succeeds", failures: []error{errTransient, errTransient}, wantAttempts: 3},
+		{name: "non-retryable error", failures: []error{errFatal}, wantAttempts: 1, wantErr: errFatal},
+		{name: "every attempt fails", failures: []error{errTransient, errTransient, errTransient, errTransient}, wantAttempts: 3, wantErr: errTransient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			config := DefaultInstrumentationConfig()
+			config.RetryBackoff = 0
+			instr := NewInstrumentation(client, config)
+
+			err := instr.TraceWithRetry(context.Background(), "fetch", 3,
+				func(ctx context.Context, attempt int) error {
+					if attempt <= len(tt.failures) {
+						return tt.failures[attempt-1]
+					}
+					return nil
+				},
+				func(err error) bool { return errors.Is(err, errTransient) })
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TraceWithRetry() error = %v, want %v", err, tt.wantErr)
+			}
+
+			spans := exportedSpans(t, client, exporter)
+			parent := findSpan(t, spans, "fetch")
+
+			var attempts []int64
+			for _, span := range spans {
+				if span.Name != "fetch.attempt" {
+					continue
+				}
+				if span.Parent.SpanID() != parent.SpanContext.SpanID() {
+					t.Errorf("attempt span is not a child of the retry span")
+				}
+				attempt, _ := attrValue(span.Attributes, RetryAttemptKey)
+				attempts = append(attempts, attempt.AsInt64())
+			}
+			if len(attempts) != tt.wantAttempts {
+				t.Fatalf("got %d attempt spans, want %d", len(attempts), tt.wantAttempts)
+			}
+			for i, attempt := range attempts {
+				if attempt != int64(i+1) {
+					t.Errorf("attempt span %d has %s = %d", i, RetryAttemptKey, attempt)
+				}
+			}
+
+			if total, _ := attrValue(parent.Attributes, RetryAttemptsKey); total.AsInt64() != int64(tt.wantAttempts) {
+				t.Errorf("%s = %d, want %d", RetryAttemptsKey, total.AsInt64(), tt.wantAttempts)
+			}
+			wantCode := codes.Unset
+			if tt.wantErr != nil {
+				wantCode = codes.Error
+			}
+			if parent.Status.Code != wantCode {
+				t.Errorf("parent status = %v, want %v", parent.Status.Code, wantCode)
+			}
+		})
+	}
+}
+
+func TestTraceVectorQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		scores    []float64
+		wantTop   float64
+		wantMin   float64
+		wantCount int64
+	}{
+		{name: "several results", scores: []float64{0.82, 0.95, 0.61}, wantTop: 0.95, wantMin: 0.61, wantCount: 3},
+		{name: "one result", scores: []float64{0.7}, wantTop: 0.7, wantMin: 0.7, wantCount: 1},
+		{name: "no results"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			instr := NewInstrumentation(client, DefaultInstrumentationConfig())
+
+			err := instr.TraceVectorQuery(context.Background(), "pinecone", "cosine", 5,
+				func(context.Context) ([]float64, error) { return tt.scores, nil })
+			if err != nil {
+				t.Fatalf("TraceVectorQuery() error = %v", err)
+			}
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "pinecone.query")
+			if metric, _ := attrValue(got.Attributes, VectorQueryMetricKey); metric.AsString() != "cosine" {
+				t.Errorf("%s = %q, want cosine", VectorQueryMetricKey, metric.AsString())
+			}
+			if k, _ := attrValue(got.Attributes, VectorQueryKKey); k.AsInt64() != 5 {
+				t.Errorf("%s = %d, want 5", VectorQueryKKey, k.AsInt64())
+			}
+			if count, _ := attrValue(got.Attributes, VectorResultsCountKey); count.AsInt64() != tt.wantCount {
+				t.Errorf("%s = %d, want %d", VectorResultsCountKey, count.AsInt64(), tt.wantCount)
+			}
+
+			top, hasTop := attrValue(got.Attributes, VectorResultsTopScoreKey)
+			lowest, hasMin := attrValue(got.Attributes, VectorResultsMinScoreKey)
+			if len(tt.scores) == 0 {
+				if hasTop || hasMin {
+					t.Errorf("score summary set without results")
+				}
+				return
+			}
+			if top.AsFloat64() != tt.wantTop || lowest.AsFloat64() != tt.wantMin {
+				t.Errorf("top/min score = %v/%v, want %v/%v", top.AsFloat64(), lowest.AsFloat64(), tt.wantTop, tt.wantMin)
+			}
+		})
+	}
+}
+
+func TestTraceFunctionCancelledLatency(t *testing.T) {
+	tests := []struct {
+		name          string
+		cancel        bool
+		err           error
+		wantLatency   bool
+		wantCancelled bool
+	}{
+		{name: "completed", wantLatency: true},
+		{name: "cancelled mid-call", cancel: true, err: context.Canceled, wantLatency: true, wantCancelled: true},
+		{name: "failed", err: errors.New("boom")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTestClient(t, nil)
+			instr := NewInstrumentation(client, DefaultInstrumentationConfig())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			_ = instr.TraceFunction(ctx, "work", func(ctx context.Context) error {
+				if tt.cancel {
+					cancel()
+					<-ctx.Done()
+				}
+				return tt.err
+			})
+
+			m, ok := findMetric(t, client.snapshotReader, "llm.latency")
+			if !ok {
+				if tt.wantLatency {
+					t.Fatal("latency not recorded")
+				}
+				return
+			}
+			hist, _ := m.Data.(metricdata.Histogram[float64])
+			var count uint64
+			for _, dp := range hist.DataPoints {
+				count += dp.Count
+				cancelled, _ := dp.Attributes.Value("cancelled")
+				if cancelled.AsBool() != tt.wantCancelled {
+					t.Errorf("cancelled label = %v, want %v", cancelled.AsBool(), tt.wantCancelled)
+				}
+			}
+			if want := map[bool]uint64{true: 1}[tt.wantLatency]; count != want {
+				t.Errorf("recorded %d latencies, want %d", count, want)
+			}
+		})
+	}
+}
+
+func TestTraceEmbeddingBatch(t *testing.T) {
+	const items = 1000
+
+	tests := []struct {
+		name          string
+		sampleRate    float64
+		wantItemSpans int
+	}{
+		{name: "aggregate only", wantItemSpans: 0},
+		{name: "one percent of items", sampleRate: 0.01, wantItemSpans: 10},
+		{name: "every item", sampleRate: 1, wantItemSpans: items},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			config := DefaultInstrumentationConfig()
+			config.EmbeddingItemSampleRate = tt.sampleRate
+			instr := NewInstrumentation(client, config)
+
+			err := instr.TraceEmbeddingBatch(context.Background(), EmbeddingSpanOptions{
+				Provider: "openai",
+				Model:    "text-embedding-3-small",
+			}, items, func(ctx context.Context) error {
+				batch := EmbeddingBatchFromContext(ctx)
+				for n := 0; n < items; n++ {
+					_ = batch.TraceItem(ctx, n, func(context.Context) error {
+						batch.AddTokens(3)
+						return nil
+					})
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("TraceEmbeddingBatch() error = %v", err)
+			}
+
+			spans := exportedSpans(t, client, exporter)
+			aggregate := findSpan(t, spans, "embedding (batch=1000)")
+			if len(spans) != 1+tt.wantItemSpans {
+				t.Errorf("exported %d spans, want %d", len(spans), 1+tt.wantItemSpans)
+			}
+			if count, _ := attrValue(aggregate.Attributes, VectorCountKey); count.AsInt64() != items {
+				t.Errorf("%s = %d, want %d", VectorCountKey, count.AsInt64(), items)
+			}
+			if tokens, _ := attrValue(aggregate.Attributes, LLMTotalTokensKey); tokens.AsInt64() != 3*items {
+				t.Errorf("%s = %d, want %d", LLMTotalTokensKey, tokens.AsInt64(), 3*items)
+			}
+			for _, span := range spans {
+				if span.Name == "embedding.item" && span.Parent.SpanID() != aggregate.SpanContext.SpanID() {
+					t.Errorf("item span is not a child of the batch span")
+				}
+			}
+		})
+	}
+}
+
+func TestRecordRAGContext(t *testing.T) {
+	tests := []struct {
+		name       string
+		chars      int
+		tokens     int
+		wantTokens bool
+	}{
+		{name: "chars and tokens", chars: 4200, tokens: 1050, wantTokens: true},
+		{name: "unknown token count", chars: 4200, tokens: -1},
+		{name: "empty context", chars: 0, tokens: 0, wantTokens: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			instr := NewInstrumentation(client, DefaultInstrumentationConfig())
+
+			err := instr.TraceVectorQuery(context.Background(), "pinecone", "cosine", 5,
+				func(ctx context.Context) ([]float64, error) {
+					RecordRAGContext(ctx, tt.chars, tt.tokens)
+					return []float64{0.9}, nil
+				})
+			if err != nil {
+				t.Fatalf("TraceVectorQuery() error = %v", err)
+			}
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "pinecone.query")
+			if chars, _ := attrValue(got.Attributes, RAGContextCharsKey); chars.AsInt64() != int64(tt.chars) {
+				t.Errorf("%s = %d, want %d", RAGContextCharsKey, chars.AsInt64(), tt.chars)
+			}
+			tokens, ok := attrValue(got.Attributes, RAGContextTokensKey)
+			if ok != tt.wantTokens {
+				t.Fatalf("%s set = %v, want %v", RAGContextTokensKey, ok, tt.wantTokens)
+			}
+			if ok && tokens.AsInt64() != int64(tt.tokens) {
+				t.Errorf("%s = %d, want %d", RAGContextTokensKey, tokens.AsInt64(), tt.tokens)
+			}
+		})
+	}
+}
+
+func TestQueueWait(t *testing.T) {
+	wait := func(ms int) *int { return &ms }
+
+	tests := []struct {
+		name   string
+		waitMs *int
+	}{
+		{name: "queued call", waitMs: wait(250)},
+		{name: "no wait measured"},
+		{name: "zero wait", waitMs: wait(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			instr := NewInstrumentation(client, DefaultInstrumentationConfig())
+
+			err := instr.TraceLLMCall(context.Background(), "llm.chat", LLMSpanOptions{
+				Provider:    "openai",
+				Model:       "gpt-4",
+				QueueWaitMs: tt.waitMs,
+			}, func(context.Context) error { return nil })
+			if err != nil {
+				t.Fatalf("TraceLLMCall() error = %v", err)
+			}
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.chat")
+			value, ok := attrValue(got.Attributes, LLMQueueWaitMsKey)
+			m, recorded := findMetric(t, client.snapshotReader, "llm.queue.wait")
+			if tt.waitMs == nil {
+				if ok || recorded {
+					t.Errorf("queue wait recorded without a measurement")
+				}
+				return
+			}
+
+			if value.AsInt64() != int64(*tt.waitMs) {
+				t.Errorf("%s = %d, want %d", LLMQueueWaitMsKey, value.AsInt64(), *tt.waitMs)
+			}
+			hist, _ := m.Data.(metricdata.Histogram[float64])
+			if len(hist.DataPoints) != 1 || hist.DataPoints[0].Count != 1 {
+				t.Fatalf("llm.queue.wait data points = %+v, want one measurement", hist.DataPoints)
+			}
+			if sum, want := hist.DataPoints[0].Sum, float64(*tt.waitMs)/1000; sum != want {
+				t.Errorf("llm.queue.wait = %vs, want %vs", sum, want)
+			}
+
+			// The wait is not part of the call's latency
+			latency := collectMetric(t, client.snapshotReader, "llm.latency").Data.(metricdata.Histogram[float64])
+			if len(latency.DataPoints) != 1 {
+				t.Fatalf("llm.latency data points = %+v, want one measurement", latency.DataPoints)
+			}
+			if *tt.waitMs > 0 && latency.DataPoints[0].Sum >= float64(*tt.waitMs)/1000 {
+				t.Errorf("llm.latency = %vs includes the queue wait", latency.DataPoints[0].Sum)
+			}
+		})
+	}
+}
+
+func TestRetryMetrics(t *testing.T) {
+	errTransient := errors.New("rate limited")
+
+	tests := []struct {
+		name           string
+		llm            bool
+		failures       int
+		wantOutcome    string
+		wantRetries    int64
+		wantSucceeded  bool
+		wantLabelKey   string
+		wantLabelValue string
+	}{
+		{name: "first attempt", wantOutcome: "success", wantLabelKey: "function", wantLabelValue: "fetch"},
+		{name: "succeeded after retries", failures: 2, wantOutcome: "success", wantRetries: 2, wantSucceeded: true, wantLabelKey: "function", wantLabelValue: "fetch"},
+		{name: "retries exhausted", failures: 5, wantOutcome: "failure", wantRetries: 2, wantLabelKey: "function", wantLabelValue: "fetch"},
+		{name: "llm call", llm: true, failures: 1, wantOutcome: "success", wantRetries: 1, wantSucceeded: true, wantLabelKey: "model", wantLabelValue: "gpt-4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTestClient(t, nil)
+			config := DefaultInstrumentationConfig()
+			config.RetryBackoff = 0
+			instr := NewInstrumentation(client, config)
+
+			fn := func(ctx context.Context, attempt int) error {
+				if attempt <= tt.failures {
+					return errTransient
+				}
+				return nil
+			}
+			shouldRetry := func(err error) bool { return errors.Is(err, errTransient) }
+			if tt.llm {
+				_ = instr.TraceLLMCallWithRetry(context.Background(), "llm.chat", LLMSpanOptions{Provider: "openai", Model: "gpt-4"}, 3, fn, shouldRetry)
+			} else {
+				_ = instr.TraceWithRetry(context.Background(), "fetch", 3, fn, shouldRetry)
+			}
+
+			hist, _ := collectMetric(t, client.snapshotReader, "llm.retry.count").Data.(metricdata.Histogram[int64])
+			if len(hist.DataPoints) != 1 {
+				t.Fatalf("llm.retry.count data points = %+v, want one", hist.DataPoints)
+			}
+			dp := hist.DataPoints[0]
+			if dp.Sum != tt.wantRetries {
+				t.Errorf("llm.retry.count = %d, want %d", dp.Sum, tt.wantRetries)
+			}
+			if outcome, _ := dp.Attributes.Value("outcome"); outcome.AsString() != tt.wantOutcome {
+				t.Errorf("outcome = %q, want %q", outcome.AsString(), tt.wantOutcome)
+			}
+			if label, _ := dp.Attributes.Value(attribute.Key(tt.wantLabelKey)); label.AsString() != tt.wantLabelValue {
+				t.Errorf("label %s = %q, want %q", tt.wantLabelKey, label.AsString(), tt.wantLabelValue)
+			}
+
+			m, recorded := findMetric(t, client.snapshotReader, "llm.retry.succeeded_after")
+			if recorded != tt.wantSucceeded {
+				t.Fatalf("llm.retry.succeeded_after recorded = %v, want %v", recorded, tt.wantSucceeded)
+			}
+			if recorded {
+				points := sumPoints(t, m)
+				if attempts, _ := points[0].Attributes.Value("attempts"); attempts.AsInt64() != tt.wantRetries+1 {
+					t.Errorf("attempts label = %d, want %d", attempts.AsInt64(), tt.wantRetries+1)
+				}
+			}
+		})
+	}
+}
+
+func TestTraceCachedResponse(t *testing.T) {
+	tokens := func(n int) *int { return &n }
+
+	tests := []struct {
+		name      string
+		opts      LLMSpanOptions
+		disabled  bool
+		wantSaved float64
+		noSaved   bool
+	}{
+		{
+			name:      "priced model",
+			opts:      LLMSpanOptions{Provider: "openai", Model: "gpt-4", PromptTokens: tokens(1000), CompletionTokens: tokens(500)},
+			wantSaved: 0.06,
+		},
+		{name: "no token counts", opts: LLMSpanOptions{Provider: "openai", Model: "gpt-4"}, noSaved: true},
+		{
+			name:    "unpriced model",
+			opts:    LLMSpanOptions{Provider: "openai", Model: "gpt-unknown", PromptTokens: tokens(1000)},
+			noSaved: true,
+		},
+		{name: "instrumentation disabled", opts: LLMSpanOptions{Provider: "openai", Model: "gpt-4"}, disabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			config := DefaultInstrumentationConfig()
+			config.Enabled = !tt.disabled
+			instr := NewInstrumentation(client, config)
+
+			instr.TraceCachedResponse(context.Background(), tt.opts)
+
+			spans := exportedSpans(t, client, exporter)
+			hits, recorded := findMetric(t, client.snapshotReader, "llm.cache.hits")
+			if tt.disabled {
+				if len(spans) != 0 || recorded {
+					t.Errorf("disabled instrumentation exported %v, recorded hits = %v", spanNames(spans), recorded)
+				}
+				return
+			}
+
+			span := findSpan(t, spans, "llm.cache_hit")
+			if hit, _ := attrValue(span.Attributes, LLMCacheHitKey); !hit.AsBool() {
+				t.Errorf("%s not set", LLMCacheHitKey)
+			}
+			if cost, ok := attrValue(span.Attributes, LLMCostTotalKey); !ok || cost.AsFloat64() != 0 {
+				t.Errorf("%s = %v (set: %v), want 0", LLMCostTotalKey, cost.AsFloat64(), ok)
+			}
+			saved, ok := attrValue(span.Attributes, LLMCacheSavedCostKey)
+			if ok == tt.noSaved {
+				t.Fatalf("%s set = %v, want %v", LLMCacheSavedCostKey, ok, !tt.noSaved)
+			}
+			if ok && math.Abs(saved.AsFloat64()-tt.wantSaved) > 1e-9 {
+				t.Errorf("%s = %v, want %v", LLMCacheSavedCostKey, saved.AsFloat64(), tt.wantSaved)
+			}
+
+			if !recorded || sumValue(t, hits) != 1 {
+				t.Errorf("llm.cache.hits not recorded once")
+			}
+		})
+	}
+}
+
+// costValue returns a pointer to a cost, for LLMSpanOptions
+func costValue(cost float64) *float64 {
+	return &cost
+}
+
+func TestAutoCost(t *testing.T) {
+	tests := []struct {
+		name       string
+		disable    bool
+		model      string
+		opts       LLMSpanOptions
+		result     *LLMResult
+		wantTokens float64
+		wantCost   float64
+		wantAttr   bool
+	}{
+		{
+			name:       "usage from options by default",
+			model:      "gpt-4",
+			opts:       LLMSpanOptions{PromptTokens: tokenCount(1000), CompletionTokens: tokenCount(500)},
+			wantTokens: 1500,
+			wantCost:   0.06,
+			wantAttr:   true,
+		},
+		{
+			name:    "turned off",
+			disable: true,
+			model:   "gpt-4",
+			opts:    LLMSpanOptions{PromptTokens: tokenCount(1000), CompletionTokens: tokenCount(500)},
+		},
+		{
+			name:       "usage reported after the call",
+			model:      "gpt-4",
+			opts:       LLMSpanOptions{PromptTokens: tokenCount(1)},
+			result:     &LLMResult{PromptTokens: 2000, CompletionTokens: 1000},
+			wantTokens: 3000,
+			wantCost:   0.12,
+			wantAttr:   true,
+		},
+		{
+			name:       "cost reported after the call",
+			model:      "gpt-4",
+			result:     &LLMResult{PromptTokens: 100, CompletionTokens: 100, Cost: &Cost{Total: 0.5}},
+			wantTokens: 200,
+			wantCost:   0.5,
+			wantAttr:   true,
+		},
+		{
+			name:       "cost set in options",
+			model:      "gpt-4",
+			opts:       LLMSpanOptions{PromptTokens: tokenCount(1000), CostTotal: costValue(0.25)},
+			wantTokens: 1000,
+			wantCost:   0.25,
+			wantAttr:   true,
+		},
+		{
+			name:       "unpriced model",
+			model:      "gpt-unknown",
+			opts:       LLMSpanOptions{PromptTokens: tokenCount(1000)},
+			wantTokens: 1000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			config := DefaultInstrumentationConfig()
+			if tt.disable {
+				config.AutoCost = false
+			}
+			instr := NewInstrumentation(client, config)
+
+			tt.opts.Provider = "openai"
+			tt.opts.Model = tt.model
+			err := instr.TraceLLMCall(context.Background(), "llm.chat", tt.opts, func(ctx context.Context) error {
+				if tt.result != nil {
+					SetLLMResult(trace.SpanFromContext(ctx), *tt.result)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("TraceLLMCall() error = %v", err)
+			}
+
+			var tokens, cost float64
+			if m, ok := findMetric(t, client.snapshotReader, "llm.total.tokens"); ok {
+				tokens = sumValue(t, m)
+			}
+			if m, ok := findMetric(t, client.snapshotReader, "llm.cost.total"); ok {
+				cost = sumValue(t, m)
+			}
+			if tokens != tt.wantTokens {
+				t.Errorf("llm.total.tokens = %v, want %v", tokens, tt.wantTokens)
+			}
+			if math.Abs(cost-tt.wantCost) > 1e-9 {
+				t.Errorf("llm.cost.total = %v, want %v", cost, tt.wantCost)
+			}
+
+			span := findSpan(t, exportedSpans(t, client, exporter), "llm.chat")
+			attr, ok := attrValue(span.Attributes, LLMCostTotalKey)
+			if ok != tt.wantAttr || (ok && math.Abs(attr.AsFloat64()-tt.wantCost) > 1e-9) {
+				t.Errorf("%s = %v (set: %v), want %v (set: %v)", LLMCostTotalKey, attr.AsFloat64(), ok, tt.wantCost, tt.wantAttr)
+			}
+		})
+	}
+}
+
+func TestActiveCounts(t *testing.T) {
+	tests := []struct {
+		name       string
+		goroutines int
+	}{
+		{name: "one goroutine", goroutines: 1},
+		{name: "concurrent goroutines", goroutines: 32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTestClient(t, nil)
+			instr := NewInstrumentation(client, DefaultInstrumentationConfig())
+
+			var started, done sync.WaitGroup
+			release := make(chan struct{})
+			started.Add(tt.goroutines)
+			done.Add(tt.goroutines)
+			for i := 0; i < tt.goroutines; i++ {
+				go func(run string) {
+					defer done.Done()
+
+					workflow := client.Context().StartWorkflow("agent", run, WorkflowOptions{})
+					_ = instr.TraceLLMCall(workflow.Context(), "llm.chat", LLMSpanOptions{Provider: "openai", Model: "gpt-4"},
+						func(context.Context) error {
+							started.Done()
+							<-release
+							return nil
+						})
+					// Ending twice decrements once
+					workflow.End()
+					workflow.End()
+				}(fmt.Sprintf("run-%d", i))
+			}
+
+			started.Wait()
+			want := int64(tt.goroutines)
+			if workflows, calls := client.Metrics().ActiveCounts(); workflows != want || calls != want {
+				t.Errorf("ActiveCounts() while running = %d, %d; want %d, %d", workflows, calls, want, want)
+			}
+			close(release)
+			done.Wait()
+
+			if workflows, calls := client.Metrics().ActiveCounts(); workflows != 0 || calls != 0 {
+				t.Errorf("ActiveCounts() after finishing = %d, %d; want 0, 0", workflows, calls)
+			}
+			for _, name := range []string{"workflow.active", "llm.calls.in_flight"} {
+				if value := sumValue(t, collectMetric(t, client.snapshotReader, name)); value != 0 {
+					t.Errorf("%s = %v after finishing, want 0", name, value)
+				}
+			}
+		})
+	}
+}
+
+func TestCaptureHTTPBody(t *testing.T) {
+	large := strings.Repeat("a", 100)
+
+	tests := []struct {
+		name         string
+		capture      bool
+		maxBodySize  int
+		request      []byte
+		response     []byte
+		wantRequest  string
+		wantResponse string
+	}{
+		{name: "capture off", request: []byte(`{"q":"hi"}`), response: []byte(`{"a":"hello"}`)},
+		{
+			name:         "within the limit",
+			capture:      true,
+			maxBodySize:  64,
+			request:      []byte(`{"q":"hi"}`),
+			response:     []byte(`{"a":"hello"}`),
+			wantRequest:  `{"q":"hi"}`,
+			wantResponse: `{"a":"hello"}`,
+		},
+		{
+			name:         "larger than the limit",
+			capture:      true,
+			maxBodySize:  10,
+			request:      []byte(large),
+			response:     []byte(large),
+			wantRequest:  strings.Repeat("a", 10) + "...",
+			wantResponse: strings.Repeat("a", 10) + "...",
+		},
+		{
+			name:         "multi-byte rune cut",
+			capture:      true,
+			maxBodySize:  4,
+			request:      []byte("abc日本"),
+			wantRequest:  "abc...",
+			response:     []byte("ok"),
+			wantResponse: "ok",
+		},
+		{
+			name:         "binary body",
+			capture:      true,
+			maxBodySize:  64,
+			request:      []byte{0xff, 0xfe, 0x00, 0x01},
+			response:     []byte("ok"),
+			wantRequest:  "[binary 4 bytes]",
+			wantResponse: "ok",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			config := DefaultInstrumentationConfig()
+			config.CaptureBody = tt.capture
+			config.MaxBodySize = tt.maxBodySize
+			instr := NewInstrumentation(client, config)
+
+			err := instr.TraceHTTPRequestWithBody(context.Background(), "POST", "/v1/chat", tt.request,
+				func(context.Context) ([]byte, error) { return tt.response, nil })
+			if err != nil {
+				t.Fatalf("TraceHTTPRequestWithBody() error = %v", err)
+			}
+
+			span := findSpan(t, exportedSpans(t, client, exporter), "POST /v1/chat")
+			bodies := []struct {
+				key  string
+				want string
+			}{
+				{key: HTTPRequestBodyKey, want: tt.wantRequest},
+				{key: HTTPResponseBodyKey, want: tt.wantResponse},
+			}
+			for _, body := range bodies {
+				value, ok := attrValue(span.Attributes, body.key)
+				if body.want == "" {
+					if ok {
+						t.Errorf("%s = %q, want it absent", body.key, value.AsString())
+					}
+					continue
+				}
+				if value.AsString() != body.want {
+					t.Errorf("%s = %q, want %q", body.key, value.AsString(), body.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCaptureLLMBody(t *testing.T) {
+	input := []Message{{Role: "user", Content: strings.Repeat("Tell me a story. ", 20)}}
+	output := []Message{{Role: "assistant", Content: "Once upon a time"}}
+
+	tests := []struct {
+		name           string
+		maxBodySize    int
+		wantTruncated  bool
+		wantCompletion string
+	}{
+		{name: "no limit", wantCompletion: `[{"role":"assistant","content":"Once upon a time"}]`},
+		{name: "truncated", maxBodySize: 32, wantTruncated: true, wantCompletion: `[{"role":"assistant","content":"...`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			config := DefaultInstrumentationConfig()
+			config.CaptureBody = true
+			config.MaxBodySize = tt.maxBodySize
+			instr := NewInstrumentation(client, config)
+
+			err := instr.TraceLLMCall(context.Background(), "llm.chat", LLMSpanOptions{Provider: "openai", Model: "gpt-4", Messages: input},
+				func(ctx context.Context) error {
+					// The response is only known once the call returns
+					SetLLMResult(trace.SpanFromContext(ctx), LLMResult{OutputMessages: output})
+					return nil
+				})
+			if err != nil {
+				t.Fatalf("TraceLLMCall() error = %v", err)
+			}
+
+			span := findSpan(t, exportedSpans(t, client, exporter), "llm.chat")
+			prompt, _ := attrValue(span.Attributes, LLMPromptKey)
+			if truncated := strings.HasSuffix(prompt.AsString(), "..."); truncated != tt.wantTruncated {
+				t.Errorf("%s truncated = %v, want %v: %q", LLMPromptKey, truncated, tt.wantTruncated, prompt.AsString())
+			}
+			if tt.wantTruncated && len(prompt.AsString()) != tt.maxBodySize+len("...") {
+				t.Errorf("%s is %d bytes, want %d", LLMPromptKey, len(prompt.AsString()), tt.maxBodySize+len("..."))
+			}
+			if completion, _ := attrValue(span.Attributes, LLMCompletionKey); completion.AsString() != tt.wantCompletion {
+				t.Errorf("%s = %q, want %q", LLMCompletionKey, completion.AsString(), tt.wantCompletion)
+			}
+		})
+	}
+}
+
+// chatArgs is a struct argument with a credential field
+type chatArgs struct {
+	Model       string
+	Temperature float64
+	APIKey      string
+	Stop        []string
+	internal    string
+}
+
+func TestTraceFunctionWithArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		capture     bool
+		maxBodySize int
+		args        []interface{}
+		want        []string
+	}{
+		{name: "capture off", args: []interface{}{"gpt-4", 42}},
+		{
+			name:    "primitive arguments",
+			capture: true,
+			args:    []interface{}{"gpt-4", 42, 0.5, true},
+			want:    []string{"gpt-4", "42", "0.5", "true"},
+		},
+		{
+			name:    "struct argument",
+			capture: true,
+			args:    []interface{}{&chatArgs{Model: "gpt-4", Temperature: 0.2, APIKey: "abc123", Stop: []string{"\n"}, internal: "x"}},
+			want:    []string{`{"APIKey":"[REDACTED]","Model":"gpt-4","Stop":["\n"],"Temperature":0.2}`},
+		},
+		{
+			name:    "map with a sensitive key",
+			capture: true,
+			args:    []interface{}{map[string]string{"user": "bob", "password": "hunter2"}},
+			want:    []string{`{"password":"[REDACTED]","user":"bob"}`},
+		},
+		{
+			name:    "credential-like value",
+			capture: true,
+			args:    []interface{}{"sk-abcdefghijklmnopqrstuvwx", "Bearer abcdefghijklmnopqrstuvwx"},
+			want:    []string{"[REDACTED]", "[REDACTED]"},
+		},
+		{
+			name:        "long argument truncated",
+			capture:     true,
+			maxBodySize: 8,
+			args:        []interface{}{strings.Repeat("z", 20)},
+			want:        []string{"zzzzzzzz..."},
+		},
+		{
+			name:    "nil pointer",
+			capture: true,
+			args:    []interface{}{(*chatArgs)(nil)},
+			want:    []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			config := DefaultInstrumentationConfig()
+			config.CaptureArgs = tt.capture
+			config.MaxBodySize = tt.maxBodySize
+			instr := NewInstrumentation(client, config)
+
+			err := instr.TraceFunctionWithArgs(context.Background(), "generate", tt.args,
+				func(context.Context) error { return nil })
+			if err != nil {
+				t.Fatalf("TraceFunctionWithArgs() error = %v", err)
+			}
+
+			span := findSpan(t, exportedSpans(t, client, exporter), "generate")
+			for n := range tt.args {
+				key := fmt.Sprintf("%s.%d", FunctionArgKeyPrefix, n)
+				value, ok := attrValue(span.Attributes, key)
+				if tt.want == nil {
+					if ok {
+						t.Errorf("%s = %q with capture off", key, value.AsString())
+					}
+					continue
+				}
+				if value.AsString() != tt.want[n] {
+					t.Errorf("%s = %q, want %q", key, value.AsString(), tt.want[n])
+				}
+			}
+		})
+	}
+}
@@ -2,87 +2,518 @@ package untrace
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // untraceMetrics implements the Metrics interface
 type untraceMetrics struct {
-	meter metric.Meter
+	instruments    *instruments
+	ctx            context.Context
+	costDimensions []string
+	baggageLabels  []string
+	disableCost    bool
+	disableTokens  bool
+
+	// sampledOnly is set when token and cost metrics follow trace sampling,
+	// using sampler and the retention rules when spans are sampled at export
+	// and the span's sampled flag otherwise; upsample is the factor metrics of
+	// rate-sampled spans are then scaled by
+	sampledOnly       bool
+	sampler           sdktrace.Sampler
+	highCostThreshold float64
+	upsample          float64
+}
+
+// instruments holds the metric instruments, created once per meter and
+// shared by every metrics instance bound to a context
+type instruments struct {
+	promptTokens     metric.Int64Counter
+	completionTokens metric.Int64Counter
+	reasoningTokens  metric.Int64Counter
+	totalTokens      metric.Int64Counter
+
+	latency       metric.Float64Histogram
+	queueWait     metric.Float64Histogram
+	errors        metric.Int64Counter
+	streamAborted metric.Int64Counter
+	abortedTokens metric.Int64Counter
+	retryCount    metric.Int64Histogram
+	retriedOK     metric.Int64Counter
+	cacheHits     metric.Int64Counter
+	coldStarts    metric.Int64Counter
+
+	costPrompt     metric.Float64Counter
+	costCompletion metric.Float64Counter
+	costReasoning  metric.Float64Counter
+	costTotal      metric.Float64Counter
+	costNative     metric.Float64Counter
+
+	classificationScore metric.Float64Histogram
+
+	activeWorkflows  metric.Int64UpDownCounter
+	inFlightLLMCalls metric.Int64UpDownCounter
+
+	// Current values of the up-down counters, for local inspection
+	activeWorkflowCount atomic.Int64
+	inFlightLLMCount    atomic.Int64
+}
+
+// newInstruments creates the metric instruments on meter, returning every
+// creation error
+func newInstruments(meter metric.Meter) (*instruments, error) {
+	var errs []error
+	int64Counter := func(name string) metric.Int64Counter {
+		counter, err := meter.Int64Counter(name)
+		errs = append(errs, err)
+		return counter
+	}
+	float64Counter := func(name string) metric.Float64Counter {
+		counter, err := meter.Float64Counter(name)
+		errs = append(errs, err)
+		return counter
+	}
+	float64Histogram := func(name string) metric.Float64Histogram {
+		histogram, err := meter.Float64Histogram(name)
+		errs = append(errs, err)
+		return histogram
+	}
+	int64Histogram := func(name string) metric.Int64Histogram {
+		histogram, err := meter.Int64Histogram(name)
+		errs = append(errs, err)
+		return histogram
+	}
+	int64UpDownCounter := func(name string) metric.Int64UpDownCounter {
+		counter, err := meter.Int64UpDownCounter(name)
+		errs = append(errs, err)
+		return counter
+	}
+
+	inst := &instruments{
+		promptTokens:     int64Counter("llm.prompt.tokens"),
+		completionTokens: int64Counter("llm.completion.tokens"),
+		reasoningTokens:  int64Counter("llm.reasoning.tokens"),
+		totalTokens:      int64Counter("llm.total.tokens"),
+
+		latency:       float64Histogram("llm.latency"),
+		queueWait:     float64Histogram("llm.queue.wait"),
+		errors:        int64Counter("llm.errors"),
+		streamAborted: int64Counter("llm.stream.aborted"),
+		abortedTokens: int64Counter("llm.stream.aborted.tokens"),
+		retryCount:    int64Histogram("llm.retry.count"),
+		retriedOK:     int64Counter("llm.retry.succeeded_after"),
+		cacheHits:     int64Counter("llm.cache.hits"),
+		coldStarts:    int64Counter("faas.cold_starts"),
+
+		costPrompt:     float64Counter("llm.cost.prompt"),
+		costCompletion: float64Counter("llm.cost.completion"),
+		costReasoning:  float64Counter("llm.cost.reasoning"),
+		costTotal:      float64Counter("llm.cost.total"),
+		costNative:     float64Counter("llm.cost.native"),
+
+		classificationScore: float64Histogram("llm.classification.score"),
+
+		activeWorkflows:  int64UpDownCounter("workflow.active"),
+		inFlightLLMCalls: int64UpDownCounter("llm.calls.in_flight"),
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("failed to create metric instruments: %w", err)
+	}
+	return inst, nil
 }
 
 // NewMetrics creates a new Untrace metrics instance
-func NewMetrics(meter metric.Meter) Metrics {
-	return &untraceMetrics{
-		meter: meter,
+func NewMetrics(meter metric.Meter) (Metrics, error) {
+	// Return a nil interface, not a nil *untraceMetrics, on error
+	m, err := newMetrics(meter, Config{})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// newMetrics creates a metrics instance honoring the metric-related config
+func newMetrics(meter metric.Meter, config Config) (*untraceMetrics, error) {
+	inst, err := newInstruments(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &untraceMetrics{
+		instruments:    inst,
+		ctx:            context.Background(),
+		costDimensions: config.CostDimensions,
+		baggageLabels:  config.BaggageToMetricLabels,
+		disableCost:    config.DisableCostMetrics,
+		disableTokens:  config.DisableTokenMetrics,
+		upsample:       1,
+	}
+
+	if config.MetricsFromSampledOnly {
+		m.sampledOnly = true
+		if sampledAtExport(config) {
+			m.sampler = sdktrace.TraceIDRatioBased(config.SamplingRate)
+			m.highCostThreshold = config.HighCostThreshold
+		}
+		if config.UpsampleMetrics && config.SamplingRate > 0 && config.SamplerMode != SamplerAlwaysOn {
+			m.upsample = 1 / config.SamplingRate
+		}
 	}
+
+	return m, nil
+}
+
+// WithContext returns a metrics instance that records against ctx, picking up
+// its baggage labels. Cancellation of ctx is ignored: the SDK drops
+// measurements made with a done context, which would lose exactly the
+// cancelled operations
+func (m *untraceMetrics) WithContext(ctx context.Context) Metrics {
+	bound := *m
+	bound.ctx = context.WithoutCancel(ctx)
+	return &bound
 }
 
 // RecordTokenUsage records token usage metrics
 func (m *untraceMetrics) RecordTokenUsage(usage TokenUsage) {
+	if m.disableTokens {
+		return
+	}
+	scale, ok := m.sampledScale(0)
+	if !ok {
+		return
+	}
+
 	attrs := []attribute.KeyValue{
 		attribute.String("model", usage.Model),
 		attribute.String("provider", usage.Provider),
 	}
+	attrs = append(attrs, m.baggageAttributes()...)
 
-	// Create counters for different token types
-	promptCounter, _ := m.meter.Int64Counter("llm.prompt.tokens")
-	completionCounter, _ := m.meter.Int64Counter("llm.completion.tokens")
-	totalCounter, _ := m.meter.Int64Counter("llm.total.tokens")
+	// Reasoning tokens are billed, so they count towards the total
+	total := usage.TotalTokens
+	if total == 0 {
+		total = usage.PromptTokens + usage.CompletionTokens + usage.ReasoningTokens
+	}
 
 	if usage.PromptTokens > 0 {
-		promptCounter.Add(context.Background(), int64(usage.PromptTokens), metric.WithAttributes(attrs...))
+		m.instruments.promptTokens.Add(m.ctx, scaleTokens(usage.PromptTokens, scale), metric.WithAttributes(attrs...))
 	}
 	if usage.CompletionTokens > 0 {
-		completionCounter.Add(context.Background(), int64(usage.CompletionTokens), metric.WithAttributes(attrs...))
+		m.instruments.completionTokens.Add(m.ctx, scaleTokens(usage.CompletionTokens, scale), metric.WithAttributes(attrs...))
+	}
+	if usage.ReasoningTokens > 0 {
+		m.instruments.reasoningTokens.Add(m.ctx, scaleTokens(usage.ReasoningTokens, scale), metric.WithAttributes(attrs...))
 	}
-	if usage.TotalTokens > 0 {
-		totalCounter.Add(context.Background(), int64(usage.TotalTokens), metric.WithAttributes(attrs...))
+	if total > 0 {
+		m.instruments.totalTokens.Add(m.ctx, scaleTokens(total, scale), metric.WithAttributes(attrs...))
 	}
 }
 
 // RecordLatency records latency metrics
 func (m *untraceMetrics) RecordLatency(duration time.Duration, attributes map[string]interface{}) {
 	attrs := m.buildAttributes(attributes)
+	attrs = append(attrs, m.baggageAttributes()...)
 
-	histogram, _ := m.meter.Float64Histogram("llm.latency")
-	histogram.Record(context.Background(), duration.Seconds(), metric.WithAttributes(attrs...))
+	m.instruments.latency.Record(m.ctx, duration.Seconds(), metric.WithAttributes(attrs...))
 }
 
-// RecordError records error metrics
+// RecordError records error metrics, labeled by the error's type. The message
+// is unbounded, so it only goes on the span in the bound context, truncated
 func (m *untraceMetrics) RecordError(err error, attributes map[string]interface{}) {
+	errType := errorType(err)
+
+	attrs := m.buildAttributes(attributes)
+	attrs = append(attrs, attribute.String("error.type", errType))
+	attrs = append(attrs, m.baggageAttributes()...)
+
+	m.instruments.errors.Add(m.ctx, 1, metric.WithAttributes(attrs...))
+
+	if span := trace.SpanFromContext(m.ctx); span.IsRecording() {
+		span.AddEvent(ErrorEvent, trace.WithAttributes(
+			attribute.String(ErrorTypeKey, errType),
+			attribute.String(ErrorMessageKey, TruncateString(err.Error(), maxErrorMessageLen)),
+		))
+	}
+}
+
+// maxErrorMessageLen bounds the error messages recorded on spans
+const maxErrorMessageLen = 512
+
+// RecordLLMError records a structured provider error, labeled by its type and code
+func (m *untraceMetrics) RecordLLMError(llmErr LLMError) {
+	attrs := []attribute.KeyValue{
+		attribute.String("error.type", llmErr.Type),
+		attribute.String("error.code", llmErr.Code),
+	}
+	attrs = append(attrs, m.baggageAttributes()...)
+
+	m.instruments.errors.Add(m.ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// RecordAbortedStream records a streaming response abandoned before
+// completion and the tokens generated for it
+func (m *untraceMetrics) RecordAbortedStream(tokens int, attributes map[string]interface{}) {
+	attrs := m.buildAttributes(attributes)
+	attrs = append(attrs, m.baggageAttributes()...)
+
+	m.instruments.streamAborted.Add(m.ctx, 1, metric.WithAttributes(attrs...))
+	m.instruments.abortedTokens.Add(m.ctx, int64(tokens), metric.WithAttributes(attrs...))
+}
+
+// RecordQueueWait records how long an LLM call waited in the application's
+// queue before it was sent, separately from its latency
+func (m *untraceMetrics) RecordQueueWait(wait time.Duration, attributes map[string]interface{}) {
+	attrs := m.buildAttributes(attributes)
+	attrs = append(attrs, m.baggageAttributes()...)
+
+	m.instruments.queueWait.Record(m.ctx, wait.Seconds(), metric.WithAttributes(attrs...))
+}
+
+// RecordRetries records the retries of a call that took the given number of
+// attempts, labeled by its final outcome. Calls that succeeded only after
+// retries are also counted by the attempt they succeeded on
+func (m *untraceMetrics) RecordRetries(attempts int, succeeded bool, attributes map[string]interface{}) {
+	outcome := "failure"
+	if succeeded {
+		outcome = "success"
+	}
+
+	attrs := m.buildAttributes(attributes)
+	attrs = append(attrs, attribute.String("outcome", outcome))
+	attrs = append(attrs, m.baggageAttributes()...)
+
+	m.instruments.retryCount.Record(m.ctx, int64(attempts-1), metric.WithAttributes(attrs...))
+
+	if succeeded && attempts > 1 {
+		attrs = append(attrs, attribute.Int("attempts", attempts))
+		m.instruments.retriedOK.Add(m.ctx, 1, metric.WithAttributes(attrs...))
+	}
+}
+
+// RecordCacheHit records a response served from an application cache
+// without calling the LLM
+func (m *untraceMetrics) RecordCacheHit(attributes map[string]interface{}) {
 	attrs := m.buildAttributes(attributes)
-	attrs = append(attrs, attribute.String("error.type", err.Error()))
+	attrs = append(attrs, m.baggageAttributes()...)
+
+	m.instruments.cacheHits.Add(m.ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// RecordColdStart records a cold start of the process
+func (m *untraceMetrics) RecordColdStart() {
+	m.instruments.coldStarts.Add(m.ctx, 1, metric.WithAttributes(m.baggageAttributes()...))
+}
+
+// RecordActiveWorkflows adjusts the number of running workflows by delta
+func (m *untraceMetrics) RecordActiveWorkflows(delta int) {
+	m.instruments.activeWorkflowCount.Add(int64(delta))
+	m.instruments.activeWorkflows.Add(m.ctx, int64(delta), metric.WithAttributes(m.baggageAttributes()...))
+}
+
+// RecordInFlightLLMCalls adjusts the number of LLM calls in progress by delta
+func (m *untraceMetrics) RecordInFlightLLMCalls(delta int) {
+	m.instruments.inFlightLLMCount.Add(int64(delta))
+	m.instruments.inFlightLLMCalls.Add(m.ctx, int64(delta), metric.WithAttributes(m.baggageAttributes()...))
+}
 
-	counter, _ := m.meter.Int64Counter("llm.errors")
-	counter.Add(context.Background(), 1, metric.WithAttributes(attrs...))
+// ActiveCounts returns the number of running workflows and LLM calls in
+// progress
+func (m *untraceMetrics) ActiveCounts() (workflows, llmCalls int64) {
+	return m.instruments.activeWorkflowCount.Load(), m.instruments.inFlightLLMCount.Load()
 }
 
 // RecordCost records cost metrics
 func (m *untraceMetrics) RecordCost(cost Cost) {
+	if m.disableCost {
+		return
+	}
+	scale, ok := m.sampledScale(cost.Total)
+	if !ok {
+		return
+	}
+
 	attrs := []attribute.KeyValue{
 		attribute.String("model", cost.Model),
 		attribute.String("provider", cost.Provider),
 		attribute.String("currency", cost.Currency),
 	}
-
-	// Create counters for different cost components
-	promptCounter, _ := m.meter.Float64Counter("llm.cost.prompt")
-	completionCounter, _ := m.meter.Float64Counter("llm.cost.completion")
-	totalCounter, _ := m.meter.Float64Counter("llm.cost.total")
+	attrs = append(attrs, m.costDimensionAttributes(cost.Attributes)...)
+	attrs = append(attrs, m.baggageAttributes()...)
 
 	if cost.Prompt > 0 {
-		promptCounter.Add(context.Background(), cost.Prompt, metric.WithAttributes(attrs...))
+		m.instruments.costPrompt.Add(m.ctx, cost.Prompt*scale, metric.WithAttributes(attrs...))
 	}
 	if cost.Completion > 0 {
-		completionCounter.Add(context.Background(), cost.Completion, metric.WithAttributes(attrs...))
+		m.instruments.costCompletion.Add(m.ctx, cost.Completion*scale, metric.WithAttributes(attrs...))
+	}
+	if cost.Reasoning > 0 {
+		m.instruments.costReasoning.Add(m.ctx, cost.Reasoning*scale, metric.WithAttributes(attrs...))
 	}
 	if cost.Total > 0 {
-		totalCounter.Add(context.Background(), cost.Total, metric.WithAttributes(attrs...))
+		m.instruments.costTotal.Add(m.ctx, cost.Total*scale, metric.WithAttributes(attrs...))
+	}
+
+	// Native amounts are summed per billing unit next to the USD figures
+	if cost.NativeUnit != "" && cost.NativeAmount > 0 {
+		nativeAttrs := append([]attribute.KeyValue{attribute.String("unit", cost.NativeUnit)}, attrs...)
+		m.instruments.costNative.Add(m.ctx, cost.NativeAmount*scale, metric.WithAttributes(nativeAttrs...))
+	}
+}
+
+// RecordClassification records classifier scores for an LLM input or output
+func (m *untraceMetrics) RecordClassification(target string, labels map[string]float64) {
+	for label, score := range labels {
+		attrs := []attribute.KeyValue{
+			attribute.String("target", target),
+			attribute.String("label", label),
+		}
+		attrs = append(attrs, m.baggageAttributes()...)
+		m.instruments.classificationScore.Record(m.ctx, score, metric.WithAttributes(attrs...))
+	}
+}
+
+// RecordCostFromUsage computes the cost of the token usage from the pricing
+// table, records it, and returns it
+func (m *untraceMetrics) RecordCostFromUsage(usage TokenUsage) (Cost, error) {
+	cost, err := CalculateCost(usage.Provider, usage.Model, usage)
+	if err != nil {
+		return Cost{}, err
+	}
+
+	m.RecordCost(cost)
+	return cost, nil
+}
+
+// sampledScale returns whether token and cost metrics should be recorded for
+// the span in the bound context, and the factor to scale them by. Metrics
+// recorded outside a trace are always kept. When sampling at export, spans
+// the retention exporter always keeps (errored, forced, or costing at least
+// HighCostThreshold, counting the cost being recorded) keep their metrics
+// unscaled; an error or cost set on the span after the metric is recorded
+// can't be taken into account.
+func (m *untraceMetrics) sampledScale(cost float64) (float64, bool) {
+	if !m.sampledOnly {
+		return 1, true
+	}
+
+	span := trace.SpanFromContext(m.ctx)
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return 1, true
+	}
+
+	if m.sampler == nil {
+		if !sc.IsSampled() {
+			return 0, false
+		}
+		return m.upsample, true
+	}
+
+	if m.highCostThreshold > 0 && cost >= m.highCostThreshold {
+		return 1, true
+	}
+	if ro, ok := span.(sdktrace.ReadOnlySpan); ok {
+		if _, kept := alwaysRetained(ro, m.highCostThreshold); kept {
+			return 1, true
+		}
+	}
+	if !rateSampled(m.sampler, sc.TraceID()) {
+		return 0, false
+	}
+	return m.upsample, true
+}
+
+// scaleTokens scales a token count, rounding to the nearest token
+func scaleTokens(tokens int, scale float64) int64 {
+	return int64(math.Round(float64(tokens) * scale))
+}
+
+// costDimensionAttributes picks the configured cost dimensions out of the
+// cost's attributes or, failing that, the attributes of the LLM span in the
+// bound context, so cost can be sliced by them
+func (m *untraceMetrics) costDimensionAttributes(costAttrs map[string]interface{}) []attribute.KeyValue {
+	if len(m.costDimensions) == 0 {
+		return nil
+	}
+
+	var spanAttrs []attribute.KeyValue
+	if span, ok := trace.SpanFromContext(m.ctx).(sdktrace.ReadOnlySpan); ok {
+		spanAttrs = span.Attributes()
 	}
+
+	dims := make(map[string]interface{}, len(m.costDimensions))
+	var fromSpan []attribute.KeyValue
+	for _, key := range m.costDimensions {
+		if value, ok := costAttrs[key]; ok {
+			dims[key] = value
+			continue
+		}
+		for _, attr := range spanAttrs {
+			if string(attr.Key) == key {
+				fromSpan = append(fromSpan, attr)
+				break
+			}
+		}
+	}
+
+	return append(m.buildAttributes(dims), fromSpan...)
+}
+
+// baggageAttributes turns the configured baggage keys found in the bound
+// context into metric labels
+func (m *untraceMetrics) baggageAttributes() []attribute.KeyValue {
+	if len(m.baggageLabels) == 0 {
+		return nil
+	}
+
+	bag := baggage.FromContext(m.ctx)
+	var attrs []attribute.KeyValue
+	for _, key := range m.baggageLabels {
+		if member := bag.Member(key); member.Key() != "" {
+			attrs = append(attrs, attribute.String(key, member.Value()))
+		}
+	}
+	return attrs
+}
+
+// latencyView applies the configured bucket boundaries to the llm.latency
+// histogram
+func latencyView(buckets []float64) sdkmetric.View {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	return sdkmetric.NewView(
+		sdkmetric.Instrument{Name: "llm.latency"},
+		sdkmetric.Stream{Aggregation: sdkmetric.AggregationExplicitBucketHistogram{Boundaries: buckets}},
+	)
+}
+
+// registerExporterUpGauge registers a gauge reporting 1 while the latest
+// span export succeeded and 0 while exports are failing
+func registerExporterUpGauge(meter metric.Meter, exporter *errorReportingExporter) error {
+	_, err := meter.Int64ObservableGauge("untrace.exporter.up",
+		metric.WithDescription("1 while span exports are succeeding, 0 while they are failing"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			if exporter.Healthy() {
+				o.Observe(1)
+			} else {
+				o.Observe(0)
+			}
+			return nil
+		}),
+	)
+	return err
 }
 
 // buildAttributes converts a map of attributes to OpenTelemetry attributes
@@ -0,0 +1,694 @@
+package untrace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRecordCostDimensions(t *testing.T) {
+	costAttrs := map[string]interface{}{
+		"team.id":    "search",
+		"project.id": "assistant",
+		"user.id":    "u-1",
+	}
+
+	tests := []struct {
+		name       string
+		dimensions []string
+		costAttrs  map[string]interface{}
+		spanAttrs  []attribute.KeyValue
+		want       map[string]string
+		absent     []string
+	}{
+		{
+			name:       "both dimensions",
+			dimensions: []string{"team.id", "project.id"},
+			costAttrs:  costAttrs,
+			want:       map[string]string{"team.id": "search", "project.id": "assistant"},
+		},
+		{
+			name:       "one dimension",
+			dimensions: []string{"team.id"},
+			costAttrs:  costAttrs,
+			want:       map[string]string{"team.id": "search"},
+			absent:     []string{"project.id"},
+		},
+		{
+			name:      "no dimensions",
+			costAttrs: costAttrs,
+			absent:    []string{"team.id", "project.id", "user.id"},
+		},
+		{
+			name:       "dimensions only on the span",
+			dimensions: []string{"team.id", "project.id"},
+			spanAttrs:  []attribute.KeyValue{attribute.String("team.id", "search"), attribute.String("project.id", "assistant")},
+			want:       map[string]string{"team.id": "search", "project.id": "assistant"},
+		},
+		{
+			name:       "cost attributes win over the span",
+			dimensions: []string{"team.id", "project.id"},
+			costAttrs:  map[string]interface{}{"team.id": "billing"},
+			spanAttrs:  []attribute.KeyValue{attribute.String("team.id", "search"), attribute.String("project.id", "assistant")},
+			want:       map[string]string{"team.id": "billing", "project.id": "assistant"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig("test-key")
+			config.CostDimensions = tt.dimensions
+			m, reader := newTestMetrics(t, config)
+
+			provider := sdktrace.NewTracerProvider()
+			t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+			ctx, span := provider.Tracer("test").Start(context.Background(), "llm.chat", trace.WithAttributes(tt.spanAttrs...))
+			defer span.End()
+
+			m.WithContext(ctx).RecordCost(Cost{
+				Total:      0.01,
+				Model:      "gpt-4",
+				Provider:   "openai",
+				Attributes: tt.costAttrs,
+			})
+
+			points := sumPoints(t, collectMetric(t, reader, "llm.cost.total"))
+			if len(points) != 1 {
+				t.Fatalf("got %d data points, want 1", len(points))
+			}
+			attrs := points[0].Attributes
+			for key, want := range tt.want {
+				if got, ok := attrs.Value(attribute.Key(key)); !ok || got.AsString() != want {
+					t.Errorf("label %s = %q, want %q", key, got.AsString(), want)
+				}
+			}
+			for _, key := range tt.absent {
+				if attrs.HasValue(attribute.Key(key)) {
+					t.Errorf("label %s is set, want it absent", key)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordCostFromUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		usage    TokenUsage
+		wantCost float64
+		wantErr  error
+	}{
+		{
+			name:     "gpt-4",
+			usage:    TokenUsage{PromptTokens: 1000, CompletionTokens: 500, Provider: "openai", Model: "gpt-4"},
+			wantCost: 0.03 + 0.03,
+		},
+		{
+			name:     "model name is case-insensitive",
+			usage:    TokenUsage{PromptTokens: 2000, Provider: "OpenAI", Model: "GPT-3.5-Turbo"},
+			wantCost: 0.001,
+		},
+		{
+			name:    "unknown model",
+			usage:   TokenUsage{PromptTokens: 1000, Provider: "openai", Model: "gpt-unknown"},
+			wantErr: ErrUnknownModelPricing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics, reader := newTestMetrics(t, DefaultConfig("test-key"))
+
+			cost, err := metrics.RecordCostFromUsage(tt.usage)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RecordCostFromUsage() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if m, ok := findMetric(t, reader, "llm.cost.total"); ok {
+					t.Errorf("recorded %v for a model without pricing", sumValue(t, m))
+				}
+				return
+			}
+
+			if math.Abs(cost.Total-tt.wantCost) > 1e-9 {
+				t.Errorf("computed cost = %v, want %v", cost.Total, tt.wantCost)
+			}
+			if recorded := sumValue(t, collectMetric(t, reader, "llm.cost.total")); math.Abs(recorded-tt.wantCost) > 1e-9 {
+				t.Errorf("recorded cost = %v, want %v", recorded, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestBaggageMetricLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		baggage map[string]string
+		want    map[string]string
+		absent  []string
+	}{
+		{
+			name:    "listed key",
+			baggage: map[string]string{"tenant.id": "acme"},
+			want:    map[string]string{"tenant.id": "acme"},
+		},
+		{
+			name:    "unlisted key",
+			baggage: map[string]string{"tenant.id": "acme", "user.id": "u-1"},
+			want:    map[string]string{"tenant.id": "acme"},
+			absent:  []string{"user.id"},
+		},
+		{
+			name:   "no baggage",
+			absent: []string{"tenant.id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig("test-key")
+			config.BaggageToMetricLabels = []string{"tenant.id"}
+			metrics, reader := newTestMetrics(t, config)
+
+			ctx := context.Background()
+			for key, value := range tt.baggage {
+				member, err := baggage.NewMember(key, value)
+				if err != nil {
+					t.Fatalf("NewMember() error = %v", err)
+				}
+				bag, _ := baggage.FromContext(ctx).SetMember(member)
+				ctx = baggage.ContextWithBaggage(ctx, bag)
+			}
+
+			metrics.WithContext(ctx).RecordCost(Cost{Total: 0.02, Model: "gpt-4", Provider: "openai"})
+
+			points := sumPoints(t, collectMetric(t, reader, "llm.cost.total"))
+			if len(points) != 1 {
+				t.Fatalf("got %d data points, want 1", len(points))
+			}
+			attrs := points[0].Attributes
+			for key, want := range tt.want {
+				if got, _ := attrs.Value(attribute.Key(key)); got.AsString() != want {
+					t.Errorf("label %s = %q, want %q", key, got.AsString(), want)
+				}
+			}
+			for _, key := range tt.absent {
+				if attrs.HasValue(attribute.Key(key)) {
+					t.Errorf("label %s is set, want it absent", key)
+				}
+			}
+		})
+	}
+}
+
+func TestDisableTokenAndCostMetrics(t *testing.T) {
+	tests := []struct {
+		name          string
+		disableTokens bool
+		disableCost   bool
+	}{
+		{name: "both enabled"},
+		{name: "tokens disabled", disableTokens: true},
+		{name: "cost disabled", disableCost: true},
+		{name: "both disabled", disableTokens: true, disableCost: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig("test-key")
+			config.DisableTokenMetrics = tt.disableTokens
+			config.DisableCostMetrics = tt.disableCost
+			metrics, reader := newTestMetrics(t, config)
+
+			metrics.RecordTokenUsage(TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, Provider: "openai", Model: "gpt-4"})
+			metrics.RecordCost(Cost{Prompt: 0.01, Completion: 0.02, Total: 0.03, Provider: "openai", Model: "gpt-4"})
+			metrics.RecordLatency(time.Second, nil)
+
+			for name, wantRecorded := range map[string]bool{
+				"llm.prompt.tokens": !tt.disableTokens,
+				"llm.total.tokens":  !tt.disableTokens,
+				"llm.cost.prompt":   !tt.disableCost,
+				"llm.cost.total":    !tt.disableCost,
+				"llm.latency":       true,
+			} {
+				if _, recorded := findMetric(t, reader, name); recorded != wantRecorded {
+					t.Errorf("%s recorded = %v, want %v", name, recorded, wantRecorded)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsFromSampledOnly(t *testing.T) {
+	const spans, tokens = 100, 10
+
+	tests := []struct {
+		name        string
+		mode        SamplerMode
+		sampledOnly bool
+		upsample    bool
+		errored     bool
+		// want returns the expected token total given the exported span count
+		want func(exported int) int
+	}{
+		{name: "all traces", want: func(int) int { return spans * tokens }},
+		{name: "sampled traces only", sampledOnly: true, want: func(n int) int { return n * tokens }},
+		{name: "upsampled", sampledOnly: true, upsample: true, want: func(n int) int { return n * tokens * 2 }},
+		{name: "retention, sampled traces only", mode: SamplerRetention, sampledOnly: true, want: func(n int) int { return n * tokens }},
+		{
+			name:        "retention, errored spans kept unscaled",
+			mode:        SamplerRetention,
+			sampledOnly: true,
+			upsample:    true,
+			errored:     true,
+			want:        func(n int) int { return n * tokens },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, func(c *Config) {
+				if tt.mode != "" {
+					c.SamplerMode = tt.mode
+				}
+				c.SamplingRate = 0.5
+				c.IDGenerator = NewSeededIDGenerator(42)
+				c.MetricsFromSampledOnly = tt.sampledOnly
+				c.UpsampleMetrics = tt.upsample
+			})
+
+			for i := 0; i < spans; i++ {
+				ctx, span := client.Tracer().StartLLMSpan(context.Background(), "llm.chat", LLMSpanOptions{Provider: "openai", Model: "gpt-4"})
+				if tt.errored {
+					span.SetStatus(codes.Error, "boom")
+				}
+				client.Metrics().WithContext(ctx).RecordTokenUsage(TokenUsage{TotalTokens: tokens, Provider: "openai", Model: "gpt-4"})
+				span.End()
+			}
+
+			exported := len(exportedSpans(t, client, exporter))
+			if !tt.errored && (exported == 0 || exported == spans) {
+				t.Fatalf("exported %d of %d spans, want a sample", exported, spans)
+			}
+
+			got := sumValue(t, collectMetric(t, client.snapshotReader, "llm.total.tokens"))
+			if want := tt.want(exported); got != float64(want) {
+				t.Errorf("llm.total.tokens = %v, want %d (%d spans exported)", got, want, exported)
+			}
+		})
+	}
+}
+
+func TestReasoningTokens(t *testing.T) {
+	tests := []struct {
+		name          string
+		pricing       *ModelPricing
+		usage         TokenUsage
+		wantTotal     float64
+		wantReasoning float64
+		wantCost      float64
+	}{
+		{
+			name:          "billed at the completion price",
+			usage:         TokenUsage{PromptTokens: 1000, CompletionTokens: 500, ReasoningTokens: 2000},
+			wantTotal:     3500,
+			wantReasoning: 2000,
+			wantCost:      0.015 + 0.03 + 0.12,
+		},
+		{
+			name:          "priced separately",
+			pricing:       &ModelPricing{PromptPer1K: 0.01, CompletionPer1K: 0.02, ReasoningPer1K: 0.05},
+			usage:         TokenUsage{PromptTokens: 1000, CompletionTokens: 1000, ReasoningTokens: 1000},
+			wantTotal:     3000,
+			wantReasoning: 1000,
+			wantCost:      0.01 + 0.02 + 0.05,
+		},
+		{
+			name:      "explicit total kept",
+			usage:     TokenUsage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500},
+			wantTotal: 1500,
+			wantCost:  0.015 + 0.03,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.usage.Provider, tt.usage.Model = "openai", "o1"
+			if tt.pricing != nil {
+				tt.usage.Model = "test-reasoner"
+				SetModelPricing("openai", tt.usage.Model, *tt.pricing)
+				defer func() {
+					pricingMu.Lock()
+					delete(pricingTable, pricingKey("openai", "test-reasoner"))
+					pricingMu.Unlock()
+				}()
+			}
+			metrics, reader := newTestMetrics(t, DefaultConfig("test-key"))
+
+			metrics.RecordTokenUsage(tt.usage)
+			cost, err := metrics.RecordCostFromUsage(tt.usage)
+			if err != nil {
+				t.Fatalf("RecordCostFromUsage() error = %v", err)
+			}
+
+			if total := sumValue(t, collectMetric(t, reader, "llm.total.tokens")); total != tt.wantTotal {
+				t.Errorf("llm.total.tokens = %v, want %v", total, tt.wantTotal)
+			}
+			reasoning := 0.0
+			if m, ok := findMetric(t, reader, "llm.reasoning.tokens"); ok {
+				reasoning = sumValue(t, m)
+			}
+			if reasoning != tt.wantReasoning {
+				t.Errorf("llm.reasoning.tokens = %v, want %v", reasoning, tt.wantReasoning)
+			}
+			if math.Abs(cost.Total-tt.wantCost) > 1e-9 {
+				t.Errorf("cost = %v, want %v", cost.Total, tt.wantCost)
+			}
+			if _, recorded := findMetric(t, reader, "llm.cost.reasoning"); recorded != (tt.wantReasoning > 0) {
+				t.Errorf("llm.cost.reasoning recorded = %v, want %v", recorded, tt.wantReasoning > 0)
+			}
+		})
+	}
+}
+
+func TestNativeCost(t *testing.T) {
+	tests := []struct {
+		name       string
+		cost       Cost
+		wantNative map[string]float64
+	}{
+		{name: "USD only", cost: Cost{Prompt: 0.01, Completion: 0.02, Total: 0.03}},
+		{
+			name:       "credits",
+			cost:       Cost{Prompt: 0.01, Completion: 0.02, Total: 0.03, NativeAmount: 30, NativeUnit: "credits"},
+			wantNative: map[string]float64{"credits": 30},
+		},
+		{name: "zero native amount", cost: Cost{Total: 0.03, NativeUnit: "credits"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cost.Provider, tt.cost.Model = "openai", "gpt-4"
+			metrics, reader := newTestMetrics(t, DefaultConfig("test-key"))
+			metrics.RecordCost(tt.cost)
+
+			attrs := CreateCostAttributes(tt.cost)
+			unit, hasUnit := attrValue(attrs, LLMCostNativeUnitKey)
+			amount, hasAmount := attrValue(attrs, LLMCostNativeAmountKey)
+			wantAttrs := tt.cost.NativeUnit != ""
+			if hasUnit != wantAttrs || hasAmount != wantAttrs {
+				t.Errorf("native cost attributes set = %v/%v, want %v", hasUnit, hasAmount, wantAttrs)
+			}
+			if wantAttrs && (unit.AsString() != tt.cost.NativeUnit || amount.AsFloat64() != tt.cost.NativeAmount) {
+				t.Errorf("native cost = %v %s, want %v %s", amount.AsFloat64(), unit.AsString(), tt.cost.NativeAmount, tt.cost.NativeUnit)
+			}
+			if total, _ := attrValue(attrs, LLMCostTotalKey); total.AsFloat64() != tt.cost.Total {
+				t.Errorf("%s = %v, want %v", LLMCostTotalKey, total.AsFloat64(), tt.cost.Total)
+			}
+
+			m, recorded := findMetric(t, reader, "llm.cost.native")
+			if recorded != (len(tt.wantNative) > 0) {
+				t.Fatalf("llm.cost.native recorded = %v, want %v", recorded, len(tt.wantNative) > 0)
+			}
+			if !recorded {
+				return
+			}
+			for _, point := range sumPoints(t, m) {
+				unit, _ := point.Attributes.Value("unit")
+				if want := tt.wantNative[unit.AsString()]; point.Value != want {
+					t.Errorf("llm.cost.native{unit=%s} = %v, want %v", unit.AsString(), point.Value, want)
+				}
+			}
+			if usd := sumValue(t, collectMetric(t, reader, "llm.cost.total")); usd != tt.cost.Total {
+				t.Errorf("llm.cost.total = %v, want %v", usd, tt.cost.Total)
+			}
+		})
+	}
+}
+
+func TestRecordErrorType(t *testing.T) {
+	tests := []struct {
+		name     string
+		errs     []error
+		wantType string
+	}{
+		{
+			name:     "plain errors",
+			errs:     []error{errors.New("timeout after 30s"), errors.New("timeout after 45s")},
+			wantType: "*errors.errorString",
+		},
+		{
+			name:     "wrapped errors",
+			errs:     []error{fmt.Errorf("call 1: %w", errors.New("refused")), fmt.Errorf("call 2: %w", errors.New("reset"))},
+			wantType: "*errors.errorString",
+		},
+		{
+			name:     "validation errors",
+			errs:     []error{NewValidationError("model is required", "Model"), NewValidationError("bad temperature", "Temperature")},
+			wantType: "*untrace.ValidationError",
+		},
+		{
+			name:     "provider errors",
+			errs:     []error{&LLMError{Type: "rate_limit_error", Message: "slow down"}, &LLMError{Type: "rate_limit_error", Message: "try again in 20s"}},
+			wantType: "rate_limit_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			ctx, span := client.Tracer().StartSpan(context.Background(), "call", SpanOptions{})
+			for _, err := range tt.errs {
+				client.Metrics().WithContext(ctx).RecordError(err, nil)
+			}
+			span.End()
+
+			// Different messages of the same type share one time series
+			points := sumPoints(t, collectMetric(t, client.snapshotReader, "llm.errors"))
+			if len(points) != 1 {
+				t.Fatalf("recorded %d error series, want 1", len(points))
+			}
+			if errType, _ := points[0].Attributes.Value("error.type"); errType.AsString() != tt.wantType {
+				t.Errorf("error.type = %q, want %q", errType.AsString(), tt.wantType)
+			}
+			if points[0].Value != float64(len(tt.errs)) {
+				t.Errorf("llm.errors = %v, want %d", points[0].Value, len(tt.errs))
+			}
+
+			// The messages go on the span instead
+			got := findSpan(t, exportedSpans(t, client, exporter), "call")
+			var messages []string
+			for _, event := range got.Events {
+				if event.Name != ErrorEvent {
+					continue
+				}
+				message, _ := attrValue(event.Attributes, ErrorMessageKey)
+				messages = append(messages, message.AsString())
+			}
+			if len(messages) != len(tt.errs) {
+				t.Fatalf("recorded %d error events, want %d", len(messages), len(tt.errs))
+			}
+			for i, err := range tt.errs {
+				if messages[i] != err.Error() {
+					t.Errorf("%s = %q, want %q", ErrorMessageKey, messages[i], err.Error())
+				}
+			}
+		})
+	}
+}
+
+// failingMeter is a meter whose counters cannot be created
+type failingMeter struct {
+	noop.Meter
+}
+
+// Int64Counter fails to create the counter
+func (failingMeter) Int64Counter(name string, options ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return noop.Int64Counter{}, fmt.Errorf("instrument %s: meter unavailable", name)
+}
+
+func TestNewMetricsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		meter   metric.Meter
+		wantErr bool
+	}{
+		{name: "instruments created", meter: noop.NewMeterProvider().Meter("test")},
+		{name: "instrument creation fails", meter: failingMeter{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics, err := NewMetrics(tt.meter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewMetrics() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && metrics != nil {
+				t.Errorf("NewMetrics() returned metrics along with an error")
+			}
+		})
+	}
+}
+
+// recordTokenUsagePerCall records token usage creating its instruments on
+// every call, as metrics did before caching them
+func recordTokenUsagePerCall(ctx context.Context, meter metric.Meter, usage TokenUsage) {
+	attrs := metric.WithAttributes(
+		attribute.String("model", usage.Model),
+		attribute.String("provider", usage.Provider),
+	)
+
+	prompt, _ := meter.Int64Counter("llm.prompt.tokens")
+	prompt.Add(ctx, int64(usage.PromptTokens), attrs)
+	completion, _ := meter.Int64Counter("llm.completion.tokens")
+	completion.Add(ctx, int64(usage.CompletionTokens), attrs)
+	total, _ := meter.Int64Counter("llm.total.tokens")
+	total.Add(ctx, int64(usage.PromptTokens+usage.CompletionTokens), attrs)
+}
+
+func BenchmarkRecordTokenUsage(b *testing.B) {
+	usage := TokenUsage{PromptTokens: 120, CompletionTokens: 30, Provider: "openai", Model: "gpt-4"}
+
+	newMeter := func(b *testing.B) metric.Meter {
+		provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewManualReader()))
+		b.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+		return provider.Meter("bench")
+	}
+
+	b.Run("per-call instruments", func(b *testing.B) {
+		meter := newMeter(b)
+		ctx := context.Background()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			recordTokenUsagePerCall(ctx, meter, usage)
+		}
+	})
+
+	b.Run("cached instruments", func(b *testing.B) {
+		metrics, err := newMetrics(newMeter(b), Config{})
+		if err != nil {
+			b.Fatalf("newMetrics() error = %v", err)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			metrics.RecordTokenUsage(usage)
+		}
+	})
+}
+
+func TestLatencyBuckets(t *testing.T) {
+	tests := []struct {
+		name    string
+		buckets []float64
+		want    []float64
+		wantErr bool
+	}{
+		{name: "default buckets", want: DefaultLatencyBuckets},
+		{name: "custom buckets", buckets: []float64{0.05, 1, 15}, want: []float64{0.05, 1, 15}},
+		{name: "unordered buckets", buckets: []float64{1, 0.5}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr {
+				config := DefaultConfig("test-key")
+				config.LatencyBuckets = tt.buckets
+				if err := config.Validate(); err == nil {
+					t.Fatalf("Validate() accepted buckets %v", tt.buckets)
+				}
+				return
+			}
+
+			client, _ := newTestClient(t, func(c *Config) {
+				c.LatencyBuckets = tt.buckets
+			})
+			client.Metrics().RecordLatency(1500*time.Millisecond, map[string]interface{}{"model": "gpt-4"})
+
+			m := collectMetric(t, client.snapshotReader, "llm.latency")
+			histogram, ok := m.Data.(metricdata.Histogram[float64])
+			if !ok || len(histogram.DataPoints) != 1 {
+				t.Fatalf("llm.latency = %#v, want one histogram data point", m.Data)
+			}
+			bounds := histogram.DataPoints[0].Bounds
+			if fmt.Sprint(bounds) != fmt.Sprint(tt.want) {
+				t.Errorf("bucket bounds = %v, want %v", bounds, tt.want)
+			}
+		})
+	}
+}
+
+func TestMeterProvider(t *testing.T) {
+	tests := []struct {
+		name   string
+		record func(client *untraceClient)
+		want   string
+	}{
+		{
+			name: "token usage",
+			record: func(client *untraceClient) {
+				client.Metrics().RecordTokenUsage(TokenUsage{PromptTokens: 10, Provider: "openai", Model: "gpt-4"})
+			},
+			want: "llm.prompt.tokens",
+		},
+		{
+			name: "cost",
+			record: func(client *untraceClient) {
+				client.Metrics().RecordCost(Cost{Total: 0.25, Provider: "openai", Model: "gpt-4"})
+			},
+			want: "llm.cost.total",
+		},
+		{
+			name: "global meter",
+			record: func(*untraceClient) {
+				counter, _ := otel.Meter("app").Int64Counter("app.requests")
+				counter.Add(context.Background(), 1)
+			},
+			want: "app.requests",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := otel.GetMeterProvider()
+			t.Cleanup(func() { otel.SetMeterProvider(previous) })
+
+			exporter := &memoryMetricExporter{}
+			client, _ := newTestClient(t, func(c *Config) {
+				c.MetricExporter = exporter
+			})
+
+			tt.record(client)
+
+			if m := collectMetric(t, client.snapshotReader, tt.want); sumValue(t, m) == 0 {
+				t.Errorf("%s recorded as 0", tt.want)
+			}
+
+			// Shutting down exports the final metrics
+			if err := client.Shutdown(context.Background()); err != nil {
+				t.Fatalf("Shutdown() error = %v", err)
+			}
+			if !exporter.metricNames()[tt.want] {
+				t.Errorf("%s not exported; got %v", tt.want, exporter.metricNames())
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package untrace
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// middlewareConfig holds the settings of Middleware
+type middlewareConfig struct {
+	skipPaths  map[string]bool
+	route      func(*http.Request) string
+	propagator propagation.TextMapPropagator
+}
+
+// MiddlewareOption customizes Middleware
+type MiddlewareOption func(*middlewareConfig)
+
+// WithSkipPaths excludes requests to the given paths, e.g. health checks,
+// from tracing
+func WithSkipPaths(paths ...string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		for _, path := range paths {
+			c.skipPaths[path] = true
+		}
+	}
+}
+
+// WithRoute sets how the route of a request is named, e.g. "/users/{id}"
+// from the router in use. It defaults to the request path, so set it when
+// paths carry IDs to keep span names low-cardinality
+func WithRoute(route func(*http.Request) string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.route = route
+	}
+}
+
+// WithPropagator sets the propagator incoming trace context is extracted
+// with, in place of the global one
+func WithPropagator(propagator propagation.TextMapPropagator) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.propagator = propagator
+	}
+}
+
+// WithB3Propagation accepts incoming trace context in B3 single or multiple
+// header form as well as W3C trace context, along with W3C baggage
+func WithB3Propagation() MiddlewareOption {
+	return WithPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		b3.New(),
+		propagation.Baggage{},
+	))
+}
+
+// Middleware returns net/http middleware tracing each incoming request as a
+// server span, continuing the trace propagated by the caller. The span
+// records the method, route and response status code, and the request
+// latency is recorded as a metric. Trace context is extracted with the
+// global propagator, W3C trace context and baggage by default; use
+// WithB3Propagation to also accept B3 headers.
+func Middleware(client Client, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	config := &middlewareConfig{
+		skipPaths: make(map[string]bool),
+		route:     func(r *http.Request) string { return r.URL.Path },
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if client == nil || config.skipPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			propagator := config.propagator
+			if propagator == nil {
+				propagator = textMapPropagator()
+			}
+
+			route := config.route(r)
+			ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := client.Tracer().StartSpan(ctx, fmt.Sprintf("%s %s", r.Method, route), SpanOptions{
+				Kind: trace.SpanKindServer,
+				Attributes: map[string]interface{}{
+					HTTPMethodKey: r.Method,
+					HTTPRouteKey:  route,
+				},
+			})
+			defer span.End()
+
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			start := time.Now()
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+			duration := time.Since(start)
+
+			span.SetAttributes(attribute.Int(HTTPStatusCodeKey, recorder.status))
+			if recorder.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(recorder.status))
+			}
+
+			client.Metrics().WithContext(ctx).RecordLatency(duration, map[string]interface{}{
+				HTTPMethodKey:     r.Method,
+				HTTPRouteKey:      route,
+				HTTPStatusCodeKey: recorder.status,
+			})
+		})
+	}
+}
+
+// statusRecorder is a ResponseWriter that remembers the response status
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the status and writes it
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes the body, implying a 200 status if none was written
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush sends buffered data to the client, e.g. for streamed responses, if
+// the wrapped ResponseWriter supports it
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		r.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// Hijack takes over the connection, e.g. for a WebSocket upgrade, if the
+// wrapped ResponseWriter supports it
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("untrace: %T does not support hijacking", r.ResponseWriter)
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
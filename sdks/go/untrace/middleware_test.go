@@ -0,0 +1,194 @@
+package untrace
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	const incomingTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	tests := []struct {
+		name       string
+		path       string
+		headers    map[string]string
+		opts       []MiddlewareOption
+		status     int
+		wantSpan   string
+		wantRoute  string
+		wantError  bool
+		wantParent string
+	}{
+		{name: "ok response", path: "/chat", status: http.StatusOK, wantSpan: "POST /chat", wantRoute: "/chat"},
+		{name: "client error", path: "/chat", status: http.StatusBadRequest, wantSpan: "POST /chat", wantRoute: "/chat"},
+		{name: "server error", path: "/chat", status: http.StatusBadGateway, wantSpan: "POST /chat", wantRoute: "/chat", wantError: true},
+		{name: "skipped health check", path: "/healthz", opts: []MiddlewareOption{WithSkipPaths("/healthz")}, status: http.StatusOK},
+		{
+			name:      "named route",
+			path:      "/users/42",
+			opts:      []MiddlewareOption{WithRoute(func(*http.Request) string { return "/users/{id}" })},
+			status:    http.StatusOK,
+			wantSpan:  "POST /users/{id}",
+			wantRoute: "/users/{id}",
+		},
+		{
+			name:       "w3c trace context",
+			path:       "/chat",
+			headers:    map[string]string{"traceparent": "00-" + incomingTrace + "-00f067aa0ba902b7-01"},
+			status:     http.StatusOK,
+			wantSpan:   "POST /chat",
+			wantRoute:  "/chat",
+			wantParent: incomingTrace,
+		},
+		{
+			name:       "b3 single header",
+			path:       "/chat",
+			headers:    map[string]string{"b3": incomingTrace + "-00f067aa0ba902b7-1"},
+			opts:       []MiddlewareOption{WithB3Propagation()},
+			status:     http.StatusOK,
+			wantSpan:   "POST /chat",
+			wantRoute:  "/chat",
+			wantParent: incomingTrace,
+		},
+		{
+			name: "b3 multiple headers",
+			path: "/chat",
+			headers: map[string]string{
+				"X-B3-TraceId": incomingTrace,
+				"X-B3-SpanId":  "00f067aa0ba902b7",
+				"X-B3-Sampled": "1",
+			},
+			opts:       []MiddlewareOption{WithB3Propagation()},
+			status:     http.StatusOK,
+			wantSpan:   "POST /chat",
+			wantRoute:  "/chat",
+			wantParent: incomingTrace,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			var handlerSpan trace.SpanContext
+			handler := Middleware(client, tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerSpan = trace.SpanContextFromContext(r.Context())
+				w.WriteHeader(tt.status)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.status {
+				t.Errorf("response status = %d, want %d", recorder.Code, tt.status)
+			}
+
+			spans := exportedSpans(t, client, exporter)
+			if tt.wantSpan == "" {
+				if len(spans) != 0 {
+					t.Errorf("traced a skipped path: %v", spanNames(spans))
+				}
+				return
+			}
+
+			span := findSpan(t, spans, tt.wantSpan)
+			if span.SpanKind != trace.SpanKindServer {
+				t.Errorf("span kind = %v, want %v", span.SpanKind, trace.SpanKindServer)
+			}
+			if span.SpanContext.SpanID() != handlerSpan.SpanID() {
+				t.Errorf("handler context is not the server span")
+			}
+			wantAttrs := map[string]string{
+				HTTPMethodKey: http.MethodPost,
+				HTTPRouteKey:  tt.wantRoute,
+			}
+			for key, want := range wantAttrs {
+				if value, _ := attrValue(span.Attributes, key); value.AsString() != want {
+					t.Errorf("%s = %q, want %q", key, value.AsString(), want)
+				}
+			}
+			if code, _ := attrValue(span.Attributes, HTTPStatusCodeKey); code.AsInt64() != int64(tt.status) {
+				t.Errorf("%s = %d, want %d", HTTPStatusCodeKey, code.AsInt64(), tt.status)
+			}
+			if isError := span.Status.Code == codes.Error; isError != tt.wantError {
+				t.Errorf("span errored = %v, want %v", isError, tt.wantError)
+			}
+
+			if tt.wantParent != "" {
+				if span.SpanContext.TraceID().String() != tt.wantParent || !span.Parent.IsRemote() {
+					t.Errorf("span trace = %s (remote parent: %v), want a child of trace %s",
+						span.SpanContext.TraceID(), span.Parent.IsRemote(), tt.wantParent)
+				}
+			} else if span.Parent.IsValid() {
+				t.Errorf("span has parent %s, want a root span", span.Parent.SpanID())
+			}
+
+			if m := collectMetric(t, client.snapshotReader, "llm.latency"); len(m.Data.(metricdata.Histogram[float64]).DataPoints) != 1 {
+				t.Errorf("request latency not recorded")
+			}
+		})
+	}
+}
+
+func TestMiddlewareResponseWriter(t *testing.T) {
+	tests := []struct {
+		name string
+		use  func(t *testing.T, w http.ResponseWriter)
+		want int
+	}{
+		{
+			name: "flush implies 200",
+			use: func(t *testing.T, w http.ResponseWriter) {
+				_, _ = w.Write([]byte("data: hello\n\n"))
+				if err := http.NewResponseController(w).Flush(); err != nil {
+					t.Errorf("Flush() error = %v", err)
+				}
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "first status wins",
+			use: func(t *testing.T, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusAccepted)
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			want: http.StatusAccepted,
+		},
+		{
+			name: "hijack unsupported",
+			use: func(t *testing.T, w http.ResponseWriter) {
+				if _, _, err := w.(http.Hijacker).Hijack(); err == nil {
+					t.Errorf("Hijack() succeeded on a recorder")
+				}
+				w.WriteHeader(http.StatusNotImplemented)
+			},
+			want: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			handler := Middleware(client)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.use(t, w)
+			}))
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+			span := findSpan(t, exportedSpans(t, client, exporter), "GET /stream")
+			if code, _ := attrValue(span.Attributes, HTTPStatusCodeKey); code.AsInt64() != int64(tt.want) {
+				t.Errorf("%s = %d, want %d", HTTPStatusCodeKey, code.AsInt64(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package untrace
+
+import (
+	"encoding/hex"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// convertSpansToOTLPJSON converts OpenTelemetry spans to the OTLP/JSON
+// encoding: resourceSpans grouped by resource, then scopeSpans by
+// instrumentation scope
+func (e *UntraceExporter) convertSpansToOTLPJSON(spans []sdktrace.ReadOnlySpan) (map[string]interface{}, error) {
+	type scopeGroup struct {
+		scope instrumentation.Scope
+		spans []interface{}
+	}
+	type resourceGroup struct {
+		resource *resource.Resource
+		scopes   []*scopeGroup
+		byScope  map[instrumentation.Scope]*scopeGroup
+	}
+
+	var resources []*resourceGroup
+	byResource := make(map[attribute.Distinct]*resourceGroup)
+
+	for _, span := range spans {
+		res := span.Resource()
+		rg, ok := byResource[res.Equivalent()]
+		if !ok {
+			rg = &resourceGroup{
+				resource: res,
+				byScope:  make(map[instrumentation.Scope]*scopeGroup),
+			}
+			byResource[res.Equivalent()] = rg
+			resources = append(resources, rg)
+		}
+
+		scope := span.InstrumentationScope()
+		sg, ok := rg.byScope[scope]
+		if !ok {
+			sg = &scopeGroup{scope: scope}
+			rg.byScope[scope] = sg
+			rg.scopes = append(rg.scopes, sg)
+		}
+
+		sg.spans = append(sg.spans, otlpJSONSpan(span))
+	}
+
+	resourceSpans := make([]interface{}, 0, len(resources))
+	for _, rg := range resources {
+		scopeSpans := make([]interface{}, 0, len(rg.scopes))
+		for _, sg := range rg.scopes {
+			scopeSpans = append(scopeSpans, map[string]interface{}{
+				"scope": map[string]interface{}{
+					"name":    sg.scope.Name,
+					"version": sg.scope.Version,
+				},
+				"schemaUrl": sg.scope.SchemaURL,
+				"spans":     sg.spans,
+			})
+		}
+
+		var resourceAttrs []attribute.KeyValue
+		schemaURL := ""
+		if rg.resource != nil {
+			resourceAttrs = rg.resource.Attributes()
+			schemaURL = rg.resource.SchemaURL()
+		}
+
+		resourceSpans = append(resourceSpans, map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpJSONAttributes(resourceAttrs),
+			},
+			"schemaUrl":  schemaURL,
+			"scopeSpans": scopeSpans,
+		})
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": resourceSpans,
+	}, nil
+}
+
+// otlpJSONSpan converts a span to its OTLP/JSON representation
+func otlpJSONSpan(span sdktrace.ReadOnlySpan) map[string]interface{} {
+	sc := span.SpanContext()
+	converted := map[string]interface{}{
+		"traceId":           sc.TraceID().String(),
+		"spanId":            sc.SpanID().String(),
+		"name":              span.Name(),
+		"kind":              int(span.SpanKind()),
+		"startTimeUnixNano": strconv.FormatInt(span.StartTime().UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(span.EndTime().UnixNano(), 10),
+		"attributes":        otlpJSONAttributes(span.Attributes()),
+		"status":            otlpJSONStatus(span.Status()),
+	}
+
+	if ts := sc.TraceState().String(); ts != "" {
+		converted["traceState"] = ts
+	}
+	if parent := span.Parent(); parent.SpanID().IsValid() {
+		converted["parentSpanId"] = parent.SpanID().String()
+	}
+
+	if events := span.Events(); len(events) > 0 {
+		convertedEvents := make([]interface{}, 0, len(events))
+		for _, event := range events {
+			convertedEvents = append(convertedEvents, map[string]interface{}{
+				"timeUnixNano": strconv.FormatInt(event.Time.UnixNano(), 10),
+				"name":         event.Name,
+				"attributes":   otlpJSONAttributes(event.Attributes),
+			})
+		}
+		converted["events"] = convertedEvents
+	}
+
+	if links := span.Links(); len(links) > 0 {
+		convertedLinks := make([]interface{}, 0, len(links))
+		for _, link := range links {
+			traceID := link.SpanContext.TraceID()
+			spanID := link.SpanContext.SpanID()
+			convertedLinks = append(convertedLinks, map[string]interface{}{
+				"traceId":    hex.EncodeToString(traceID[:]),
+				"spanId":     hex.EncodeToString(spanID[:]),
+				"attributes": otlpJSONAttributes(link.Attributes),
+			})
+		}
+		converted["links"] = convertedLinks
+	}
+
+	return converted
+}
+
+// otlpJSONStatus converts a span status to its OTLP/JSON representation
+func otlpJSONStatus(status sdktrace.Status) map[string]interface{} {
+	// OTLP status codes: 0 unset, 1 ok, 2 error
+	code := 0
+	switch status.Code {
+	case codes.Ok:
+		code = 1
+	case codes.Error:
+		code = 2
+	}
+
+	converted := map[string]interface{}{"code": code}
+	if status.Description != "" {
+		converted["message"] = status.Description
+	}
+	return converted
+}
+
+// otlpJSONAttributes converts attributes to OTLP/JSON key-value pairs
+func otlpJSONAttributes(attrs []attribute.KeyValue) []interface{} {
+	converted := make([]interface{}, 0, len(attrs))
+	for _, attr := range attrs {
+		converted = append(converted, map[string]interface{}{
+			"key":   string(attr.Key),
+			"value": otlpJSONValue(attr.Value),
+		})
+	}
+	return converted
+}
+
+// otlpJSONValue converts an attribute value to an OTLP/JSON AnyValue. 64-bit
+// integers are encoded as strings as the protobuf JSON mapping requires.
+func otlpJSONValue(value attribute.Value) map[string]interface{} {
+	switch value.Type() {
+	case attribute.BOOL:
+		return map[string]interface{}{"boolValue": value.AsBool()}
+	case attribute.INT64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(value.AsInt64(), 10)}
+	case attribute.FLOAT64:
+		return map[string]interface{}{"doubleValue": value.AsFloat64()}
+	case attribute.BOOLSLICE:
+		values := make([]interface{}, 0, len(value.AsBoolSlice()))
+		for _, v := range value.AsBoolSlice() {
+			values = append(values, otlpJSONValue(attribute.BoolValue(v)))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case attribute.INT64SLICE:
+		values := make([]interface{}, 0, len(value.AsInt64Slice()))
+		for _, v := range value.AsInt64Slice() {
+			values = append(values, otlpJSONValue(attribute.Int64Value(v)))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case attribute.FLOAT64SLICE:
+		values := make([]interface{}, 0, len(value.AsFloat64Slice()))
+		for _, v := range value.AsFloat64Slice() {
+			values = append(values, otlpJSONValue(attribute.Float64Value(v)))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case attribute.STRINGSLICE:
+		values := make([]interface{}, 0, len(value.AsStringSlice()))
+		for _, v := range value.AsStringSlice() {
+			values = append(values, otlpJSONValue(attribute.StringValue(v)))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	default:
+		return map[string]interface{}{"stringValue": value.Emit()}
+	}
+}
@@ -0,0 +1,218 @@
+package untrace
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// otlpJSONPayload mirrors the parts of an OTLP/JSON trace request the tests check
+type otlpJSONPayload struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []otlpJSONKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			Spans []struct {
+				TraceID           string             `json:"traceId"`
+				SpanID            string             `json:"spanId"`
+				ParentSpanID      string             `json:"parentSpanId"`
+				Name              string             `json:"name"`
+				Kind              int                `json:"kind"`
+				StartTimeUnixNano string             `json:"startTimeUnixNano"`
+				EndTimeUnixNano   string             `json:"endTimeUnixNano"`
+				Attributes        []otlpJSONKeyValue `json:"attributes"`
+				Status            struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				} `json:"status"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+// otlpJSONKeyValue is an OTLP/JSON attribute
+type otlpJSONKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func TestOTLPJSONPayload(t *testing.T) {
+	tests := []struct {
+		name       string
+		record     func(tp trace.TracerProvider)
+		wantScopes map[string][]string
+	}{
+		{
+			name: "single span",
+			record: func(tp trace.TracerProvider) {
+				_, span := tp.Tracer("untrace").Start(context.Background(), "llm.chat")
+				span.End()
+			},
+			wantScopes: map[string][]string{"untrace": {"llm.chat"}},
+		},
+		{
+			name: "parent and child",
+			record: func(tp trace.TracerProvider) {
+				ctx, parent := tp.Tracer("untrace").Start(context.Background(), "workflow")
+				_, child := tp.Tracer("untrace").Start(ctx, "llm.chat")
+				child.End()
+				parent.End()
+			},
+			wantScopes: map[string][]string{"untrace": {"llm.chat", "workflow"}},
+		},
+		{
+			name: "spans grouped by scope",
+			record: func(tp trace.TracerProvider) {
+				for _, scope := range []string{"otelhttp", "untrace", "otelhttp"} {
+					_, span := tp.Tracer(scope).Start(context.Background(), scope+".span")
+					span.End()
+				}
+			},
+			wantScopes: map[string][]string{
+				"otelhttp": {"otelhttp.span", "otelhttp.span"},
+				"untrace":  {"untrace.span"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			tt.record(client.TracerProvider())
+			spans := exportedSpans(t, client, exporter)
+
+			config := client.config
+			config.ExportFormat = ExportFormatOTLPJSON
+			untraceExporter, err := NewUntraceExporter(config)
+			if err != nil {
+				t.Fatalf("NewUntraceExporter() error = %v", err)
+			}
+			payload, err := untraceExporter.buildPayload(tracetest.SpanStubs(spans).Snapshots())
+			if err != nil {
+				t.Fatalf("buildPayload() error = %v", err)
+			}
+
+			data, err := json.Marshal(payload)
+			if err != nil {
+				t.Fatalf("payload is not JSON: %v", err)
+			}
+			var decoded otlpJSONPayload
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("unexpected payload shape: %v\n%s", err, data)
+			}
+
+			if len(decoded.ResourceSpans) != 1 {
+				t.Fatalf("got %d resourceSpans, want 1", len(decoded.ResourceSpans))
+			}
+			rs := decoded.ResourceSpans[0]
+			var hasServiceName bool
+			for _, attr := range rs.Resource.Attributes {
+				hasServiceName = hasServiceName || attr.Key == "service.name"
+			}
+			if !hasServiceName {
+				t.Errorf("resource attributes lack service.name")
+			}
+
+			ids := make(map[string]bool)
+			gotScopes := make(map[string][]string)
+			for _, ss := range rs.ScopeSpans {
+				if _, dup := gotScopes[ss.Scope.Name]; dup {
+					t.Errorf("scope %q appears in several scopeSpans", ss.Scope.Name)
+				}
+				gotScopes[ss.Scope.Name] = nil
+				for _, span := range ss.Spans {
+					gotScopes[ss.Scope.Name] = append(gotScopes[ss.Scope.Name], span.Name)
+					ids[span.SpanID] = true
+					if len(span.TraceID) != 32 || len(span.SpanID) != 16 {
+						t.Errorf("span %s: trace/span IDs %q/%q are not hex", span.Name, span.TraceID, span.SpanID)
+					}
+					start, err1 := strconv.ParseInt(span.StartTimeUnixNano, 10, 64)
+					end, err2 := strconv.ParseInt(span.EndTimeUnixNano, 10, 64)
+					if err1 != nil || err2 != nil || end < start {
+						t.Errorf("span %s: times %q..%q", span.Name, span.StartTimeUnixNano, span.EndTimeUnixNano)
+					}
+				}
+			}
+			for scope, want := range tt.wantScopes {
+				if got := gotScopes[scope]; len(got) != len(want) {
+					t.Errorf("scope %s has spans %v, want %v", scope, got, want)
+				}
+			}
+			for _, ss := range rs.ScopeSpans {
+				for _, span := range ss.Spans {
+					if span.ParentSpanID != "" && !ids[span.ParentSpanID] {
+						t.Errorf("span %s: parentSpanId %s not in the batch", span.Name, span.ParentSpanID)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestOTLPJSONValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value attribute.Value
+		want  string
+	}{
+		{name: "string", value: attribute.StringValue("gpt-4"), want: `{"stringValue":"gpt-4"}`},
+		{name: "bool", value: attribute.BoolValue(true), want: `{"boolValue":true}`},
+		{name: "int as string", value: attribute.Int64Value(9007199254740993), want: `{"intValue":"9007199254740993"}`},
+		{name: "double", value: attribute.Float64Value(0.25), want: `{"doubleValue":0.25}`},
+		{
+			name:  "string slice",
+			value: attribute.StringSliceValue([]string{"a", "b"}),
+			want:  `{"arrayValue":{"values":[{"stringValue":"a"},{"stringValue":"b"}]}}`,
+		},
+		{
+			name:  "int slice",
+			value: attribute.Int64SliceValue([]int64{1}),
+			want:  `{"arrayValue":{"values":[{"intValue":"1"}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(otlpJSONValue(tt.value))
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("otlpJSONValue() = %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestOTLPJSONStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status codes.Code
+		desc   string
+		want   string
+	}{
+		{name: "unset", status: codes.Unset, want: `{"code":0}`},
+		{name: "ok", status: codes.Ok, want: `{"code":1}`},
+		{name: "error", status: codes.Error, desc: "boom", want: `{"code":2,"message":"boom"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := json.Marshal(otlpJSONStatus(sdktrace.Status{Code: tt.status, Description: tt.desc}))
+			if string(data) != tt.want {
+				t.Errorf("otlpJSONStatus() = %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package untrace
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownModelPricing is returned when no pricing is registered for a model
+var ErrUnknownModelPricing = errors.New("no pricing registered for model")
+
+// ModelPricing represents the USD price of a model per 1K tokens
+type ModelPricing struct {
+	PromptPer1K     float64
+	CompletionPer1K float64
+	// ReasoningPer1K prices reasoning tokens. Zero bills them at the
+	// completion price, as most providers do
+	ReasoningPer1K float64
+}
+
+// defaultPricing holds the list prices (USD per 1K tokens) of common OpenAI
+// and Anthropic models, keyed by provider and model
+var defaultPricing = map[string]map[string]ModelPricing{
+	"openai": {
+		"gpt-4":                  {PromptPer1K: 0.03, CompletionPer1K: 0.06},
+		"gpt-4-32k":              {PromptPer1K: 0.06, CompletionPer1K: 0.12},
+		"gpt-4-turbo":            {PromptPer1K: 0.01, CompletionPer1K: 0.03},
+		"gpt-4o":                 {PromptPer1K: 0.0025, CompletionPer1K: 0.01},
+		"gpt-4o-mini":            {PromptPer1K: 0.00015, CompletionPer1K: 0.0006},
+		"gpt-3.5-turbo":          {PromptPer1K: 0.0005, CompletionPer1K: 0.0015},
+		"o1":                     {PromptPer1K: 0.015, CompletionPer1K: 0.06},
+		"text-embedding-3-small": {PromptPer1K: 0.00002},
+		"text-embedding-3-large": {PromptPer1K: 0.00013},
+		"text-embedding-ada-002": {PromptPer1K: 0.0001},
+	},
+	"anthropic": {
+		"claude-3-opus-20240229":     {PromptPer1K: 0.015, CompletionPer1K: 0.075},
+		"claude-3-sonnet-20240229":   {PromptPer1K: 0.003, CompletionPer1K: 0.015},
+		"claude-3-haiku-20240307":    {PromptPer1K: 0.00025, CompletionPer1K: 0.00125},
+		"claude-3-5-sonnet-20240620": {PromptPer1K: 0.003, CompletionPer1K: 0.015},
+		"claude-3-5-sonnet-20241022": {PromptPer1K: 0.003, CompletionPer1K: 0.015},
+		"claude-3-5-haiku-20241022":  {PromptPer1K: 0.0008, CompletionPer1K: 0.004},
+	},
+}
+
+// Pricing table management
+var (
+	pricingTable = newPricingTable()
+	pricingMu    sync.RWMutex
+)
+
+// newPricingTable creates the pricing table seeded with the default prices
+func newPricingTable() map[string]ModelPricing {
+	table := make(map[string]ModelPricing)
+	for provider, models := range defaultPricing {
+		for model, pricing := range models {
+			table[pricingKey(provider, model)] = pricing
+		}
+	}
+	return table
+}
+
+// pricingKey builds the pricing table key for a provider and model
+func pricingKey(provider, model string) string {
+	return strings.ToLower(provider) + "/" + strings.ToLower(model)
+}
+
+// RegisterModelPricing adds or overrides the pricing of a model. The table
+// ships with list prices of common OpenAI and Anthropic models; register
+// your negotiated prices or newer models over them.
+func RegisterModelPricing(provider, model string, promptPer1K, completionPer1K float64) {
+	SetModelPricing(provider, model, ModelPricing{
+		PromptPer1K:     promptPer1K,
+		CompletionPer1K: completionPer1K,
+	})
+}
+
+// SetModelPricing adds or overrides the full pricing of a model, e.g. to
+// price reasoning tokens separately
+func SetModelPricing(provider, model string, pricing ModelPricing) {
+	pricingMu.Lock()
+	defer pricingMu.Unlock()
+
+	pricingTable[pricingKey(provider, model)] = pricing
+}
+
+// LookupModelPricing returns the registered pricing of a model
+func LookupModelPricing(provider, model string) (ModelPricing, bool) {
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
+
+	pricing, ok := pricingTable[pricingKey(provider, model)]
+	return pricing, ok
+}
+
+// CalculateCost computes the USD cost of the token usage from the pricing
+// table. It returns an error wrapping ErrUnknownModelPricing if the model has
+// no pricing, so callers can fall back with errors.Is.
+func CalculateCost(provider, model string, usage TokenUsage) (Cost, error) {
+	pricing, ok := LookupModelPricing(provider, model)
+	if !ok {
+		return Cost{}, fmt.Errorf("%w: %s/%s", ErrUnknownModelPricing, provider, model)
+	}
+
+	prompt := float64(usage.PromptTokens) / 1000 * pricing.PromptPer1K
+	completion := float64(usage.CompletionTokens) / 1000 * pricing.CompletionPer1K
+
+	reasoningPer1K := pricing.ReasoningPer1K
+	if reasoningPer1K == 0 {
+		reasoningPer1K = pricing.CompletionPer1K
+	}
+	reasoning := float64(usage.ReasoningTokens) / 1000 * reasoningPer1K
+
+	return Cost{
+		Prompt:     prompt,
+		Completion: completion,
+		Reasoning:  reasoning,
+		Total:      prompt + completion + reasoning,
+		Currency:   "USD",
+		Model:      model,
+		Provider:   provider,
+	}, nil
+}
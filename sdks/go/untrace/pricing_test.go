@@ -0,0 +1,81 @@
+package untrace
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDefaultPricing(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		model    string
+		override *ModelPricing
+		usage    TokenUsage
+		wantCost float64
+		wantErr  error
+	}{
+		{
+			name:     "gpt-4",
+			provider: "openai",
+			model:    "gpt-4",
+			usage:    TokenUsage{PromptTokens: 1000, CompletionTokens: 1000},
+			wantCost: 0.09,
+		},
+		{
+			name:     "gpt-3.5-turbo",
+			provider: "openai",
+			model:    "gpt-3.5-turbo",
+			usage:    TokenUsage{PromptTokens: 2000, CompletionTokens: 1000},
+			wantCost: 0.0025,
+		},
+		{
+			name:     "anthropic model",
+			provider: "Anthropic",
+			model:    "claude-3-haiku-20240307",
+			usage:    TokenUsage{PromptTokens: 4000, CompletionTokens: 800},
+			wantCost: 0.002,
+		},
+		{
+			name:     "embedding model has no completion price",
+			provider: "openai",
+			model:    "text-embedding-3-small",
+			usage:    TokenUsage{PromptTokens: 50000},
+			wantCost: 0.001,
+		},
+		{
+			name:     "registered price overrides the default",
+			provider: "openai",
+			model:    "gpt-4",
+			override: &ModelPricing{PromptPer1K: 0.02, CompletionPer1K: 0.04},
+			usage:    TokenUsage{PromptTokens: 1000, CompletionTokens: 1000},
+			wantCost: 0.06,
+		},
+		{
+			name:     "unknown model",
+			provider: "openai",
+			model:    "gpt-unknown",
+			usage:    TokenUsage{PromptTokens: 1000},
+			wantErr:  ErrUnknownModelPricing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.override != nil {
+				original, _ := LookupModelPricing(tt.provider, tt.model)
+				SetModelPricing(tt.provider, tt.model, *tt.override)
+				t.Cleanup(func() { SetModelPricing(tt.provider, tt.model, original) })
+			}
+
+			cost, err := CalculateCost(tt.provider, tt.model, tt.usage)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CalculateCost() error = %v, want %v", err, tt.wantErr)
+			}
+			if math.Abs(cost.Total-tt.wantCost) > 1e-9 {
+				t.Errorf("CalculateCost() total = %v, want %v", cost.Total, tt.wantCost)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package untrace
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// endedSpanCounter is a span processor that counts sampled spans as they end
+type endedSpanCounter struct {
+	ended atomic.Int64
+}
+
+// OnStart does nothing
+func (p *endedSpanCounter) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+// OnEnd counts the span if it is sampled and will reach the exporter
+func (p *endedSpanCounter) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.ended.Add(1)
+	}
+}
+
+// Shutdown does nothing
+func (p *endedSpanCounter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ForceFlush does nothing
+func (p *endedSpanCounter) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// Ended returns the number of sampled spans ended so far
+func (p *endedSpanCounter) Ended() int64 {
+	return p.ended.Load()
+}
+
+// contextAttributesProcessor is a span processor that applies the attributes
+// attached to the parent context to every span started in it
+type contextAttributesProcessor struct{}
+
+// OnStart sets the context attributes on the span, falling back to the
+// conversation ID propagated in baggage by an upstream service
+func (p *contextAttributesProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if id, ok := conversationIDFromBaggage(parent); ok {
+		s.SetAttributes(attribute.String(ConversationIDKey, id))
+	}
+	if attrs := contextAttributes(parent); len(attrs) > 0 {
+		s.SetAttributes(attrs...)
+	}
+}
+
+// OnEnd does nothing
+func (p *contextAttributesProcessor) OnEnd(s sdktrace.ReadOnlySpan) {}
+
+// Shutdown does nothing
+func (p *contextAttributesProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ForceFlush does nothing
+func (p *contextAttributesProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// spanNameProcessor is a span processor that renames spans at start using
+// the configured transform
+type spanNameProcessor struct {
+	transform func(original string, attrs map[string]interface{}) string
+}
+
+// OnStart renames the span
+func (p *spanNameProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	attrs := make(map[string]interface{}, len(s.Attributes()))
+	for _, attr := range s.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.AsInterface()
+	}
+
+	if name := p.transform(s.Name(), attrs); name != "" {
+		s.SetName(name)
+	}
+}
+
+// OnEnd does nothing
+func (p *spanNameProcessor) OnEnd(s sdktrace.ReadOnlySpan) {}
+
+// Shutdown does nothing
+func (p *spanNameProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ForceFlush does nothing
+func (p *spanNameProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// slowSpanStartHook is the duration above which a Config.OnSpanStart call is
+// reported as slow in debug mode
+const slowSpanStartHook = time.Millisecond
+
+// spanStartHookProcessor is a span processor that sets the attributes
+// returned by the configured OnSpanStart hook
+type spanStartHookProcessor struct {
+	hook  func(ctx context.Context, name string) []attribute.KeyValue
+	debug bool
+}
+
+// OnStart calls the hook and sets its attributes on the span
+func (p *spanStartHookProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	start := time.Now()
+	attrs := p.hook(parent, s.Name())
+	if elapsed := time.Since(start); p.debug && elapsed > slowSpanStartHook {
+		log.Printf("[Untrace] Warning: OnSpanStart took %s for span %q", elapsed, s.Name())
+	}
+
+	if len(attrs) > 0 {
+		s.SetAttributes(attrs...)
+	}
+}
+
+// OnEnd does nothing
+func (p *spanStartHookProcessor) OnEnd(s sdktrace.ReadOnlySpan) {}
+
+// Shutdown does nothing
+func (p *spanStartHookProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ForceFlush does nothing
+func (p *spanStartHookProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// deadlineProcessor is a span processor that records how much of the parent
+// context's deadline remained when each span ended. It wraps the next
+// processor since ended spans can no longer be modified.
+type deadlineProcessor struct {
+	next sdktrace.SpanProcessor
+}
+
+// newDeadlineProcessor creates a deadline processor that forwards to next
+func newDeadlineProcessor(next sdktrace.SpanProcessor) *deadlineProcessor {
+	return &deadlineProcessor{next: next}
+}
+
+// OnStart stamps the span with the budget left on the parent context's
+// deadline, if any, when the span started. Keeping it on the span rather than
+// in the processor means spans that never end leave nothing behind.
+func (p *deadlineProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if deadline, ok := parent.Deadline(); ok {
+		s.SetAttributes(attribute.Int64(RequestDeadlineRemainingMsKey, deadline.Sub(s.StartTime()).Milliseconds()))
+	}
+	p.next.OnStart(parent, s)
+}
+
+// OnEnd takes the span's duration off the budget stamped at start, so the
+// value is negative when the span ended after the deadline
+func (p *deadlineProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	for i, kv := range s.Attributes() {
+		if kv.Key != RequestDeadlineRemainingMsKey {
+			continue
+		}
+		attrs := make([]attribute.KeyValue, len(s.Attributes()))
+		copy(attrs, s.Attributes())
+		elapsed := s.EndTime().Sub(s.StartTime())
+		attrs[i] = attribute.Int64(RequestDeadlineRemainingMsKey, kv.Value.AsInt64()-elapsed.Milliseconds())
+		s = &attributeOverrideSpan{ReadOnlySpan: s, attrs: attrs}
+		break
+	}
+	p.next.OnEnd(s)
+}
+
+// Shutdown shuts down the next processor
+func (p *deadlineProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+// ForceFlush flushes the next processor
+func (p *deadlineProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// filterProcessor is a span processor that drops spans rejected by a filter
+// and rewrites the attributes of the rest before forwarding them to the next
+// processor
+type filterProcessor struct {
+	next       sdktrace.SpanProcessor
+	filter     func(sdktrace.ReadOnlySpan) bool
+	attributes func([]attribute.KeyValue) []attribute.KeyValue
+
+	// ended, if set, counts the spans the filter keeps, so dropped spans
+	// aren't reported as abandoned at shutdown
+	ended *endedSpanCounter
+}
+
+// newFilterProcessor creates a filter processor that forwards to next. A nil
+// filter keeps every span and nil attributes leaves them unchanged
+func newFilterProcessor(next sdktrace.SpanProcessor, filter func(sdktrace.ReadOnlySpan) bool, attributes func([]attribute.KeyValue) []attribute.KeyValue) *filterProcessor {
+	return &filterProcessor{next: next, filter: filter, attributes: attributes}
+}
+
+// OnStart forwards the span to the next processor
+func (p *filterProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+// OnEnd forwards the span unless the filter rejects it
+func (p *filterProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if p.filter != nil && !p.filter(s) {
+		return
+	}
+	if p.ended != nil {
+		p.ended.OnEnd(s)
+	}
+	if p.attributes != nil {
+		attrs := make([]attribute.KeyValue, len(s.Attributes()))
+		copy(attrs, s.Attributes())
+		s = &attributeOverrideSpan{ReadOnlySpan: s, attrs: p.attributes(attrs)}
+	}
+	p.next.OnEnd(s)
+}
+
+// Shutdown shuts down the next processor
+func (p *filterProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+// ForceFlush flushes the next processor
+func (p *filterProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// staticAttributesProcessor is a span processor that sets the same
+// attributes on every span as it starts
+type staticAttributesProcessor struct {
+	attrs []attribute.KeyValue
+}
+
+// newStaticAttributesProcessor converts the attributes once for every span
+func newStaticAttributesProcessor(attrs map[string]interface{}) *staticAttributesProcessor {
+	p := &staticAttributesProcessor{attrs: make([]attribute.KeyValue, 0, len(attrs))}
+	for key, value := range attrs {
+		p.attrs = append(p.attrs, toAttribute(key, value))
+	}
+	return p
+}
+
+// OnStart sets the attributes the span wasn't started with, so start
+// attributes with the same key take precedence
+func (p *staticAttributesProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	existing := s.Attributes()
+	if len(existing) == 0 {
+		s.SetAttributes(p.attrs...)
+		return
+	}
+
+	present := make(map[attribute.Key]bool, len(existing))
+	for _, attr := range existing {
+		present[attr.Key] = true
+	}
+	missing := make([]attribute.KeyValue, 0, len(p.attrs))
+	for _, attr := range p.attrs {
+		if !present[attr.Key] {
+			missing = append(missing, attr)
+		}
+	}
+	s.SetAttributes(missing...)
+}
+
+// OnEnd does nothing
+func (p *staticAttributesProcessor) OnEnd(s sdktrace.ReadOnlySpan) {}
+
+// Shutdown does nothing
+func (p *staticAttributesProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ForceFlush does nothing
+func (p *staticAttributesProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
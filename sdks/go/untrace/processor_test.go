@@ -0,0 +1,410 @@
+package untrace
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// recordingProcessor logs the spans it sees, tagged with its name
+type recordingProcessor struct {
+	name string
+	mu   *sync.Mutex
+	log  *[]string
+}
+
+// OnStart logs the span start along with whether the built-in static
+// attributes were already applied
+func (p *recordingProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry := p.name + ".start." + s.Name()
+	if _, ok := attrValue(s.Attributes(), "region"); ok {
+		entry += "+static"
+	}
+	*p.log = append(*p.log, entry)
+}
+
+// OnEnd logs the span end
+func (p *recordingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	*p.log = append(*p.log, p.name+".end."+s.Name())
+}
+
+// Shutdown does nothing
+func (p *recordingProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ForceFlush does nothing
+func (p *recordingProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+func TestUserSpanProcessorsOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		processors []string
+		want       []string
+	}{
+		{
+			name:       "single processor",
+			processors: []string{"a"},
+			want:       []string{"a.start.work+static", "a.end.work"},
+		},
+		{
+			name:       "processors in order",
+			processors: []string{"a", "b"},
+			want: []string{
+				"a.start.work+static", "b.start.work+static",
+				"a.end.work", "b.end.work",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var log []string
+			var opts []Option
+			for _, name := range tt.processors {
+				opts = append(opts, WithSpanProcessor(&recordingProcessor{name: name, mu: &mu, log: &log}))
+			}
+
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.SpanAttributes = map[string]interface{}{"region": "eu-west-1"}
+			}, opts...)
+
+			_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{})
+			span.End()
+
+			// The built-in export pipeline still runs alongside the processors
+			findSpan(t, exportedSpans(t, client, exporter), "work")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(log) != len(tt.want) {
+				t.Fatalf("processors saw %v, want %v", log, tt.want)
+			}
+			for i := range tt.want {
+				if log[i] != tt.want[i] {
+					t.Errorf("processors saw %v, want %v", log, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSpanNameTransform(t *testing.T) {
+	// Include the model in LLM span names, leaving other spans as they are
+	withModel := func(original string, attrs map[string]interface{}) string {
+		if model, ok := attrs[LLMModelKey].(string); ok {
+			return original + " " + model
+		}
+		return ""
+	}
+
+	tests := []struct {
+		name  string
+		start func(ctx context.Context, tracer Tracer)
+		want  string
+	}{
+		{
+			name: "LLM span",
+			start: func(ctx context.Context, tracer Tracer) {
+				_, span := tracer.StartLLMSpan(ctx, "chat", LLMSpanOptions{Provider: "openai", Model: "gpt-4o"})
+				span.End()
+			},
+			want: "chat gpt-4o",
+		},
+		{
+			name: "plain span",
+			start: func(ctx context.Context, tracer Tracer) {
+				_, span := tracer.StartSpan(ctx, "handle", SpanOptions{})
+				span.End()
+			},
+			want: "handle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.SpanNameTransform = withModel
+			})
+
+			tt.start(context.Background(), client.Tracer())
+
+			spans := exportedSpans(t, client, exporter)
+			if len(spans) != 1 || spans[0].Name != tt.want {
+				t.Errorf("exported %v, want [%s]", spanNames(spans), tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadlineRemaining(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		sleep    time.Duration
+		wantSet  bool
+		wantSign int
+	}{
+		{name: "no deadline"},
+		{name: "within budget", timeout: time.Minute, wantSet: true, wantSign: 1},
+		{name: "over budget", timeout: 10 * time.Millisecond, sleep: 40 * time.Millisecond, wantSet: true, wantSign: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			_, span := client.Tracer().StartSpan(ctx, "handle", SpanOptions{})
+			// The budget is stamped at start, so an open span already has it
+			live := span.(sdktrace.ReadOnlySpan)
+			if _, ok := attrValue(live.Attributes(), RequestDeadlineRemainingMsKey); ok != tt.wantSet {
+				t.Errorf("%s set at start = %v, want %v", RequestDeadlineRemainingMsKey, ok, tt.wantSet)
+			}
+			time.Sleep(tt.sleep)
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "handle")
+			var count int
+			for _, kv := range got.Attributes {
+				if kv.Key == RequestDeadlineRemainingMsKey {
+					count++
+				}
+			}
+			if count > 1 {
+				t.Errorf("%s set %d times, want once", RequestDeadlineRemainingMsKey, count)
+			}
+			remaining, ok := attrValue(got.Attributes, RequestDeadlineRemainingMsKey)
+			if ok != tt.wantSet {
+				t.Fatalf("%s set = %v, want %v", RequestDeadlineRemainingMsKey, ok, tt.wantSet)
+			}
+			if !ok {
+				return
+			}
+			ms := remaining.AsInt64()
+			switch {
+			case tt.wantSign > 0 && (ms <= 0 || ms > tt.timeout.Milliseconds()):
+				t.Errorf("%s = %d, want within (0, %d]", RequestDeadlineRemainingMsKey, ms, tt.timeout.Milliseconds())
+			case tt.wantSign < 0 && ms >= 0:
+				t.Errorf("%s = %d, want negative", RequestDeadlineRemainingMsKey, ms)
+			}
+		})
+	}
+}
+
+func TestOnSpanStart(t *testing.T) {
+	type tenantKey struct{}
+
+	tests := []struct {
+		name      string
+		hook      func(ctx context.Context, name string) []attribute.KeyValue
+		ctx       context.Context
+		wantAttrs map[string]string
+		slow      bool
+	}{
+		{
+			name: "attribute from context",
+			hook: func(ctx context.Context, name string) []attribute.KeyValue {
+				tenant, _ := ctx.Value(tenantKey{}).(string)
+				return []attribute.KeyValue{attribute.String("tenant.id", tenant)}
+			},
+			ctx:       context.WithValue(context.Background(), tenantKey{}, "acme"),
+			wantAttrs: map[string]string{"tenant.id": "acme"},
+		},
+		{
+			name: "attribute from span name",
+			hook: func(ctx context.Context, name string) []attribute.KeyValue {
+				return []attribute.KeyValue{attribute.String("span.label", "hook:"+name)}
+			},
+			ctx:       context.Background(),
+			wantAttrs: map[string]string{"span.label": "hook:work"},
+		},
+		{
+			name:      "no attributes",
+			hook:      func(context.Context, string) []attribute.KeyValue { return nil },
+			ctx:       context.Background(),
+			wantAttrs: map[string]string{},
+		},
+		{
+			name: "slow hook logged",
+			hook: func(context.Context, string) []attribute.KeyValue {
+				time.Sleep(5 * time.Millisecond)
+				return nil
+			},
+			ctx:       context.Background(),
+			wantAttrs: map[string]string{},
+			slow:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.OnSpanStart = tt.hook
+				c.Debug = true
+			})
+
+			_, span := client.Tracer().StartSpan(tt.ctx, "work", SpanOptions{})
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "work")
+			for key, want := range tt.wantAttrs {
+				if value, _ := attrValue(got.Attributes, key); value.AsString() != want {
+					t.Errorf("%s = %q, want %q", key, value.AsString(), want)
+				}
+			}
+			if logged := strings.Contains(logs.String(), "OnSpanStart took"); logged != tt.slow {
+				t.Errorf("slow hook logged = %v, want %v", logged, tt.slow)
+			}
+		})
+	}
+}
+
+func TestSpanFilter(t *testing.T) {
+	dropInternal := WithSpanFilter(func(s sdktrace.ReadOnlySpan) bool {
+		return !strings.HasPrefix(s.Name(), "internal.")
+	})
+	stripPrompt := WithAttributeProcessor(func(attrs []attribute.KeyValue) []attribute.KeyValue {
+		kept := attrs[:0]
+		for _, attr := range attrs {
+			if attr.Key != LLMPromptKey {
+				kept = append(kept, attr)
+			}
+		}
+		return kept
+	})
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantSpans  []string
+		wantPrompt bool
+	}{
+		{name: "no filter", wantSpans: []string{"internal.cache", "llm.chat"}, wantPrompt: true},
+		{name: "filtered span dropped", opts: []Option{dropInternal}, wantSpans: []string{"llm.chat"}, wantPrompt: true},
+		{name: "attributes rewritten", opts: []Option{stripPrompt}, wantSpans: []string{"internal.cache", "llm.chat"}},
+		{name: "filter and rewrite", opts: []Option{dropInternal, stripPrompt}, wantSpans: []string{"llm.chat"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil, tt.opts...)
+
+			ctx := context.Background()
+			for _, name := range []string{"internal.cache", "llm.chat"} {
+				_, span := client.Tracer().StartSpan(ctx, name, SpanOptions{
+					Attributes: map[string]interface{}{LLMPromptKey: strings.Repeat("x", 4096), "llm.model": "gpt-4"},
+				})
+				span.End()
+			}
+
+			// Dropped spans are neither exported nor counted as exported
+			count, err := client.FlushWithCount(ctx)
+			if err != nil {
+				t.Fatalf("FlushWithCount() error = %v", err)
+			}
+			if count != len(tt.wantSpans) {
+				t.Errorf("FlushWithCount() = %d, want %d", count, len(tt.wantSpans))
+			}
+
+			spans := exporter.GetSpans()
+			if names := spanNames(spans); strings.Join(names, ",") != strings.Join(tt.wantSpans, ",") {
+				t.Fatalf("exported %v, want %v", names, tt.wantSpans)
+			}
+			for _, span := range spans {
+				if _, ok := attrValue(span.Attributes, LLMPromptKey); ok != tt.wantPrompt {
+					t.Errorf("%s: %s exported = %v, want %v", span.Name, LLMPromptKey, ok, tt.wantPrompt)
+				}
+				if model, _ := attrValue(span.Attributes, "llm.model"); model.AsString() != "gpt-4" {
+					t.Errorf("%s: llm.model = %q, want gpt-4", span.Name, model.AsString())
+				}
+			}
+		})
+	}
+}
+
+func TestSpanAttributes(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(c *Config)
+		opts      []Option
+		spanAttrs map[string]interface{}
+		want      map[string]attribute.Value
+	}{
+		{
+			name: "option",
+			opts: []Option{WithSpanAttributes(map[string]interface{}{"region": "eu-west-1", "cluster.size": 3})},
+			want: map[string]attribute.Value{
+				"region":       attribute.StringValue("eu-west-1"),
+				"cluster.size": attribute.IntValue(3),
+			},
+		},
+		{
+			name: "config merged with option",
+			configure: func(c *Config) {
+				c.SpanAttributes = map[string]interface{}{"git.commit": "abc123", "region": "us-east-1"}
+			},
+			opts: []Option{WithSpanAttributes(map[string]interface{}{"region": "eu-west-1"})},
+			want: map[string]attribute.Value{
+				"git.commit": attribute.StringValue("abc123"),
+				"region":     attribute.StringValue("eu-west-1"),
+			},
+		},
+		{
+			name:      "span start attributes take precedence",
+			opts:      []Option{WithSpanAttributes(map[string]interface{}{"region": "eu-west-1", "canary": false})},
+			spanAttrs: map[string]interface{}{"region": "ap-south-1"},
+			want: map[string]attribute.Value{
+				"region": attribute.StringValue("ap-south-1"),
+				"canary": attribute.BoolValue(false),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, tt.configure, tt.opts...)
+
+			_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{Attributes: tt.spanAttrs})
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "work")
+			for key, want := range tt.want {
+				value, ok := attrValue(got.Attributes, key)
+				if !ok || value != want {
+					t.Errorf("%s = %v, want %v", key, value.Emit(), want.Emit())
+				}
+			}
+			if name, _ := got.Resource.Set().Value("region"); name.AsString() != "" {
+				t.Errorf("span attribute region leaked into the resource")
+			}
+		})
+	}
+}
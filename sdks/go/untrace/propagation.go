@@ -0,0 +1,63 @@
+package untrace
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// defaultPropagator is used when no global propagator has been configured
+var defaultPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// Carriers for InjectContext and ExtractContext, e.g.
+// HeaderCarrier(req.Header) or MapCarrier(message.Headers)
+type (
+	HeaderCarrier = propagation.HeaderCarrier
+	MapCarrier    = propagation.MapCarrier
+)
+
+// InjectContext writes the trace context and baggage of ctx into carrier
+// using the global propagator
+func InjectContext(ctx context.Context, carrier propagation.TextMapCarrier) {
+	textMapPropagator().Inject(ctx, carrier)
+}
+
+// ExtractContext returns a copy of ctx carrying the trace context and baggage
+// read from carrier using the global propagator
+func ExtractContext(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return textMapPropagator().Extract(ctx, carrier)
+}
+
+// InjectCarrier writes the trace context and baggage of ctx into carrier,
+// e.g. the headers of a queue message, using the global propagator
+func InjectCarrier(ctx context.Context, carrier map[string]string) {
+	InjectContext(ctx, MapCarrier(carrier))
+}
+
+// ExtractCarrier returns a copy of ctx carrying the trace context and baggage
+// read from carrier, e.g. the headers of a consumed queue message
+func ExtractCarrier(ctx context.Context, carrier map[string]string) context.Context {
+	return ExtractContext(ctx, MapCarrier(carrier))
+}
+
+// installPropagator registers W3C trace context and baggage as the global
+// propagator unless one is already configured
+func installPropagator() {
+	if len(otel.GetTextMapPropagator().Fields()) == 0 {
+		otel.SetTextMapPropagator(defaultPropagator)
+	}
+}
+
+// textMapPropagator returns the global propagator, falling back to W3C trace
+// context and baggage while the global one is still the no-op default
+func textMapPropagator() propagation.TextMapPropagator {
+	propagator := otel.GetTextMapPropagator()
+	if len(propagator.Fields()) == 0 {
+		return defaultPropagator
+	}
+	return propagator
+}
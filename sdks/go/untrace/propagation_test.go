@@ -0,0 +1,136 @@
+package untrace
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCarrierPropagation(t *testing.T) {
+	tests := []struct {
+		name        string
+		withSpan    bool
+		baggage     map[string]string
+		wantHeaders []string
+	}{
+		{name: "trace context", withSpan: true, wantHeaders: []string{"traceparent"}},
+		{name: "trace context and baggage", withSpan: true, baggage: map[string]string{"tenant.id": "acme"}, wantHeaders: []string{"traceparent", "baggage"}},
+		{name: "nothing to propagate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			ctx := context.Background()
+			for key, value := range tt.baggage {
+				member, _ := baggage.NewMember(key, value)
+				bag, _ := baggage.FromContext(ctx).SetMember(member)
+				ctx = baggage.ContextWithBaggage(ctx, bag)
+			}
+			var producer trace.Span
+			if tt.withSpan {
+				ctx, producer = client.Tracer().StartSpan(ctx, "queue.publish", SpanOptions{})
+				producer.End()
+			}
+
+			headers := make(map[string]string)
+			InjectCarrier(ctx, headers)
+			for _, header := range tt.wantHeaders {
+				if headers[header] == "" {
+					t.Errorf("header %s not injected: %v", header, headers)
+				}
+			}
+			if len(tt.wantHeaders) == 0 && len(headers) != 0 {
+				t.Errorf("injected %v, want nothing", headers)
+			}
+
+			consumerCtx := ExtractCarrier(context.Background(), headers)
+			for key, want := range tt.baggage {
+				if got := baggage.FromContext(consumerCtx).Member(key).Value(); got != want {
+					t.Errorf("baggage %s = %q, want %q", key, got, want)
+				}
+			}
+
+			_, consumer := client.Tracer().StartSpan(consumerCtx, "queue.process", SpanOptions{})
+			consumer.End()
+
+			spans := exportedSpans(t, client, exporter)
+			got := findSpan(t, spans, "queue.process")
+			if !tt.withSpan {
+				if got.Parent.IsValid() {
+					t.Errorf("consumer span has a parent without propagated context")
+				}
+				return
+			}
+			publish := findSpan(t, spans, "queue.publish")
+			if got.SpanContext.TraceID() != publish.SpanContext.TraceID() || got.Parent.SpanID() != publish.SpanContext.SpanID() {
+				t.Errorf("consumer span is not a child of the producer span")
+			}
+			if !got.Parent.IsRemote() {
+				t.Errorf("extracted parent is not remote")
+			}
+		})
+	}
+}
+
+func TestInjectExtractContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		carrier func() propagation.TextMapCarrier
+		baggage map[string]string
+	}{
+		{name: "http headers", carrier: func() propagation.TextMapCarrier { return HeaderCarrier(http.Header{}) }},
+		{name: "map", carrier: func() propagation.TextMapCarrier { return MapCarrier{} }},
+		{
+			name:    "headers with baggage",
+			carrier: func() propagation.TextMapCarrier { return HeaderCarrier(http.Header{}) },
+			baggage: map[string]string{"workflow.run_id": "run-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTestClient(t, nil)
+
+			fields := otel.GetTextMapPropagator().Fields()
+			if !slices.Contains(fields, "traceparent") || !slices.Contains(fields, "baggage") {
+				t.Fatalf("global propagator fields = %v, want trace context and baggage", fields)
+			}
+
+			ctx := context.Background()
+			for key, value := range tt.baggage {
+				var err error
+				if ctx, err = WithBaggage(ctx, key, value); err != nil {
+					t.Fatalf("WithBaggage() error = %v", err)
+				}
+			}
+			ctx, producer := client.Tracer().StartSpan(ctx, "queue.publish", SpanOptions{})
+			defer producer.End()
+
+			carrier := tt.carrier()
+			InjectContext(ctx, carrier)
+			consumed := ExtractContext(context.Background(), carrier)
+
+			got := trace.SpanContextFromContext(consumed)
+			want := producer.SpanContext()
+			if got.TraceID() != want.TraceID() || got.SpanID() != want.SpanID() {
+				t.Errorf("extracted trace %s span %s, want trace %s span %s", got.TraceID(), got.SpanID(), want.TraceID(), want.SpanID())
+			}
+			if !got.IsRemote() {
+				t.Errorf("extracted span context is not remote")
+			}
+			for key, value := range tt.baggage {
+				if got := BaggageFromContext(consumed, key); got != value {
+					t.Errorf("baggage %s = %q, want %q", key, got, value)
+				}
+			}
+		})
+	}
+}
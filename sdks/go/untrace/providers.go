@@ -2,10 +2,13 @@ package untrace
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -30,18 +33,64 @@ type ProviderInstrumentation interface {
 // ProviderRegistry manages provider instrumentations
 type ProviderRegistry struct {
 	instrumentations map[string]ProviderInstrumentation
+	initialized      map[string]bool
+	client           Client
 }
 
 // NewProviderRegistry creates a new provider registry
 func NewProviderRegistry() *ProviderRegistry {
 	return &ProviderRegistry{
 		instrumentations: make(map[string]ProviderInstrumentation),
+		initialized:      make(map[string]bool),
 	}
 }
 
-// Register registers a provider instrumentation
-func (r *ProviderRegistry) Register(provider ProviderInstrumentation) {
-	r.instrumentations[provider.Name()] = provider
+// Register registers a provider instrumentation. Once InitializeAll has been
+// called, the provider is initialized with the registry's client.
+func (r *ProviderRegistry) Register(provider ProviderInstrumentation) error {
+	name := provider.Name()
+	r.instrumentations[name] = provider
+	delete(r.initialized, name)
+
+	if r.client == nil {
+		return nil
+	}
+	return r.initialize(name, provider)
+}
+
+// InitializeAll initializes every registered provider with the client, and
+// providers registered later as they are registered
+func (r *ProviderRegistry) InitializeAll(client Client) error {
+	r.client = client
+
+	var errs []error
+	for name, provider := range r.instrumentations {
+		if err := r.initialize(name, provider); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// initialize initializes a provider with the registry's client
+func (r *ProviderRegistry) initialize(name string, provider ProviderInstrumentation) error {
+	if err := provider.Initialize(r.client); err != nil {
+		return NewInstrumentationError("failed to initialize provider", name, err)
+	}
+	r.initialized[name] = true
+	return nil
+}
+
+// isInitialized reports whether a provider is ready to emit telemetry,
+// either through InitializeAll or its own Initialize
+func (r *ProviderRegistry) isInitialized(name string, provider ProviderInstrumentation) bool {
+	if r.initialized[name] {
+		return true
+	}
+	if base, ok := provider.(interface{ isEnabled() bool }); ok {
+		return base.isEnabled()
+	}
+	return false
 }
 
 // Get returns a provider instrumentation by name
@@ -70,9 +119,86 @@ func (r *ProviderRegistry) Instrument(name string, module interface{}) (interfac
 		return nil, fmt.Errorf("module cannot be instrumented by provider %s", name)
 	}
 
+	if !r.isInitialized(name, provider) {
+		return nil, NewInstrumentationError("provider is not initialized, call InitializeAll first", name, nil)
+	}
+
 	return provider.Instrument(module), nil
 }
 
+// InstrumentAs instruments a module with the named provider and returns the
+// wrapper as T, so callers keep a static type instead of asserting. T must be
+// an interface the provider's wrapper implements, such as OpenAIClient.
+func InstrumentAs[T any](r *ProviderRegistry, name string, module interface{}) (T, error) {
+	var zero T
+
+	instrumented, err := r.Instrument(name, module)
+	if err != nil {
+		return zero, err
+	}
+
+	typed, ok := instrumented.(T)
+	if !ok {
+		return zero, NewInstrumentationError(
+			fmt.Sprintf("%T does not implement %s", instrumented, reflect.TypeOf(&zero).Elem()), name, nil)
+	}
+	return typed, nil
+}
+
+// OpenAIChatClient is the chat completion API of an OpenAI client, generic
+// over its request and response types, e.g. openai.ChatCompletionRequest and
+// openai.ChatCompletionResponse
+type OpenAIChatClient[Req, Resp any] interface {
+	CreateChatCompletion(ctx context.Context, request Req) (Resp, error)
+}
+
+// InstrumentOpenAI instruments an OpenAI chat client and returns it with the
+// same static type, so CreateChatCompletion is called without assertions. T
+// must be an interface declaring only CreateChatCompletion; the request and
+// response types are inferred from that method.
+func InstrumentOpenAI[T OpenAIChatClient[Req, Resp], Req, Resp any](r *ProviderRegistry, client T) (T, error) {
+	var zero T
+
+	wrapper, err := InstrumentAs[*OpenAIWrapper](r, "openai", client)
+	if err != nil {
+		return zero, err
+	}
+
+	instrumented, ok := interface{}(&typedOpenAIClient[Req, Resp]{wrapper: wrapper}).(T)
+	if !ok {
+		return zero, NewInstrumentationError(
+			fmt.Sprintf("%s is not an interface of CreateChatCompletion", reflect.TypeOf(&zero).Elem()), "openai", nil)
+	}
+	return instrumented, nil
+}
+
+// typedOpenAIClient adapts an OpenAIWrapper to the request and response
+// types of the client it wraps
+type typedOpenAIClient[Req, Resp any] struct {
+	wrapper *OpenAIWrapper
+}
+
+// CreateChatCompletion traces a chat completion call
+func (c *typedOpenAIClient[Req, Resp]) CreateChatCompletion(ctx context.Context, request Req) (Resp, error) {
+	return TypedResponse[Resp](c.wrapper.CreateChatCompletion(ctx, request))
+}
+
+// TypedResponse asserts the response of a wrapped provider call to its
+// concrete type, passing the error through. Wrap a call with it directly:
+//
+//	resp, err := untrace.TypedResponse[openai.ChatCompletionResponse](wrapped.CreateChatCompletion(ctx, req))
+func TypedResponse[Resp any](response interface{}, err error) (Resp, error) {
+	var zero Resp
+	if err != nil {
+		return zero, err
+	}
+	typed, ok := response.(Resp)
+	if !ok {
+		return zero, fmt.Errorf("unexpected response type %T, want %s", response, reflect.TypeOf(&zero).Elem())
+	}
+	return typed, nil
+}
+
 // baseProviderInstrumentation provides common functionality for provider instrumentations
 type baseProviderInstrumentation struct {
 	name    string
@@ -112,27 +238,118 @@ func (b *baseProviderInstrumentation) createLLMSpan(ctx context.Context, name st
 	return b.client.Tracer().StartLLMSpan(ctx, name, opts)
 }
 
-// recordMetrics records metrics for the provider
-func (b *baseProviderInstrumentation) recordMetrics(usage TokenUsage, cost Cost, duration time.Duration, err error) {
+// recordMetrics records metrics for the provider against ctx, so they pick
+// up the cost dimensions and baggage of the LLM span in it
+func (b *baseProviderInstrumentation) recordMetrics(ctx context.Context, usage TokenUsage, cost Cost, duration time.Duration, err error) {
 	if !b.isEnabled() {
 		return
 	}
+	metrics := b.client.Metrics().WithContext(ctx)
 
 	if err != nil {
-		b.client.Metrics().RecordError(err, map[string]interface{}{
+		metrics.RecordError(err, map[string]interface{}{
 			"provider": usage.Provider,
 			"model":    usage.Model,
 		})
 	} else {
-		b.client.Metrics().RecordTokenUsage(usage)
-		b.client.Metrics().RecordCost(cost)
-		b.client.Metrics().RecordLatency(duration, map[string]interface{}{
+		metrics.RecordTokenUsage(usage)
+		metrics.RecordCost(cost)
+		metrics.RecordLatency(duration, map[string]interface{}{
 			"provider": usage.Provider,
 			"model":    usage.Model,
 		})
 	}
 }
 
+// traceCall invokes the named method of a provider client with ctx and args
+// within an LLM span and records the token usage, cost and latency of the
+// response. The method must return a response and an error; parse reads the
+// usage, model and request ID from a successful response.
+func (b *baseProviderInstrumentation) traceCall(ctx context.Context, client interface{}, method string, opts LLMSpanOptions, args []interface{}, parse func(response interface{}) (TokenUsage, string)) (interface{}, error) {
+	fn := reflect.ValueOf(client).MethodByName(method)
+	if !fn.IsValid() {
+		return nil, fmt.Errorf("client %T has no method %s", client, method)
+	}
+	fnType := fn.Type()
+	if fnType.NumIn() == 0 || fnType.In(0) != contextInterface || fnType.NumOut() != 2 || !fnType.Out(1).Implements(errorInterface) {
+		return nil, fmt.Errorf("method %s of %T does not take a context and return a response and error", method, client)
+	}
+
+	in := make([]reflect.Value, 0, len(args)+1)
+	in = append(in, reflect.Value{}) // the context, set by invoke
+	for n, arg := range args {
+		param := n + 1
+		var paramType reflect.Type
+		switch {
+		case fnType.IsVariadic() && param >= fnType.NumIn()-1:
+			paramType = fnType.In(fnType.NumIn() - 1).Elem()
+		case param < fnType.NumIn():
+			paramType = fnType.In(param)
+		default:
+			return nil, fmt.Errorf("method %s of %T takes %d arguments, got %d", method, client, fnType.NumIn()-1, len(args))
+		}
+		value := reflect.ValueOf(arg)
+		if !value.IsValid() && nillable(paramType) {
+			// An untyped nil stands for the nil request of a pointer or
+			// interface parameter
+			value = reflect.Zero(paramType)
+		}
+		if !value.IsValid() || !value.Type().AssignableTo(paramType) {
+			return nil, fmt.Errorf("method %s of %T does not accept an argument of type %T", method, client, arg)
+		}
+		in = append(in, value)
+	}
+
+	invoke := func(ctx context.Context) (interface{}, error) {
+		in[0] = reflect.ValueOf(ctx)
+		out := fn.Call(in)
+		err, _ := out[1].Interface().(error)
+		return out[0].Interface(), err
+	}
+
+	if !b.isEnabled() {
+		return invoke(ctx)
+	}
+
+	opts.Provider = b.name
+	ctx, span := b.createLLMSpan(ctx, fmt.Sprintf("%s.%s", b.name, opts.Operation), opts)
+	defer span.End()
+
+	start := time.Now()
+	response, err := invoke(ctx)
+	duration := time.Since(start)
+
+	usage := TokenUsage{Model: opts.Model, Provider: opts.Provider}
+	cost := Cost{Model: opts.Model, Provider: opts.Provider, Currency: "USD"}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		parsed, requestID := parse(response)
+		parsed.Provider = opts.Provider
+		if opts.Model != "" {
+			parsed.Model = opts.Model
+		}
+		usage = parsed
+
+		result := LLMResult{
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			TotalTokens:      usage.TotalTokens,
+			RequestID:        requestID,
+		}
+		if calculated, costErr := CalculateCost(usage.Provider, usage.Model, usage); costErr == nil {
+			cost = calculated
+			result.Cost = &cost
+		}
+		SetLLMResult(span, result)
+	}
+
+	b.recordMetrics(ctx, usage, cost, duration, err)
+
+	return response, err
+}
+
 // OpenAIInstrumentation provides instrumentation for OpenAI
 type OpenAIInstrumentation struct {
 	baseProviderInstrumentation
@@ -157,7 +374,7 @@ func (o *OpenAIInstrumentation) CanInstrument(module interface{}) bool {
 	}
 
 	// Look for common OpenAI client methods
-	methods := []string{"CreateChatCompletion", "CreateCompletion", "CreateEmbedding"}
+	methods := []string{"CreateChatCompletion", "CreateCompletion", "CreateEmbedding", "CreateEmbeddings"}
 	for _, method := range methods {
 		if _, exists := moduleType.MethodByName(method); exists {
 			return true
@@ -167,22 +384,77 @@ func (o *OpenAIInstrumentation) CanInstrument(module interface{}) bool {
 	return false
 }
 
-// Instrument instruments an OpenAI module
+// Instrument instruments an OpenAI module, returning an *OpenAIWrapper
 func (o *OpenAIInstrumentation) Instrument(module interface{}) interface{} {
-	// This is a simplified implementation
-	// In practice, you would use reflection or code generation to wrap methods
 	return &OpenAIWrapper{
 		client: module,
 		instrumentation: o,
 	}
 }
 
-// OpenAIWrapper wraps an OpenAI client with instrumentation
+// OpenAIClient is the instrumented OpenAI API, implemented by OpenAIWrapper
+type OpenAIClient interface {
+	CreateChatCompletion(ctx context.Context, request interface{}) (interface{}, error)
+	CreateCompletion(ctx context.Context, request interface{}) (interface{}, error)
+	CreateEmbedding(ctx context.Context, request interface{}) (interface{}, error)
+}
+
+// OpenAIWrapper wraps an OpenAI client with instrumentation. Its methods
+// delegate to the client methods of the same name, shaped like those of
+// github.com/sashabaranov/go-openai: they take a context and a request struct
+// with a Model field and return a response struct with a Usage field and an
+// error. Responses are returned as is, for the caller to type-assert.
 type OpenAIWrapper struct {
 	client         interface{}
 	instrumentation *OpenAIInstrumentation
 }
 
+// Ensure OpenAIWrapper implements OpenAIClient
+var _ OpenAIClient = (*OpenAIWrapper)(nil)
+
+// Client returns the wrapped client
+func (w *OpenAIWrapper) Client() interface{} {
+	return w.client
+}
+
+// CreateChatCompletion traces a chat completion call
+func (w *OpenAIWrapper) CreateChatCompletion(ctx context.Context, request interface{}) (interface{}, error) {
+	return w.call(ctx, "CreateChatCompletion", LLMOperationChat, request)
+}
+
+// CreateCompletion traces a completion call
+func (w *OpenAIWrapper) CreateCompletion(ctx context.Context, request interface{}) (interface{}, error) {
+	return w.call(ctx, "CreateCompletion", LLMOperationCompletion, request)
+}
+
+// CreateEmbedding traces an embedding call, delegating to the client's
+// CreateEmbeddings method, or CreateEmbedding if it has no such method
+func (w *OpenAIWrapper) CreateEmbedding(ctx context.Context, request interface{}) (interface{}, error) {
+	method := "CreateEmbeddings"
+	if !reflect.ValueOf(w.client).MethodByName(method).IsValid() {
+		method = "CreateEmbedding"
+	}
+	return w.call(ctx, method, LLMOperationEmbedding, request)
+}
+
+// call invokes the named client method within an LLM span and records the
+// token usage, cost and latency of the response
+func (w *OpenAIWrapper) call(ctx context.Context, method string, operation LLMOperationType, request interface{}) (interface{}, error) {
+	opts := LLMSpanOptions{
+		Model:     stringField(request, "Model"),
+		Operation: operation,
+	}
+	return w.instrumentation.traceCall(ctx, w.client, method, opts, []interface{}{request}, func(response interface{}) (TokenUsage, string) {
+		usage := TokenUsage{Model: stringField(response, "Model")}
+		if u := structField(response, "Usage"); u.IsValid() {
+			usage.PromptTokens = intField(u, "PromptTokens")
+			usage.CompletionTokens = intField(u, "CompletionTokens")
+			usage.TotalTokens = intField(u, "TotalTokens")
+		}
+		return usage, stringField(response, "ID")
+	})
+}
+
 // AnthropicInstrumentation provides instrumentation for Anthropic
 type AnthropicInstrumentation struct {
 	baseProviderInstrumentation
@@ -229,17 +501,163 @@ type AnthropicWrapper struct {
 	instrumentation *AnthropicInstrumentation
 }
 
+// GeminiInstrumentation provides instrumentation for Google Gemini
+type GeminiInstrumentation struct {
+	baseProviderInstrumentation
+}
+
+// NewGeminiInstrumentation creates a new Gemini instrumentation
+func NewGeminiInstrumentation() *GeminiInstrumentation {
+	return &GeminiInstrumentation{
+		baseProviderInstrumentation: baseProviderInstrumentation{
+			name: "gemini",
+		},
+	}
+}
+
+// CanInstrument checks if a module can be instrumented by Gemini
+func (g *GeminiInstrumentation) CanInstrument(module interface{}) bool {
+	moduleType := reflect.TypeOf(module)
+	if moduleType == nil {
+		return false
+	}
+
+	// Only GenerateContent is traced by the wrapper, so a model that can
+	// only stream is left alone
+	_, exists := moduleType.MethodByName("GenerateContent")
+	return exists
+}
+
+// Instrument instruments a Gemini model, returning a *GeminiWrapper
+func (g *GeminiInstrumentation) Instrument(module interface{}) interface{} {
+	return &GeminiWrapper{
+		model:           module,
+		instrumentation: g,
+	}
+}
+
+// GeminiWrapper wraps a Gemini model with instrumentation. Its methods
+// delegate to the model methods of the same name, shaped like those of
+// *genai.GenerativeModel from github.com/google/generative-ai-go: the model
+// name comes from its Name method and token usage from the UsageMetadata of
+// the response. Responses are returned as is, for the caller to type-assert.
+type GeminiWrapper struct {
+	model           interface{}
+	instrumentation *GeminiInstrumentation
+}
+
+// Model returns the wrapped model
+func (w *GeminiWrapper) Model() interface{} {
+	return w.model
+}
+
+// GenerateContent traces a content generation call
+func (w *GeminiWrapper) GenerateContent(ctx context.Context, parts ...interface{}) (interface{}, error) {
+	opts := LLMSpanOptions{
+		Model:     w.modelName(),
+		Operation: LLMOperationChat,
+	}
+	return w.instrumentation.traceCall(ctx, w.model, "GenerateContent", opts, parts, geminiUsage)
+}
+
+// modelName returns the name of the wrapped model, if it reports one
+func (w *GeminiWrapper) modelName() string {
+	name := reflect.ValueOf(w.model).MethodByName("Name")
+	if !name.IsValid() || name.Type().NumIn() != 0 || name.Type().NumOut() != 1 || name.Type().Out(0).Kind() != reflect.String {
+		return ""
+	}
+	// Names may be fully qualified, e.g. "models/gemini-1.5-pro"
+	model := name.Call(nil)[0].String()
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	return model
+}
+
+// geminiUsage maps the UsageMetadata of a Gemini response to token usage
+func geminiUsage(response interface{}) (TokenUsage, string) {
+	var usage TokenUsage
+	if u := structField(response, "UsageMetadata"); u.IsValid() {
+		usage.PromptTokens = intField(u, "PromptTokenCount")
+		usage.CompletionTokens = intField(u, "CandidatesTokenCount")
+		usage.TotalTokens = intField(u, "TotalTokenCount")
+	}
+	return usage, ""
+}
+
 // GetDefaultProviders returns the default set of providers
 func GetDefaultProviders() []ProviderInstrumentation {
 	return []ProviderInstrumentation{
 		NewOpenAIInstrumentation(),
 		NewAnthropicInstrumentation(),
+		NewGeminiInstrumentation(),
 	}
 }
 
 // RegisterDefaultProviders registers all default providers
-func RegisterDefaultProviders(registry *ProviderRegistry) {
+func RegisterDefaultProviders(registry *ProviderRegistry) error {
+	var errs []error
 	for _, provider := range GetDefaultProviders() {
-		registry.Register(provider)
+		if err := registry.Register(provider); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// errorInterface is the reflected error interface type
+var errorInterface = reflect.TypeOf((*error)(nil)).Elem()
+
+// contextInterface is the reflected context.Context interface type
+var contextInterface = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// nillable reports whether nil is a valid value of type t
+func nillable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return true
+	}
+	return false
+}
+
+// structField returns the named field of a struct or pointer to struct, or
+// the zero Value if there is none
+func structField(value interface{}, name string) reflect.Value {
+	v, ok := value.(reflect.Value)
+	if !ok {
+		v = reflect.ValueOf(value)
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return v.FieldByName(name)
+}
+
+// stringField returns the named string field of a struct, or ""
+func stringField(value interface{}, name string) string {
+	f := structField(value, name)
+	if !f.IsValid() || f.Kind() != reflect.String {
+		return ""
+	}
+	return f.String()
+}
+
+// intField returns the named integer field of a struct, or 0
+func intField(value interface{}, name string) int {
+	f := structField(value, name)
+	if !f.IsValid() {
+		return 0
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(f.Int())
+	default:
+		return 0
 	}
 }
@@ -0,0 +1,635 @@
+package untrace
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Fake request and response types shaped like github.com/sashabaranov/go-openai
+type (
+	fakeOpenAIRequest struct {
+		Model string
+	}
+
+	fakeOpenAIUsage struct {
+		PromptTokens     int
+		CompletionTokens int
+		TotalTokens      int
+	}
+
+	fakeOpenAIResponse struct {
+		ID    string
+		Model string
+		Usage fakeOpenAIUsage
+	}
+)
+
+// fakeOpenAIClient answers every call with a fixed usage, or fails with err
+type fakeOpenAIClient struct {
+	err error
+}
+
+// respond returns the canned response for a request
+func (c *fakeOpenAIClient) respond(request fakeOpenAIRequest, completion int) (fakeOpenAIResponse, error) {
+	if c.err != nil {
+		return fakeOpenAIResponse{}, c.err
+	}
+	return fakeOpenAIResponse{
+		ID:    "chatcmpl-123",
+		Model: request.Model + "-0613",
+		Usage: fakeOpenAIUsage{PromptTokens: 40, CompletionTokens: completion, TotalTokens: 40 + completion},
+	}, nil
+}
+
+// CreateChatCompletion answers a chat completion request
+func (c *fakeOpenAIClient) CreateChatCompletion(ctx context.Context, request fakeOpenAIRequest) (fakeOpenAIResponse, error) {
+	return c.respond(request, 10)
+}
+
+// CreateCompletion answers a completion request
+func (c *fakeOpenAIClient) CreateCompletion(ctx context.Context, request fakeOpenAIRequest) (fakeOpenAIResponse, error) {
+	return c.respond(request, 10)
+}
+
+// CreateEmbeddings answers an embedding request, which has no completion
+func (c *fakeOpenAIClient) CreateEmbeddings(ctx context.Context, request fakeOpenAIRequest) (fakeOpenAIResponse, error) {
+	return c.respond(request, 0)
+}
+
+func TestOpenAIWrapper(t *testing.T) {
+	tests := []struct {
+		name           string
+		call           func(w *OpenAIWrapper, ctx context.Context, request interface{}) (interface{}, error)
+		model          string
+		err            error
+		wantSpan       string
+		wantOperation  LLMOperationType
+		wantCompletion float64
+	}{
+		{
+			name:           "chat completion",
+			call:           (*OpenAIWrapper).CreateChatCompletion,
+			model:          "gpt-4",
+			wantSpan:       "openai.chat",
+			wantOperation:  LLMOperationChat,
+			wantCompletion: 10,
+		},
+		{
+			name:           "completion",
+			call:           (*OpenAIWrapper).CreateCompletion,
+			model:          "gpt-3.5-turbo",
+			wantSpan:       "openai.completion",
+			wantOperation:  LLMOperationCompletion,
+			wantCompletion: 10,
+		},
+		{
+			name:          "embedding",
+			call:          (*OpenAIWrapper).CreateEmbedding,
+			model:         "text-embedding-3-small",
+			wantSpan:      "openai.embedding",
+			wantOperation: LLMOperationEmbedding,
+		},
+		{
+			name:          "client error",
+			call:          (*OpenAIWrapper).CreateChatCompletion,
+			model:         "gpt-4",
+			err:           errors.New("429 too many requests"),
+			wantSpan:      "openai.chat",
+			wantOperation: LLMOperationChat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			instrumentation := NewOpenAIInstrumentation()
+			if err := instrumentation.Initialize(client); err != nil {
+				t.Fatalf("Initialize() error = %v", err)
+			}
+			wrapper := instrumentation.Instrument(&fakeOpenAIClient{err: tt.err}).(*OpenAIWrapper)
+
+			response, err := tt.call(wrapper, context.Background(), fakeOpenAIRequest{Model: tt.model})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("call error = %v, want %v", err, tt.err)
+			}
+
+			span := findSpan(t, exportedSpans(t, client, exporter), tt.wantSpan)
+			wantAttrs := map[string]string{
+				LLMProviderKey:      "openai",
+				LLMModelKey:         tt.model,
+				LLMOperationTypeKey: string(tt.wantOperation),
+			}
+			for key, want := range wantAttrs {
+				if value, _ := attrValue(span.Attributes, key); value.AsString() != want {
+					t.Errorf("%s = %q, want %q", key, value.AsString(), want)
+				}
+			}
+
+			if tt.err != nil {
+				if span.Status.Code != codes.Error {
+					t.Errorf("status = %v, want %v", span.Status.Code, codes.Error)
+				}
+				if _, ok := findMetric(t, client.snapshotReader, "llm.prompt.tokens"); ok {
+					t.Errorf("llm.prompt.tokens recorded for a failed call")
+				}
+				return
+			}
+
+			if got, ok := response.(fakeOpenAIResponse); !ok || got.ID != "chatcmpl-123" {
+				t.Errorf("response = %#v, want the client's response", response)
+			}
+			if id, _ := attrValue(span.Attributes, LLMRequestIDKey); id.AsString() != "chatcmpl-123" {
+				t.Errorf("%s = %q, want chatcmpl-123", LLMRequestIDKey, id.AsString())
+			}
+			if prompt := sumValue(t, collectMetric(t, client.snapshotReader, "llm.prompt.tokens")); prompt != 40 {
+				t.Errorf("llm.prompt.tokens = %v, want 40", prompt)
+			}
+			var completion float64
+			if m, ok := findMetric(t, client.snapshotReader, "llm.completion.tokens"); ok {
+				completion = sumValue(t, m)
+			}
+			if completion != tt.wantCompletion {
+				t.Errorf("llm.completion.tokens = %v, want %v", completion, tt.wantCompletion)
+			}
+		})
+	}
+}
+
+// fakeCallClient has methods of the shapes traceCall must accept or reject
+type fakeCallClient struct{}
+
+// Complete answers a request passed by pointer, which may be nil
+func (c *fakeCallClient) Complete(ctx context.Context, request *fakeOpenAIRequest) (fakeOpenAIResponse, error) {
+	if request == nil {
+		return fakeOpenAIResponse{ID: "default"}, nil
+	}
+	return fakeOpenAIResponse{ID: request.Model}, nil
+}
+
+// CompleteAny answers a request passed as an interface, which may be nil
+func (c *fakeCallClient) CompleteAny(ctx context.Context, request interface{}) (fakeOpenAIResponse, error) {
+	return fakeOpenAIResponse{ID: "any"}, nil
+}
+
+// CompleteValue answers a request passed by value
+func (c *fakeCallClient) CompleteValue(ctx context.Context, request fakeOpenAIRequest) (fakeOpenAIResponse, error) {
+	return fakeOpenAIResponse{ID: request.Model}, nil
+}
+
+// CompleteNoContext takes the request where the context belongs
+func (c *fakeCallClient) CompleteNoContext(request *fakeOpenAIRequest, model string) (fakeOpenAIResponse, error) {
+	return fakeOpenAIResponse{}, nil
+}
+
+func TestTraceCall(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		args    []interface{}
+		wantID  string
+		wantErr bool
+	}{
+		{name: "pointer request", method: "Complete", args: []interface{}{&fakeOpenAIRequest{Model: "gpt-4"}}, wantID: "gpt-4"},
+		{name: "nil pointer request", method: "Complete", args: []interface{}{nil}, wantID: "default"},
+		{name: "nil interface request", method: "CompleteAny", args: []interface{}{nil}, wantID: "any"},
+		{name: "nil value request", method: "CompleteValue", args: []interface{}{nil}, wantErr: true},
+		{name: "wrong request type", method: "CompleteValue", args: []interface{}{"gpt-4"}, wantErr: true},
+		{name: "no context parameter", method: "CompleteNoContext", args: []interface{}{"gpt-4"}, wantErr: true},
+		{name: "missing method", method: "Stream", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTestClient(t, nil)
+			instrumentation := NewOpenAIInstrumentation()
+			if err := instrumentation.Initialize(client); err != nil {
+				t.Fatalf("Initialize() error = %v", err)
+			}
+
+			response, err := instrumentation.traceCall(context.Background(), &fakeCallClient{}, tt.method,
+				LLMSpanOptions{Model: "gpt-4", Operation: LLMOperationChat}, tt.args,
+				func(interface{}) (TokenUsage, string) { return TokenUsage{}, "" })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("traceCall() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got, ok := response.(fakeOpenAIResponse); !ok || got.ID != tt.wantID {
+				t.Errorf("response = %#v, want ID %q", response, tt.wantID)
+			}
+		})
+	}
+}
+
+// Fake types shaped like github.com/google/generative-ai-go/genai
+type (
+	fakeGeminiText string
+
+	fakeGeminiUsageMetadata struct {
+		PromptTokenCount     int32
+		CandidatesTokenCount int32
+		TotalTokenCount      int32
+	}
+
+	fakeGeminiResponse struct {
+		UsageMetadata *fakeGeminiUsageMetadata
+	}
+)
+
+// fakeGeminiModel answers every call with a fixed usage, or fails with err
+type fakeGeminiModel struct {
+	name  string
+	usage *fakeGeminiUsageMetadata
+	err   error
+}
+
+// Name returns the model name
+func (m *fakeGeminiModel) Name() string {
+	return m.name
+}
+
+// GenerateContent answers a content generation request
+func (m *fakeGeminiModel) GenerateContent(ctx context.Context, parts ...fakeGeminiText) (*fakeGeminiResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &fakeGeminiResponse{UsageMetadata: m.usage}, nil
+}
+
+// fakeGeminiStreamModel only streams generated content
+type fakeGeminiStreamModel struct{}
+
+// GenerateContentStream is never called; it only marks the model shape
+func (m *fakeGeminiStreamModel) GenerateContentStream(ctx context.Context, parts ...fakeGeminiText) *fakeGeminiResponse {
+	return nil
+}
+
+func TestGeminiCanInstrument(t *testing.T) {
+	tests := []struct {
+		name   string
+		module interface{}
+		want   bool
+	}{
+		{name: "generative model", module: &fakeGeminiModel{}, want: true},
+		{name: "streaming-only model", module: &fakeGeminiStreamModel{}},
+		{name: "openai client", module: &fakeOpenAIClient{}},
+		{name: "nil module", module: nil},
+	}
+
+	instrumentation := NewGeminiInstrumentation()
+	if name := instrumentation.Name(); name != "gemini" {
+		t.Fatalf("Name() = %q, want gemini", name)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := instrumentation.CanInstrument(tt.module); got != tt.want {
+				t.Errorf("CanInstrument() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeminiWrapper(t *testing.T) {
+	tests := []struct {
+		name           string
+		model          string
+		usage          *fakeGeminiUsageMetadata
+		err            error
+		wantModel      string
+		wantPrompt     int64
+		wantCompletion int64
+		wantTotal      int64
+	}{
+		{
+			name:           "qualified model name",
+			model:          "models/gemini-1.5-pro",
+			usage:          &fakeGeminiUsageMetadata{PromptTokenCount: 12, CandidatesTokenCount: 30, TotalTokenCount: 42},
+			wantModel:      "gemini-1.5-pro",
+			wantPrompt:     12,
+			wantCompletion: 30,
+			wantTotal:      42,
+		},
+		{
+			name:           "bare model name",
+			model:          "gemini-1.5-flash",
+			usage:          &fakeGeminiUsageMetadata{PromptTokenCount: 5, CandidatesTokenCount: 7, TotalTokenCount: 12},
+			wantModel:      "gemini-1.5-flash",
+			wantPrompt:     5,
+			wantCompletion: 7,
+			wantTotal:      12,
+		},
+		{
+			name:      "no usage metadata",
+			model:     "gemini-1.5-pro",
+			wantModel: "gemini-1.5-pro",
+		},
+		{
+			name:      "model error",
+			model:     "gemini-1.5-pro",
+			err:       errors.New("429 resource exhausted"),
+			wantModel: "gemini-1.5-pro",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			instrumentation := NewGeminiInstrumentation()
+			if err := instrumentation.Initialize(client); err != nil {
+				t.Fatalf("Initialize() error = %v", err)
+			}
+			model := &fakeGeminiModel{name: tt.model, usage: tt.usage, err: tt.err}
+			wrapper := instrumentation.Instrument(model).(*GeminiWrapper)
+
+			response, err := wrapper.GenerateContent(context.Background(), fakeGeminiText("Hello"), fakeGeminiText("Gemini"))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("GenerateContent() error = %v, want %v", err, tt.err)
+			}
+
+			span := findSpan(t, exportedSpans(t, client, exporter), "gemini.chat")
+			wantAttrs := map[string]string{
+				LLMProviderKey:      "gemini",
+				LLMModelKey:         tt.wantModel,
+				LLMOperationTypeKey: string(LLMOperationChat),
+			}
+			for key, want := range wantAttrs {
+				if value, _ := attrValue(span.Attributes, key); value.AsString() != want {
+					t.Errorf("%s = %q, want %q", key, value.AsString(), want)
+				}
+			}
+
+			if tt.err != nil {
+				if span.Status.Code != codes.Error {
+					t.Errorf("status = %v, want %v", span.Status.Code, codes.Error)
+				}
+				return
+			}
+
+			if _, ok := response.(*fakeGeminiResponse); !ok {
+				t.Errorf("response = %#v, want the model's response", response)
+			}
+			wantTokens := map[string]int64{
+				LLMPromptTokensKey:     tt.wantPrompt,
+				LLMCompletionTokensKey: tt.wantCompletion,
+				LLMTotalTokensKey:      tt.wantTotal,
+			}
+			for key, want := range wantTokens {
+				if value, _ := attrValue(span.Attributes, key); value.AsInt64() != want {
+					t.Errorf("%s = %d, want %d", key, value.AsInt64(), want)
+				}
+			}
+			if tt.wantPrompt > 0 {
+				if prompt := sumValue(t, collectMetric(t, client.snapshotReader, "llm.prompt.tokens")); prompt != float64(tt.wantPrompt) {
+					t.Errorf("llm.prompt.tokens metric = %v, want %d", prompt, tt.wantPrompt)
+				}
+			}
+		})
+	}
+}
+
+// fakeChatCompleter is the chat API of fakeOpenAIClient, as an application
+// would declare it
+type fakeChatCompleter interface {
+	CreateChatCompletion(ctx context.Context, request fakeOpenAIRequest) (fakeOpenAIResponse, error)
+}
+
+// newOpenAIRegistry returns a registry with the OpenAI provider registered,
+// initialized with client unless it is nil
+func newOpenAIRegistry(t *testing.T, client Client) *ProviderRegistry {
+	t.Helper()
+
+	registry := NewProviderRegistry()
+	if err := registry.Register(NewOpenAIInstrumentation()); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if client != nil {
+		if err := registry.InitializeAll(client); err != nil {
+			t.Fatalf("InitializeAll() error = %v", err)
+		}
+	}
+	return registry
+}
+
+func TestInstrumentOpenAI(t *testing.T) {
+	tests := []struct {
+		name       string
+		initialize bool
+		err        error
+		wantErr    bool
+	}{
+		{name: "typed client", initialize: true},
+		{name: "client error passed through", initialize: true, err: errors.New("429 too many requests")},
+		{name: "uninitialized registry", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			var initClient Client
+			if tt.initialize {
+				initClient = client
+			}
+			registry := newOpenAIRegistry(t, initClient)
+
+			var chat fakeChatCompleter = &fakeOpenAIClient{err: tt.err}
+			instrumented, err := InstrumentOpenAI(registry, chat)
+			if tt.wantErr {
+				var instrumentationErr *InstrumentationError
+				if !errors.As(err, &instrumentationErr) {
+					t.Errorf("InstrumentOpenAI() error = %v, want an InstrumentationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InstrumentOpenAI() error = %v", err)
+			}
+
+			// The instrumented client keeps the static type of the original
+			chat = instrumented
+			response, err := chat.CreateChatCompletion(context.Background(), fakeOpenAIRequest{Model: "gpt-4"})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("CreateChatCompletion() error = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && response.ID != "chatcmpl-123" {
+				t.Errorf("response ID = %q, want chatcmpl-123", response.ID)
+			}
+			findSpan(t, exportedSpans(t, client, exporter), "openai.chat")
+		})
+	}
+}
+
+func TestInstrumentAs(t *testing.T) {
+	tests := []struct {
+		name       string
+		instrument func(r *ProviderRegistry) (interface{}, error)
+		wantErr    bool
+	}{
+		{
+			name: "wrapper interface",
+			instrument: func(r *ProviderRegistry) (interface{}, error) {
+				return InstrumentAs[OpenAIClient](r, "openai", &fakeOpenAIClient{})
+			},
+		},
+		{
+			name: "wrapper type",
+			instrument: func(r *ProviderRegistry) (interface{}, error) {
+				return InstrumentAs[*OpenAIWrapper](r, "openai", &fakeOpenAIClient{})
+			},
+		},
+		{
+			name: "type the wrapper does not implement",
+			instrument: func(r *ProviderRegistry) (interface{}, error) {
+				return InstrumentAs[fakeChatCompleter](r, "openai", &fakeOpenAIClient{})
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown provider",
+			instrument: func(r *ProviderRegistry) (interface{}, error) {
+				return InstrumentAs[OpenAIClient](r, "cohere", &fakeOpenAIClient{})
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTestClient(t, nil)
+			registry := newOpenAIRegistry(t, client)
+
+			got, err := tt.instrument(registry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InstrumentAs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got == nil {
+				t.Errorf("InstrumentAs() = nil, want the wrapper")
+			}
+		})
+	}
+}
+
+func TestTypedResponse(t *testing.T) {
+	callErr := errors.New("upstream failure")
+
+	tests := []struct {
+		name     string
+		response interface{}
+		err      error
+		wantErr  bool
+	}{
+		{name: "matching type", response: fakeOpenAIResponse{ID: "chatcmpl-123"}},
+		{name: "mismatched type", response: "chatcmpl-123", wantErr: true},
+		{name: "call error", err: callErr, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TypedResponse[fakeOpenAIResponse](tt.response, tt.err)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TypedResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("TypedResponse() error = %v, want %v", err, tt.err)
+			}
+			if !tt.wantErr && got.ID != "chatcmpl-123" {
+				t.Errorf("TypedResponse() ID = %q, want chatcmpl-123", got.ID)
+			}
+		})
+	}
+}
+
+// failingProvider is an OpenAI instrumentation whose Initialize fails
+type failingProvider struct {
+	*OpenAIInstrumentation
+	err error
+}
+
+// Initialize fails with the provider's error
+func (p *failingProvider) Initialize(client Client) error {
+	return p.err
+}
+
+func TestProviderRegistryInitialization(t *testing.T) {
+	initErr := errors.New("missing API key")
+
+	tests := []struct {
+		name         string
+		provider     func() ProviderInstrumentation
+		initialize   bool
+		registerLate bool
+		wantInitErr  bool
+		wantErr      bool
+	}{
+		{name: "uninitialized registry", provider: func() ProviderInstrumentation { return NewOpenAIInstrumentation() }, wantErr: true},
+		{name: "initialized registry", provider: func() ProviderInstrumentation { return NewOpenAIInstrumentation() }, initialize: true},
+		{name: "registered after InitializeAll", provider: func() ProviderInstrumentation { return NewOpenAIInstrumentation() }, initialize: true, registerLate: true},
+		{
+			name: "provider fails to initialize",
+			provider: func() ProviderInstrumentation {
+				return &failingProvider{OpenAIInstrumentation: NewOpenAIInstrumentation(), err: initErr}
+			},
+			initialize:  true,
+			wantInitErr: true,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			registry := NewProviderRegistry()
+			provider := tt.provider()
+
+			var initErrs []error
+			register := func() {
+				if err := registry.Register(provider); err != nil {
+					initErrs = append(initErrs, err)
+				}
+			}
+			if !tt.registerLate {
+				register()
+			}
+			if tt.initialize {
+				if err := registry.InitializeAll(client); err != nil {
+					initErrs = append(initErrs, err)
+				}
+			}
+			if tt.registerLate {
+				register()
+			}
+
+			gotInitErr := errors.Join(initErrs...)
+			if tt.wantInitErr {
+				var instrumentationErr *InstrumentationError
+				if !errors.As(gotInitErr, &instrumentationErr) || !errors.Is(gotInitErr, initErr) {
+					t.Errorf("initialization error = %v, want an InstrumentationError wrapping %v", gotInitErr, initErr)
+				}
+			} else if gotInitErr != nil {
+				t.Fatalf("initialization error = %v", gotInitErr)
+			}
+
+			instrumented, err := registry.Instrument("openai", &fakeOpenAIClient{})
+			if tt.wantErr {
+				var instrumentationErr *InstrumentationError
+				if !errors.As(err, &instrumentationErr) {
+					t.Errorf("Instrument() error = %v, want an InstrumentationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Instrument() error = %v", err)
+			}
+
+			wrapper := instrumented.(*OpenAIWrapper)
+			if _, err := wrapper.CreateChatCompletion(context.Background(), fakeOpenAIRequest{Model: "gpt-4"}); err != nil {
+				t.Fatalf("CreateChatCompletion() error = %v", err)
+			}
+			findSpan(t, exportedSpans(t, client, exporter), "openai.chat")
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package untrace
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RAG pipeline stages
+const (
+	RAGStageEmbed    = "embed"
+	RAGStageRetrieve = "retrieve"
+	RAGStageRerank   = "rerank"
+	RAGStageGenerate = "generate"
+)
+
+// RAGOptions represents options for tracing a RAG pipeline
+type RAGOptions struct {
+	// Name of the pipeline span. Defaults to "rag.pipeline"
+	Name string
+
+	// Provider and model of the query embedding
+	EmbeddingProvider string
+	EmbeddingModel    string
+
+	// Vector DB system queried for retrieval and the number of results
+	// requested
+	VectorSystem string
+	TopK         int
+
+	// Provider and model of the answer generation
+	Provider string
+	Model    string
+
+	Attributes map[string]interface{}
+}
+
+// RAGUsage represents the tokens and USD cost consumed by a RAG stage
+type RAGUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+}
+
+// RAGSteps holds the stages of a RAG pipeline. Stages run in order; a nil
+// stage is skipped.
+type RAGSteps struct {
+	Embed    func(ctx context.Context) (RAGUsage, error)
+	Retrieve func(ctx context.Context) (RAGUsage, error)
+	Rerank   func(ctx context.Context) (RAGUsage, error)
+	Generate func(ctx context.Context) (RAGUsage, error)
+}
+
+// TraceRAGPipeline traces a RAG pipeline as a parent span with a child span
+// per stage: query embedding, retrieval, reranking and generation. Token and
+// cost usage of the stages is recorded on their spans and summed onto the
+// parent. The pipeline stops at the first failing stage, whose error is
+// returned.
+func (i *Instrumentation) TraceRAGPipeline(ctx context.Context, opts RAGOptions, steps RAGSteps) error {
+	stages := []struct {
+		name string
+		fn   func(ctx context.Context) (RAGUsage, error)
+	}{
+		{RAGStageEmbed, steps.Embed},
+		{RAGStageRetrieve, steps.Retrieve},
+		{RAGStageRerank, steps.Rerank},
+		{RAGStageGenerate, steps.Generate},
+	}
+
+	if !i.config.Enabled {
+		for _, stage := range stages {
+			if stage.fn == nil {
+				continue
+			}
+			if _, err := stage.fn(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = "rag.pipeline"
+	}
+
+	ctx, span := i.client.Tracer().StartSpan(ctx, name, SpanOptions{
+		Attributes: opts.Attributes,
+	})
+	defer span.End()
+
+	start := time.Now()
+	var total RAGUsage
+	var err error
+	for _, stage := range stages {
+		if stage.fn == nil {
+			continue
+		}
+
+		var usage RAGUsage
+		usage, err = i.traceRAGStage(ctx, stage.name, opts, stage.fn)
+		total.PromptTokens += usage.PromptTokens
+		total.CompletionTokens += usage.CompletionTokens
+		total.Cost += usage.Cost
+		if err != nil {
+			break
+		}
+	}
+
+	// Aggregates use rag.* keys so cost isn't counted twice alongside the
+	// stage spans' llm.* attributes
+	span.SetAttributes(
+		attribute.Int(RAGTotalTokensKey, total.PromptTokens+total.CompletionTokens),
+		attribute.Float64(RAGCostTotalKey, total.Cost),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	// Record metrics
+	i.recordOutcome(ctx, err, time.Since(start), map[string]interface{}{
+		"function": name,
+	})
+
+	return err
+}
+
+// traceRAGStage runs a single RAG stage in its own span
+func (i *Instrumentation) traceRAGStage(ctx context.Context, stage string, opts RAGOptions, fn func(ctx context.Context) (RAGUsage, error)) (RAGUsage, error) {
+	attrs := []attribute.KeyValue{attribute.String(RAGStageKey, stage)}
+	switch stage {
+	case RAGStageEmbed:
+		attrs = append(attrs, CreateLLMAttributes(opts.EmbeddingProvider, opts.EmbeddingModel, LLMOperationEmbedding)...)
+	case RAGStageRetrieve:
+		attrs = append(attrs, CreateVectorDBAttributes(opts.VectorSystem, "query")...)
+		if opts.TopK > 0 {
+			attrs = append(attrs, attribute.Int(VectorQueryKKey, opts.TopK))
+		}
+	case RAGStageGenerate:
+		attrs = append(attrs, CreateLLMAttributes(opts.Provider, opts.Model, LLMOperationChat)...)
+	}
+
+	ctx, span := i.client.Tracer().GetTracer().Start(ctx, "rag."+stage, trace.WithAttributes(attrs...))
+	defer span.End()
+
+	usage, err := fn(ctx)
+
+	if tokens := usage.PromptTokens + usage.CompletionTokens; tokens > 0 {
+		span.SetAttributes(
+			attribute.Int(LLMPromptTokensKey, usage.PromptTokens),
+			attribute.Int(LLMCompletionTokensKey, usage.CompletionTokens),
+			attribute.Int(LLMTotalTokensKey, tokens),
+		)
+	}
+	if usage.Cost > 0 {
+		span.SetAttributes(attribute.Float64(LLMCostTotalKey, usage.Cost))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return usage, err
+}
@@ -0,0 +1,120 @@
+package untrace
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestTraceRAGPipeline(t *testing.T) {
+	errRetrieval := errors.New("index unavailable")
+	stage := func(usage RAGUsage, err error) func(context.Context) (RAGUsage, error) {
+		return func(context.Context) (RAGUsage, error) { return usage, err }
+	}
+
+	tests := []struct {
+		name       string
+		steps      RAGSteps
+		wantStages []string
+		wantTokens int64
+		wantCost   float64
+		wantErr    error
+	}{
+		{
+			name: "all stages",
+			steps: RAGSteps{
+				Embed:    stage(RAGUsage{PromptTokens: 12, Cost: 0.0001}, nil),
+				Retrieve: stage(RAGUsage{}, nil),
+				Rerank:   stage(RAGUsage{}, nil),
+				Generate: stage(RAGUsage{PromptTokens: 900, CompletionTokens: 150, Cost: 0.036}, nil),
+			},
+			wantStages: []string{"rag.embed", "rag.retrieve", "rag.rerank", "rag.generate"},
+			wantTokens: 1062,
+			wantCost:   0.0361,
+		},
+		{
+			name: "no rerank",
+			steps: RAGSteps{
+				Embed:    stage(RAGUsage{PromptTokens: 12}, nil),
+				Retrieve: stage(RAGUsage{}, nil),
+				Generate: stage(RAGUsage{PromptTokens: 500, CompletionTokens: 50}, nil),
+			},
+			wantStages: []string{"rag.embed", "rag.retrieve", "rag.generate"},
+			wantTokens: 562,
+		},
+		{
+			name: "failing stage stops the pipeline",
+			steps: RAGSteps{
+				Embed:    stage(RAGUsage{PromptTokens: 12, Cost: 0.0001}, nil),
+				Retrieve: stage(RAGUsage{}, errRetrieval),
+				Generate: stage(RAGUsage{PromptTokens: 500}, nil),
+			},
+			wantStages: []string{"rag.embed", "rag.retrieve"},
+			wantTokens: 12,
+			wantCost:   0.0001,
+			wantErr:    errRetrieval,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			instr := NewInstrumentation(client, DefaultInstrumentationConfig())
+
+			err := instr.TraceRAGPipeline(context.Background(), RAGOptions{
+				EmbeddingProvider: "openai",
+				EmbeddingModel:    "text-embedding-3-small",
+				VectorSystem:      "pinecone",
+				TopK:              5,
+				Provider:          "openai",
+				Model:             "gpt-4",
+			}, tt.steps)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TraceRAGPipeline() error = %v, want %v", err, tt.wantErr)
+			}
+
+			spans := exportedSpans(t, client, exporter)
+			pipeline := findSpan(t, spans, "rag.pipeline")
+
+			var stages []string
+			for _, span := range spans {
+				if span.Name == "rag.pipeline" {
+					continue
+				}
+				if span.Parent.SpanID() != pipeline.SpanContext.SpanID() {
+					t.Errorf("%s is not a child of the pipeline span", span.Name)
+				}
+				stages = append(stages, span.Name)
+			}
+			if len(stages) != len(tt.wantStages) {
+				t.Fatalf("stage spans = %v, want %v", stages, tt.wantStages)
+			}
+			for i := range stages {
+				if stages[i] != tt.wantStages[i] {
+					t.Errorf("stage spans = %v, want %v", stages, tt.wantStages)
+					break
+				}
+			}
+
+			if tokens, _ := attrValue(pipeline.Attributes, RAGTotalTokensKey); tokens.AsInt64() != tt.wantTokens {
+				t.Errorf("%s = %d, want %d", RAGTotalTokensKey, tokens.AsInt64(), tt.wantTokens)
+			}
+			if cost, _ := attrValue(pipeline.Attributes, RAGCostTotalKey); math.Abs(cost.AsFloat64()-tt.wantCost) > 1e-9 {
+				t.Errorf("%s = %v, want %v", RAGCostTotalKey, cost.AsFloat64(), tt.wantCost)
+			}
+			if _, ok := attrValue(pipeline.Attributes, LLMCostTotalKey); ok {
+				t.Errorf("pipeline span carries %s, double counting its stages", LLMCostTotalKey)
+			}
+			wantCode := codes.Unset
+			if tt.wantErr != nil {
+				wantCode = codes.Error
+			}
+			if pipeline.Status.Code != wantCode {
+				t.Errorf("pipeline status = %v, want %v", pipeline.Status.Code, wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package untrace
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Built-in redaction patterns
+const (
+	RedactionPatternEmail      = `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`
+	RedactionPatternPhone      = `(?:\+\d{1,3}[-. ]?)?(?:\(\d{3}\)|\b\d{3})[-. ]?\d{3}[-. ]?\d{4}\b`
+	RedactionPatternCreditCard = `\b(?:\d[ -]?){12,18}\d\b`
+)
+
+// DefaultRedactionPatterns are the patterns used when Config.RedactValues is
+// set without Config.RedactionPatterns
+var DefaultRedactionPatterns = []string{
+	RedactionPatternEmail,
+	RedactionPatternCreditCard,
+	RedactionPatternPhone,
+}
+
+// redactedValue replaces every redacted substring
+const redactedValue = "[REDACTED]"
+
+// unredactedKeys are identifiers set by the SDK itself, which look like
+// numbers but never carry personal data
+var unredactedKeys = map[attribute.Key]bool{
+	LLMRequestIDKey:      true,
+	LLMErrorRequestIDKey: true,
+	WorkflowRunIDKey:     true,
+	WorkflowParentIDKey:  true,
+	"trace_id":           true,
+	"span_id":            true,
+	"parent_span_id":     true,
+}
+
+// patternValidators confirm matches of built-in patterns that a regular
+// expression alone over-matches
+var patternValidators = map[string]func(string) bool{
+	RedactionPatternCreditCard: luhnValid,
+}
+
+// redactionPattern is a compiled pattern with an optional match validator
+type redactionPattern struct {
+	re       *regexp.Regexp
+	validate func(string) bool
+}
+
+// Redactor scrubs substrings matching its patterns out of text
+type Redactor struct {
+	patterns []redactionPattern
+}
+
+// NewRedactor creates a redactor for the given regular expressions
+func NewRedactor(patterns ...string) (*Redactor, error) {
+	r := &Redactor{patterns: make([]redactionPattern, 0, len(patterns))}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, redactionPattern{
+			re:       re,
+			validate: patternValidators[pattern],
+		})
+	}
+	return r, nil
+}
+
+// Redact returns s with every match replaced by [REDACTED]
+func (r *Redactor) Redact(s string) string {
+	for _, pattern := range r.patterns {
+		if pattern.validate == nil {
+			s = pattern.re.ReplaceAllString(s, redactedValue)
+			continue
+		}
+		validate := pattern.validate
+		s = pattern.re.ReplaceAllStringFunc(s, func(match string) string {
+			if !validate(match) {
+				return match
+			}
+			return redactedValue
+		})
+	}
+	return s
+}
+
+// luhnValid reports whether the digits of s, ignoring spaces and dashes,
+// pass the Luhn checksum used by card numbers
+func luhnValid(s string) bool {
+	sum, digits := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+// RedactAttributes returns a copy of attrs with string values redacted.
+// Request, run, trace and span identifiers are left as they are.
+func (r *Redactor) RedactAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	redacted := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		if unredactedKeys[attr.Key] {
+			redacted[i] = attr
+			continue
+		}
+		switch attr.Value.Type() {
+		case attribute.STRING:
+			redacted[i] = attribute.String(string(attr.Key), r.Redact(attr.Value.AsString()))
+		case attribute.STRINGSLICE:
+			values := attr.Value.AsStringSlice()
+			for j, value := range values {
+				values[j] = r.Redact(value)
+			}
+			redacted[i] = attribute.StringSlice(string(attr.Key), values)
+		default:
+			redacted[i] = attr
+		}
+	}
+	return redacted
+}
+
+// redactionExporter scrubs attribute and event values of spans before
+// handing them to the wrapped exporter
+type redactionExporter struct {
+	sdktrace.SpanExporter
+	redactor *Redactor
+}
+
+// newRedactionExporter wraps the given exporter with value redaction
+func newRedactionExporter(exporter sdktrace.SpanExporter, redactor *Redactor) *redactionExporter {
+	return &redactionExporter{
+		SpanExporter: exporter,
+		redactor:     redactor,
+	}
+}
+
+// ExportSpans redacts every span and exports the batch
+func (e *redactionExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	redacted := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, span := range spans {
+		events := make([]sdktrace.Event, len(span.Events()))
+		for j, event := range span.Events() {
+			event.Attributes = e.redactor.RedactAttributes(event.Attributes)
+			events[j] = event
+		}
+		redacted[i] = &redactedSpan{
+			ReadOnlySpan: span,
+			attrs:        e.redactor.RedactAttributes(span.Attributes()),
+			events:       events,
+		}
+	}
+	return e.SpanExporter.ExportSpans(ctx, redacted)
+}
+
+// redactedSpan replaces the attributes and events of a read-only span
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	attrs  []attribute.KeyValue
+	events []sdktrace.Event
+}
+
+// Attributes returns the redacted attributes
+func (s *redactedSpan) Attributes() []attribute.KeyValue {
+	return s.attrs
+}
+
+// Events returns the redacted events
+func (s *redactedSpan) Events() []sdktrace.Event {
+	return s.events
+}
@@ -0,0 +1,133 @@
+package untrace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		input    string
+		want     string
+	}{
+		{
+			name:     "email",
+			patterns: DefaultRedactionPatterns,
+			input:    "Contact jane.doe+work@example.co.uk for access",
+			want:     "Contact [REDACTED] for access",
+		},
+		{
+			name:     "card number passing the Luhn check",
+			patterns: DefaultRedactionPatterns,
+			input:    "Card: 4111 1111 1111 1111, exp 12/30",
+			want:     "Card: [REDACTED], exp 12/30",
+		},
+		{
+			name:     "digits failing the Luhn check",
+			patterns: DefaultRedactionPatterns,
+			input:    "Order 1234567890123456 shipped",
+			want:     "Order 1234567890123456 shipped",
+		},
+		{
+			name:     "phone numbers",
+			patterns: DefaultRedactionPatterns,
+			input:    "Call (555) 123-4567 or +1 555.987.6543",
+			want:     "Call [REDACTED] or [REDACTED]",
+		},
+		{
+			name:     "mixed content",
+			patterns: DefaultRedactionPatterns,
+			input:    "I'm bob@example.com, card 5500-0000-0000-0004, phone 555-123-4567, order #42",
+			want:     "I'm [REDACTED], card [REDACTED], phone [REDACTED], order #42",
+		},
+		{
+			name:     "custom pattern",
+			patterns: []string{`\b\d{3}-\d{2}-\d{4}\b`},
+			input:    "SSN 123-45-6789, email bob@example.com",
+			want:     "SSN [REDACTED], email bob@example.com",
+		},
+		{
+			name:     "nothing to redact",
+			patterns: DefaultRedactionPatterns,
+			input:    "Summarize the quarterly report in 3 bullet points",
+			want:     "Summarize the quarterly report in 3 bullet points",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redactor, err := NewRedactor(tt.patterns...)
+			if err != nil {
+				t.Fatalf("NewRedactor() error = %v", err)
+			}
+			if got := redactor.Redact(tt.input); got != tt.want {
+				t.Errorf("Redact() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewRedactor(`(unclosed`); err == nil {
+		t.Error("NewRedactor() accepted an invalid pattern")
+	}
+}
+
+func TestRedactValues(t *testing.T) {
+	const requestID = "4111111111111111"
+
+	tests := []struct {
+		name       string
+		redact     bool
+		wantPrompt string
+		wantEvent  string
+	}{
+		{
+			name:       "redaction off",
+			wantPrompt: "Email bob@example.com about card 4111 1111 1111 1111",
+			wantEvent:  "call me at 555-123-4567",
+		},
+		{
+			name:       "redaction on",
+			redact:     true,
+			wantPrompt: "Email [REDACTED] about card [REDACTED]",
+			wantEvent:  "call me at [REDACTED]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.RedactValues = tt.redact
+			})
+
+			_, span := client.Tracer().StartSpan(context.Background(), "llm.chat", SpanOptions{
+				Attributes: map[string]interface{}{
+					LLMPromptKey:    "Email bob@example.com about card 4111 1111 1111 1111",
+					LLMRequestIDKey: requestID,
+					"llm.tokens":    42,
+				},
+			})
+			client.Tracer().AddLLMEvent(span, "user.message", map[string]interface{}{"text": "call me at 555-123-4567"})
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.chat")
+			if prompt, _ := attrValue(got.Attributes, LLMPromptKey); prompt.AsString() != tt.wantPrompt {
+				t.Errorf("%s = %q, want %q", LLMPromptKey, prompt.AsString(), tt.wantPrompt)
+			}
+			// SDK identifiers are never redacted, even when they look like card numbers
+			if id, _ := attrValue(got.Attributes, LLMRequestIDKey); id.AsString() != requestID {
+				t.Errorf("%s = %q, want %q", LLMRequestIDKey, id.AsString(), requestID)
+			}
+			if tokens, _ := attrValue(got.Attributes, "llm.tokens"); tokens.AsInt64() != 42 {
+				t.Errorf("llm.tokens = %d, want 42", tokens.AsInt64())
+			}
+			if len(got.Events) != 1 {
+				t.Fatalf("recorded %d events, want 1", len(got.Events))
+			}
+			if text, _ := attrValue(got.Events[0].Attributes, "text"); text.AsString() != tt.wantEvent {
+				t.Errorf("event text = %q, want %q", text.AsString(), tt.wantEvent)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package untrace
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LLMResult holds the outcome of an LLM call that is only known once the
+// response arrives. Zero fields are not written
+type LLMResult struct {
+	PromptTokens     int
+	CompletionTokens int
+	ReasoningTokens  int
+	// TotalTokens defaults to the sum of the other token counts
+	TotalTokens int
+	Cost        *Cost
+	RequestID   string
+	UsageReason string
+
+	// OutputMessages is the response content. It is not written by
+	// SetLLMResult itself; TraceLLMCall captures it when CaptureBody is set
+	OutputMessages []Message
+}
+
+// llmCallKey identifies the span of a TraceLLMCall in progress
+type llmCallKey struct {
+	traceID trace.TraceID
+	spanID  trace.SpanID
+}
+
+// pendingLLMResults holds the results reported with SetLLMResult for the
+// spans of TraceLLMCall calls in progress, keyed by llmCallKey
+var pendingLLMResults sync.Map
+
+// llmResultSlot collects the results reported for one call
+type llmResultSlot struct {
+	mu       sync.Mutex
+	result   LLMResult
+	reported bool
+}
+
+// awaitLLMResult starts collecting the results reported for span. The
+// returned function stops collecting and returns them, reporting false if
+// none were
+func awaitLLMResult(span trace.Span) func() (LLMResult, bool) {
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return func() (LLMResult, bool) { return LLMResult{}, false }
+	}
+
+	key := llmCallKey{traceID: sc.TraceID(), spanID: sc.SpanID()}
+	slot := &llmResultSlot{}
+	pendingLLMResults.Store(key, slot)
+
+	return func() (LLMResult, bool) {
+		pendingLLMResults.Delete(key)
+		slot.mu.Lock()
+		defer slot.mu.Unlock()
+		return slot.result, slot.reported
+	}
+}
+
+// reportLLMResult hands a result to the TraceLLMCall awaiting span, if any
+func reportLLMResult(span trace.Span, result LLMResult) {
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+	value, ok := pendingLLMResults.Load(llmCallKey{traceID: sc.TraceID(), spanID: sc.SpanID()})
+	if !ok {
+		return
+	}
+
+	slot := value.(*llmResultSlot)
+	slot.mu.Lock()
+	slot.result = result
+	slot.reported = true
+	slot.mu.Unlock()
+}
+
+// SetLLMResult writes the result of an LLM call to a span started with
+// StartLLMSpan, using the canonical llm.* attribute keys. Inside
+// TraceLLMCall, the result is also handed to it once fn returns
+func SetLLMResult(span trace.Span, result LLMResult) {
+	reportLLMResult(span, result)
+
+	var attrs []attribute.KeyValue
+
+	if result.PromptTokens > 0 {
+		attrs = append(attrs, attribute.Int(LLMPromptTokensKey, result.PromptTokens))
+	}
+	if result.CompletionTokens > 0 {
+		attrs = append(attrs, attribute.Int(LLMCompletionTokensKey, result.CompletionTokens))
+	}
+	if result.ReasoningTokens > 0 {
+		attrs = append(attrs, attribute.Int(LLMReasoningTokensKey, result.ReasoningTokens))
+	}
+
+	total := result.TotalTokens
+	if total == 0 {
+		total = result.PromptTokens + result.CompletionTokens + result.ReasoningTokens
+	}
+	if total > 0 {
+		attrs = append(attrs, attribute.Int(LLMTotalTokensKey, total))
+	}
+
+	if result.Cost != nil {
+		attrs = append(attrs, CreateCostAttributes(*result.Cost)...)
+	}
+	if result.RequestID != "" {
+		attrs = append(attrs, attribute.String(LLMRequestIDKey, result.RequestID))
+	}
+	if result.UsageReason != "" {
+		attrs = append(attrs, attribute.String(LLMUsageReasonKey, result.UsageReason))
+	}
+
+	span.SetAttributes(attrs...)
+}
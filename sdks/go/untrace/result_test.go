@@ -0,0 +1,78 @@
+package untrace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSetLLMResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		result    LLMResult
+		wantAttrs map[string]string
+		absent    []string
+	}{
+		{
+			name:   "empty result",
+			absent: []string{LLMPromptTokensKey, LLMTotalTokensKey, LLMCostTotalKey, LLMRequestIDKey, LLMUsageReasonKey},
+		},
+		{
+			name:   "total derived from counts",
+			result: LLMResult{PromptTokens: 120, CompletionTokens: 30, ReasoningTokens: 50},
+			wantAttrs: map[string]string{
+				LLMPromptTokensKey:     "120",
+				LLMCompletionTokensKey: "30",
+				LLMReasoningTokensKey:  "50",
+				LLMTotalTokensKey:      "200",
+			},
+		},
+		{
+			name:   "explicit total",
+			result: LLMResult{PromptTokens: 120, TotalTokens: 130},
+			wantAttrs: map[string]string{
+				LLMPromptTokensKey: "120",
+				LLMTotalTokensKey:  "130",
+			},
+			absent: []string{LLMCompletionTokensKey, LLMReasoningTokensKey},
+		},
+		{
+			name: "cost, request ID and usage reason",
+			result: LLMResult{
+				Cost:        &Cost{Prompt: 0.25, Completion: 0.5, Total: 0.75},
+				RequestID:   "req_abc",
+				UsageReason: "stop",
+			},
+			wantAttrs: map[string]string{
+				LLMCostTotalKey:   "0.75",
+				LLMRequestIDKey:   "req_abc",
+				LLMUsageReasonKey: "stop",
+			},
+			absent: []string{LLMTotalTokensKey},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			_, span := client.Tracer().StartLLMSpan(context.Background(), "llm.chat", LLMSpanOptions{
+				Provider: "openai",
+				Model:    "gpt-4",
+			})
+			SetLLMResult(span, tt.result)
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.chat")
+			for key, want := range tt.wantAttrs {
+				if value, _ := attrValue(got.Attributes, key); value.Emit() != want {
+					t.Errorf("%s = %s, want %s", key, value.Emit(), want)
+				}
+			}
+			for _, key := range tt.absent {
+				if _, ok := attrValue(got.Attributes, key); ok {
+					t.Errorf("%s is set, want it absent", key)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package untrace
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SampledReason describes why a span was kept by the SDK
+type SampledReason string
+
+const (
+	SampledReasonError    SampledReason = "error"
+	SampledReasonHighCost SampledReason = "high_cost"
+	SampledReasonForced   SampledReason = "forced"
+	SampledReasonRate     SampledReason = "rate"
+)
+
+// retentionExporter decides which finished spans are kept. Errored, high-cost
+// and forced spans are always kept; the rest are sampled by trace ID at the
+// configured rate so whole traces are kept or dropped together.
+type retentionExporter struct {
+	sdktrace.SpanExporter
+	sampler           sdktrace.Sampler
+	highCostThreshold float64
+	recordReason      bool
+}
+
+// newRetentionExporter wraps the given exporter with the retention policy.
+// Unless spans are sampled at export, every span reaching the exporter was
+// already sampled and is kept.
+func newRetentionExporter(exporter sdktrace.SpanExporter, config Config) *retentionExporter {
+	e := &retentionExporter{
+		SpanExporter:      exporter,
+		highCostThreshold: config.HighCostThreshold,
+		recordReason:      config.RecordSampledReason,
+	}
+	if sampledAtExport(config) {
+		e.sampler = sdktrace.TraceIDRatioBased(config.SamplingRate)
+	}
+	return e
+}
+
+// newHeadSampler creates the tracer provider's sampler for the sampler mode
+func newHeadSampler(config Config) sdktrace.Sampler {
+	if config.SamplerMode == SamplerAlwaysOn || sampledAtExport(config) {
+		return sdktrace.AlwaysSample()
+	}
+
+	var ratio sdktrace.Sampler
+	switch {
+	case config.SamplingRate >= 1:
+		ratio = sdktrace.AlwaysSample()
+	case config.SamplingRate <= 0:
+		ratio = sdktrace.NeverSample()
+	default:
+		ratio = sdktrace.TraceIDRatioBased(config.SamplingRate)
+	}
+
+	if config.SamplerMode == SamplerParentBased {
+		return sdktrace.ParentBased(ratio)
+	}
+	return ratio
+}
+
+// sampledAtExport returns whether the sampler mode samples spans when they
+// are exported rather than when they start
+func sampledAtExport(config Config) bool {
+	return config.SamplerMode == SamplerRetention
+}
+
+// ExportSpans exports the spans the retention policy keeps
+func (e *retentionExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	kept := make([]sdktrace.ReadOnlySpan, 0, len(spans))
+	for _, span := range spans {
+		reason, keep := e.retain(span)
+		if !keep {
+			continue
+		}
+		if e.recordReason {
+			span = withAttributes(span, attribute.String(UntraceSampledReasonKey, string(reason)))
+		}
+		kept = append(kept, span)
+	}
+
+	if len(kept) == 0 {
+		return nil
+	}
+	return e.SpanExporter.ExportSpans(ctx, kept)
+}
+
+// retain returns whether the span is kept and why
+func (e *retentionExporter) retain(span sdktrace.ReadOnlySpan) (SampledReason, bool) {
+	if reason, ok := alwaysRetained(span, e.highCostThreshold); ok {
+		return reason, true
+	}
+
+	if e.sampler == nil {
+		return SampledReasonRate, true
+	}
+	return SampledReasonRate, rateSampled(e.sampler, span.SpanContext().TraceID())
+}
+
+// alwaysRetained returns whether the span is kept whatever the sampling rate
+// because it errored, is high-cost or is forced, and why
+func alwaysRetained(span sdktrace.ReadOnlySpan, highCostThreshold float64) (SampledReason, bool) {
+	if span.Status().Code == codes.Error {
+		return SampledReasonError, true
+	}
+
+	for _, attr := range span.Attributes() {
+		switch string(attr.Key) {
+		case UntraceSamplingForcedKey:
+			if attr.Value.Type() == attribute.BOOL && attr.Value.AsBool() {
+				return SampledReasonForced, true
+			}
+		case LLMCostTotalKey:
+			if highCostThreshold > 0 && attr.Value.AsFloat64() >= highCostThreshold {
+				return SampledReasonHighCost, true
+			}
+		}
+	}
+	return "", false
+}
+
+// rateSampled returns whether the trace passes the sampling rate. Spans and
+// metrics share it so both keep the same population of traces
+func rateSampled(sampler sdktrace.Sampler, traceID trace.TraceID) bool {
+	result := sampler.ShouldSample(sdktrace.SamplingParameters{TraceID: traceID})
+	return result.Decision == sdktrace.RecordAndSample
+}
@@ -0,0 +1,244 @@
+package untrace
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSampledReason(t *testing.T) {
+	tests := []struct {
+		name         string
+		samplingRate float64
+		mark         func(span trace.Span)
+		wantReason   SampledReason
+		wantDropped  bool
+	}{
+		{
+			name:       "error",
+			mark:       func(span trace.Span) { span.SetStatus(codes.Error, "boom") },
+			wantReason: SampledReasonError,
+		},
+		{
+			name: "forced",
+			mark: func(span trace.Span) {
+				span.SetAttributes(attribute.Bool(UntraceSamplingForcedKey, true))
+			},
+			wantReason: SampledReasonForced,
+		},
+		{
+			name: "high cost",
+			mark: func(span trace.Span) {
+				span.SetAttributes(attribute.Float64(LLMCostTotalKey, 2.5))
+			},
+			wantReason: SampledReasonHighCost,
+		},
+		{
+			name:         "rate",
+			samplingRate: 1,
+			mark:         func(trace.Span) {},
+			wantReason:   SampledReasonRate,
+		},
+		{
+			name:        "dropped by rate",
+			mark:        func(trace.Span) {},
+			wantDropped: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.SamplerMode = SamplerRetention
+				c.SamplingRate = tt.samplingRate
+				c.HighCostThreshold = 1
+				c.RecordSampledReason = true
+			})
+
+			_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{})
+			tt.mark(span)
+			span.End()
+
+			spans := exportedSpans(t, client, exporter)
+			if tt.wantDropped {
+				if len(spans) != 0 {
+					t.Fatalf("exported %v, want nothing", spanNames(spans))
+				}
+				return
+			}
+
+			got := findSpan(t, spans, "work")
+			if reason, _ := attrValue(got.Attributes, UntraceSampledReasonKey); reason.AsString() != string(tt.wantReason) {
+				t.Errorf("%s = %q, want %q", UntraceSampledReasonKey, reason.AsString(), tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestZeroSamplingRate(t *testing.T) {
+	errored := func(span trace.Span) { span.SetStatus(codes.Error, "boom") }
+	forced := func(span trace.Span) { span.SetAttributes(attribute.Bool(UntraceSamplingForcedKey, true)) }
+	highCost := func(span trace.Span) { span.SetAttributes(attribute.Float64(LLMCostTotalKey, 5)) }
+
+	tests := []struct {
+		name         string
+		mode         SamplerMode
+		mark         func(span trace.Span)
+		wantSampled  bool
+		wantExported bool
+	}{
+		{name: "plain span", mark: func(trace.Span) {}},
+		{name: "errored span", mark: errored},
+		{name: "forced span", mark: forced},
+		{name: "retention, plain span", mode: SamplerRetention, mark: func(trace.Span) {}, wantSampled: true},
+		{name: "retention, errored span", mode: SamplerRetention, mark: errored, wantSampled: true, wantExported: true},
+		{name: "retention, forced span", mode: SamplerRetention, mark: forced, wantSampled: true, wantExported: true},
+		{name: "retention, high-cost span", mode: SamplerRetention, mark: highCost, wantSampled: true, wantExported: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, func(c *Config) {
+				if tt.mode != "" {
+					c.SamplerMode = tt.mode
+				}
+				c.SamplingRate = 0
+				c.HighCostThreshold = 1
+			})
+
+			for i := 0; i < 20; i++ {
+				_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{})
+				// The head sampler decides what is recorded and propagated
+				if sampled := span.SpanContext().IsSampled(); sampled != tt.wantSampled {
+					t.Fatalf("span sampled = %v, want %v", sampled, tt.wantSampled)
+				}
+				tt.mark(span)
+				span.End()
+			}
+
+			want := 0
+			if tt.wantExported {
+				want = 20
+			}
+			if spans := exportedSpans(t, client, exporter); len(spans) != want {
+				t.Errorf("exported %d spans, want %d", len(spans), want)
+			}
+		})
+	}
+}
+
+func TestSamplerMode(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         SamplerMode
+		rate         float64
+		remote       *bool
+		wantExported bool
+	}{
+		{name: "parent based, sampled remote parent", mode: SamplerParentBased, remote: boolPtr(true), wantExported: true},
+		{name: "parent based, unsampled remote parent", mode: SamplerParentBased, rate: 1, remote: boolPtr(false)},
+		{name: "parent based, root below rate", mode: SamplerParentBased},
+		{name: "parent based, root within rate", mode: SamplerParentBased, rate: 1, wantExported: true},
+		{name: "trace ID ratio ignores the parent", mode: SamplerTraceIDRatio, remote: boolPtr(true)},
+		{name: "trace ID ratio within rate", mode: SamplerTraceIDRatio, rate: 1, remote: boolPtr(false), wantExported: true},
+		{name: "default mode below rate", mode: "", remote: boolPtr(true)},
+		{name: "always on ignores the rate", mode: SamplerAlwaysOn, wantExported: true},
+		{name: "retention below rate", mode: SamplerRetention},
+		{name: "retention within rate", mode: SamplerRetention, rate: 1, wantExported: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.SamplerMode = tt.mode
+				c.SamplingRate = tt.rate
+			})
+
+			ctx := context.Background()
+			if tt.remote != nil {
+				var flags trace.TraceFlags
+				if *tt.remote {
+					flags = trace.FlagsSampled
+				}
+				ctx = trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+					SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+					TraceFlags: flags,
+					Remote:     true,
+				}))
+			}
+
+			_, span := client.Tracer().StartSpan(ctx, "handle", SpanOptions{})
+			span.End()
+
+			spans := exportedSpans(t, client, exporter)
+			if exported := len(spans) == 1; exported != tt.wantExported {
+				t.Errorf("exported = %v, want %v", exported, tt.wantExported)
+			}
+			if tt.wantExported && tt.remote != nil && spans[0].Parent.TraceID() != spans[0].SpanContext.TraceID() {
+				t.Errorf("span did not join the remote trace")
+			}
+		})
+	}
+}
+
+// boolPtr returns a pointer to b
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestSeededIDGenerator(t *testing.T) {
+	// sampledTraces runs 50 root spans at a 0.5 sampling rate and returns
+	// the IDs of the exported traces
+	sampledTraces := func(t *testing.T, seed int64) []string {
+		client, exporter := newTestClient(t, func(c *Config) {
+			c.SamplingRate = 0.5
+			c.IDGenerator = NewSeededIDGenerator(seed)
+		})
+		for i := 0; i < 50; i++ {
+			_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{})
+			span.End()
+		}
+
+		var ids []string
+		for _, span := range exportedSpans(t, client, exporter) {
+			ids = append(ids, span.SpanContext.TraceID().String())
+		}
+		if err := client.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Shutdown() error = %v", err)
+		}
+		if err := Reset(); err != nil {
+			t.Fatalf("Reset() error = %v", err)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name     string
+		seeds    [2]int64
+		wantSame bool
+	}{
+		{name: "same seed", seeds: [2]int64{42, 42}, wantSame: true},
+		{name: "other same seed", seeds: [2]int64{7, 7}, wantSame: true},
+		{name: "different seeds", seeds: [2]int64{42, 43}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := sampledTraces(t, tt.seeds[0])
+			second := sampledTraces(t, tt.seeds[1])
+			if len(first) == 0 || len(first) == 50 {
+				t.Fatalf("sampled %d of 50 traces, want a sample", len(first))
+			}
+
+			same := strings.Join(first, ",") == strings.Join(second, ",")
+			if same != tt.wantSame {
+				t.Errorf("same sampled traces = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package untrace
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// signalShutdownTimeout bounds how long ShutdownOnSignal waits for the client
+// to flush and shut down
+const signalShutdownTimeout = 5 * time.Second
+
+// ShutdownOnSignal flushes and shuts down the client when one of the given
+// signals arrives. It defaults to SIGINT and SIGTERM when no signals are
+// given. Once the client is shut down the handler is uninstalled and the
+// signal re-raised, so the process terminates as it would have without the
+// handler; an application that handles the signal itself receives it again.
+// The returned function uninstalls the handler.
+func ShutdownOnSignal(client Client, signals ...os.Signal) func() {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			signal.Stop(ch)
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		var sig os.Signal
+		select {
+		case sig = <-ch:
+		case <-done:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), signalShutdownTimeout)
+		defer cancel()
+
+		if err := client.Flush(ctx); err != nil {
+			log.Printf("[Untrace] Warning: failed to flush on signal: %v", err)
+		}
+		if err := client.Shutdown(ctx); err != nil {
+			log.Printf("[Untrace] Warning: failed to shutdown on signal: %v", err)
+		}
+
+		stop()
+		reraise(sig)
+	}()
+
+	var closeOnce sync.Once
+	return func() {
+		stop()
+		closeOnce.Do(func() {
+			close(done)
+		})
+	}
+}
+
+// reraise sends sig to the current process, now that its default action is
+// restored, exiting with the conventional status if it can't be delivered.
+// It is a variable so tests can observe the signal without terminating
+var reraise = func(sig os.Signal) {
+	process, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = process.Signal(sig)
+	}
+	if err != nil {
+		code := 1
+		if s, ok := sig.(syscall.Signal); ok {
+			code = 128 + int(s)
+		}
+		os.Exit(code)
+	}
+}
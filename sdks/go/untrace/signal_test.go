@@ -0,0 +1,74 @@
+package untrace
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdownOnSignal(t *testing.T) {
+	tests := []struct {
+		name         string
+		uninstall    bool
+		wantShutdown bool
+	}{
+		{name: "signal received", wantShutdown: true},
+		{name: "handler uninstalled", uninstall: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reraised := make(chan os.Signal, 1)
+			previous := reraise
+			reraise = func(sig os.Signal) { reraised <- sig }
+			defer func() { reraise = previous }()
+
+			// Catch the signal once the handler lets go of it, instead of
+			// letting it terminate the test binary
+			caught := make(chan os.Signal, 1)
+			signal.Notify(caught, syscall.SIGUSR1)
+			defer signal.Stop(caught)
+
+			client, _ := newTestClient(t, nil)
+			_, span := client.Tracer().StartSpan(context.Background(), "work", SpanOptions{})
+			span.End()
+
+			uninstall := ShutdownOnSignal(client, syscall.SIGUSR1)
+			defer uninstall()
+			if tt.uninstall {
+				uninstall()
+			}
+
+			if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+				t.Fatalf("Kill() error = %v", err)
+			}
+
+			select {
+			case sig := <-reraised:
+				if !tt.wantShutdown {
+					t.Fatalf("re-raised %v after the handler was uninstalled", sig)
+				}
+				if sig != syscall.SIGUSR1 {
+					t.Errorf("re-raised %v, want %v", sig, syscall.SIGUSR1)
+				}
+			case <-time.After(time.Second):
+				if tt.wantShutdown {
+					t.Fatal("signal not re-raised after shutdown")
+				}
+			}
+
+			client.mu.RLock()
+			shutdown := client.shutdown
+			client.mu.RUnlock()
+			if shutdown != tt.wantShutdown {
+				t.Errorf("client shut down = %v, want %v", shutdown, tt.wantShutdown)
+			}
+			if tt.wantShutdown && client.exporter.Exported() != 1 {
+				t.Errorf("exported %d spans before shutdown, want 1", client.exporter.Exported())
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package untrace
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// MetricsSnapshot represents the current values of the SDK metrics
+type MetricsSnapshot struct {
+	PromptTokens     int64
+	CompletionTokens int64
+	TotalTokens      int64
+	TotalCost        float64
+	CostByModel      map[string]float64
+	Errors           int64
+	Latency          LatencySummary
+
+	// CacheBySession aggregates prompt cache usage per conversation or
+	// workflow session ID
+	CacheBySession map[string]CacheStats
+}
+
+// LatencySummary summarizes the latency histogram in seconds. Percentiles are
+// estimated from the histogram buckets.
+type LatencySummary struct {
+	Count uint64
+	Sum   float64
+	P50   float64
+	P90   float64
+	P99   float64
+}
+
+// MetricsSnapshot returns the current metric values from the in-memory reader
+func (c *untraceClient) MetricsSnapshot() MetricsSnapshot {
+	snapshot := MetricsSnapshot{
+		CostByModel:    make(map[string]float64),
+		CacheBySession: c.cacheStats.Snapshot(),
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := c.snapshotReader.Collect(context.Background(), &rm); err != nil {
+		return snapshot
+	}
+
+	var latency []metricdata.HistogramDataPoint[float64]
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			switch m.Name {
+			case "llm.prompt.tokens":
+				snapshot.PromptTokens += sumInt64(m.Data)
+			case "llm.completion.tokens":
+				snapshot.CompletionTokens += sumInt64(m.Data)
+			case "llm.total.tokens":
+				snapshot.TotalTokens += sumInt64(m.Data)
+			case "llm.errors":
+				snapshot.Errors += sumInt64(m.Data)
+			case "llm.cost.total":
+				if sum, ok := m.Data.(metricdata.Sum[float64]); ok {
+					for _, dp := range sum.DataPoints {
+						model, _ := dp.Attributes.Value(attribute.Key("model"))
+						snapshot.CostByModel[model.AsString()] += dp.Value
+						snapshot.TotalCost += dp.Value
+					}
+				}
+			case "llm.latency":
+				if hist, ok := m.Data.(metricdata.Histogram[float64]); ok {
+					latency = append(latency, hist.DataPoints...)
+				}
+			}
+		}
+	}
+
+	snapshot.Latency = summarizeLatency(latency)
+	return snapshot
+}
+
+// sumInt64 adds up the data points of an int64 sum
+func sumInt64(data metricdata.Aggregation) int64 {
+	sum, ok := data.(metricdata.Sum[int64])
+	if !ok {
+		return 0
+	}
+
+	var total int64
+	for _, dp := range sum.DataPoints {
+		total += dp.Value
+	}
+	return total
+}
+
+// summarizeLatency merges histogram data points and estimates percentiles
+func summarizeLatency(points []metricdata.HistogramDataPoint[float64]) LatencySummary {
+	var summary LatencySummary
+	if len(points) == 0 {
+		return summary
+	}
+
+	// Merge bucket counts across attribute sets sharing the same bounds
+	bounds := points[0].Bounds
+	counts := make([]uint64, len(bounds)+1)
+	for _, dp := range points {
+		summary.Count += dp.Count
+		summary.Sum += dp.Sum
+		if len(dp.Bounds) != len(bounds) {
+			continue
+		}
+		for i, count := range dp.BucketCounts {
+			counts[i] += count
+		}
+	}
+
+	summary.P50 = bucketPercentile(bounds, counts, 0.50)
+	summary.P90 = bucketPercentile(bounds, counts, 0.90)
+	summary.P99 = bucketPercentile(bounds, counts, 0.99)
+	return summary
+}
+
+// bucketPercentile estimates a percentile by interpolating within the bucket
+// that contains it
+func bucketPercentile(bounds []float64, counts []uint64, p float64) float64 {
+	var total uint64
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := p * float64(total)
+	cumulative := make([]float64, len(counts))
+	var running uint64
+	for i, count := range counts {
+		running += count
+		cumulative[i] = float64(running)
+	}
+
+	i := sort.SearchFloat64s(cumulative, rank)
+	if i >= len(bounds) {
+		// The overflow bucket has no upper bound; report its lower bound
+		if len(bounds) == 0 {
+			return 0
+		}
+		return bounds[len(bounds)-1]
+	}
+
+	lower := 0.0
+	below := 0.0
+	if i > 0 {
+		lower = bounds[i-1]
+		below = cumulative[i-1]
+	}
+	upper := bounds[i]
+	inBucket := cumulative[i] - below
+	if inBucket == 0 {
+		return upper
+	}
+
+	return lower + (upper-lower)*math.Min(1, (rank-below)/inBucket)
+}
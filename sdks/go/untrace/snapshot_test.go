@@ -0,0 +1,84 @@
+package untrace
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMetricsSnapshot(t *testing.T) {
+	tests := []struct {
+		name   string
+		record func(m Metrics)
+		check  func(t *testing.T, s MetricsSnapshot)
+	}{
+		{
+			name:   "empty",
+			record: func(Metrics) {},
+			check: func(t *testing.T, s MetricsSnapshot) {
+				if s.TotalTokens != 0 || s.TotalCost != 0 || s.Errors != 0 || s.Latency.Count != 0 {
+					t.Errorf("snapshot = %+v, want zero values", s)
+				}
+			},
+		},
+		{
+			name: "token usage",
+			record: func(m Metrics) {
+				m.RecordTokenUsage(TokenUsage{PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150, Model: "gpt-4", Provider: "openai"})
+				m.RecordTokenUsage(TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, Model: "gpt-4o", Provider: "openai"})
+			},
+			check: func(t *testing.T, s MetricsSnapshot) {
+				if s.PromptTokens != 110 || s.CompletionTokens != 55 || s.TotalTokens != 165 {
+					t.Errorf("tokens = %d/%d/%d, want 110/55/165", s.PromptTokens, s.CompletionTokens, s.TotalTokens)
+				}
+			},
+		},
+		{
+			name: "cost by model",
+			record: func(m Metrics) {
+				m.RecordCost(Cost{Total: 0.5, Model: "gpt-4", Provider: "openai"})
+				m.RecordCost(Cost{Total: 0.25, Model: "gpt-4", Provider: "openai"})
+				m.RecordCost(Cost{Total: 0.1, Model: "gpt-4o", Provider: "openai"})
+			},
+			check: func(t *testing.T, s MetricsSnapshot) {
+				if math.Abs(s.TotalCost-0.85) > 1e-9 {
+					t.Errorf("TotalCost = %v, want 0.85", s.TotalCost)
+				}
+				if math.Abs(s.CostByModel["gpt-4"]-0.75) > 1e-9 || math.Abs(s.CostByModel["gpt-4o"]-0.1) > 1e-9 {
+					t.Errorf("CostByModel = %v", s.CostByModel)
+				}
+			},
+		},
+		{
+			name: "errors and latency",
+			record: func(m Metrics) {
+				m.RecordError(errors.New("timeout"), nil)
+				m.RecordError(errors.New("rate limited"), nil)
+				for i := 0; i < 10; i++ {
+					m.RecordLatency(200*time.Millisecond, nil)
+				}
+			},
+			check: func(t *testing.T, s MetricsSnapshot) {
+				if s.Errors != 2 {
+					t.Errorf("Errors = %d, want 2", s.Errors)
+				}
+				if s.Latency.Count != 10 || math.Abs(s.Latency.Sum-2) > 1e-9 {
+					t.Errorf("Latency count/sum = %d/%v, want 10/2", s.Latency.Count, s.Latency.Sum)
+				}
+				if s.Latency.P50 <= 0 || s.Latency.P50 > s.Latency.P99 {
+					t.Errorf("Latency percentiles = %v/%v/%v", s.Latency.P50, s.Latency.P90, s.Latency.P99)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTestClient(t, nil)
+
+			tt.record(client.Metrics())
+			tt.check(t, client.MetricsSnapshot())
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package untrace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StreamRecorder records a streaming LLM response. Call Chunk for every
+// received chunk and Done once the stream completes. If the context is
+// cancelled first, e.g. because the client disconnected, the stream is
+// recorded as aborted with the tokens received so far. Done or Abort must be
+// called, typically deferred, or the span stays open and the goroutine
+// watching the context runs until it is cancelled.
+type StreamRecorder struct {
+	span    trace.Span
+	metrics Metrics
+	opts    LLMSpanOptions
+	stop    chan struct{}
+	start   time.Time
+
+	mu         sync.Mutex
+	finished   bool
+	chunks     int
+	tokens     int
+	firstChunk time.Time
+}
+
+// StartLLMStream starts an LLM span for a streaming response and returns the
+// recorder tracking it
+func (i *Instrumentation) StartLLMStream(ctx context.Context, name string, opts LLMSpanOptions) (context.Context, *StreamRecorder) {
+	if !i.config.Enabled {
+		// A finished recorder ignores all calls
+		return ctx, &StreamRecorder{finished: true}
+	}
+
+	stream := true
+	opts.Stream = &stream
+
+	ctx, span := i.client.Tracer().StartLLMSpan(ctx, name, opts)
+	i.recordQueueWait(ctx, opts)
+	r := &StreamRecorder{
+		span:    span,
+		metrics: i.client.Metrics().WithContext(ctx),
+		opts:    opts,
+		stop:    make(chan struct{}),
+		start:   time.Now(),
+	}
+
+	// Abort the stream if the context is cancelled before Done. A context
+	// that can never be cancelled needs no watching
+	if done := ctx.Done(); done != nil {
+		go func() {
+			select {
+			case <-done:
+				r.Abort()
+			case <-r.stop:
+			}
+		}()
+	}
+
+	return ctx, r
+}
+
+// Chunk records a received chunk carrying the given number of tokens. The
+// first chunk marks the time to first token
+func (r *StreamRecorder) Chunk(tokens int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.finished {
+		return
+	}
+	if r.chunks == 0 {
+		r.firstChunk = time.Now()
+		ttft := attribute.Int64(LLMTimeToFirstTokenMsKey, r.firstChunk.Sub(r.start).Milliseconds())
+		r.span.SetAttributes(ttft)
+		r.span.AddEvent(LLMFirstTokenEvent, trace.WithAttributes(ttft))
+	}
+	r.chunks++
+	r.tokens += tokens
+}
+
+// Done records the stream as complete and ends its span
+func (r *StreamRecorder) Done() {
+	r.finish(false)
+}
+
+// Abort records the stream as abandoned before completion and ends its span
+func (r *StreamRecorder) Abort() {
+	r.finish(true)
+}
+
+// finish ends the stream span exactly once
+func (r *StreamRecorder) finish(aborted bool) {
+	r.mu.Lock()
+	if r.finished {
+		r.mu.Unlock()
+		return
+	}
+	r.finished = true
+	tokens, chunks := r.tokens, r.chunks
+	r.mu.Unlock()
+
+	close(r.stop)
+
+	r.span.SetAttributes(
+		attribute.Bool(LLMStreamAbortedKey, aborted),
+		attribute.Int(LLMStreamTokensKey, tokens),
+		attribute.Int(LLMStreamChunksKey, chunks),
+		attribute.Int64(LLMDurationMsKey, time.Since(r.start).Milliseconds()),
+	)
+	if aborted {
+		r.metrics.RecordAbortedStream(tokens, map[string]interface{}{
+			"provider":  r.opts.Provider,
+			"model":     r.opts.Model,
+			"operation": string(r.opts.Operation),
+		})
+	}
+
+	r.span.End()
+}
+
+// TraceLLMStream traces a streaming LLM call. fn must call emit for every
+// received chunk, or at least for the first one, so the span records the time
+// to first token, the chunk count and the total stream duration.
+func (i *Instrumentation) TraceLLMStream(ctx context.Context, name string, opts LLMSpanOptions, fn func(ctx context.Context, emit func()) error) error {
+	if !i.config.Enabled {
+		return fn(ctx, func() {})
+	}
+
+	ctx, recorder := i.StartLLMStream(ctx, name, opts)
+	// Abort the stream if fn panics; after Done this does nothing
+	defer recorder.Abort()
+
+	err := fn(ctx, func() { recorder.Chunk(0) })
+	duration := time.Since(recorder.start)
+
+	if err != nil {
+		recorder.span.RecordError(err)
+		recorder.span.SetStatus(codes.Error, err.Error())
+	}
+	recorder.Done()
+
+	i.recordOutcome(ctx, err, duration, map[string]interface{}{
+		"provider":  opts.Provider,
+		"model":     opts.Model,
+		"operation": string(opts.Operation),
+	})
+
+	return err
+}
@@ -0,0 +1,170 @@
+package untrace
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestStreamRecorder(t *testing.T) {
+	tests := []struct {
+		name        string
+		chunks      []int
+		finish      func(r *StreamRecorder, cancel context.CancelFunc)
+		wantAborted bool
+	}{
+		{
+			name:   "completed",
+			chunks: []int{3, 4, 5},
+			finish: func(r *StreamRecorder, _ context.CancelFunc) { r.Done() },
+		},
+		{
+			name:        "client disconnected",
+			chunks:      []int{3, 4},
+			finish:      func(_ *StreamRecorder, cancel context.CancelFunc) { cancel() },
+			wantAborted: true,
+		},
+		{
+			name:        "aborted explicitly",
+			chunks:      []int{7},
+			finish:      func(r *StreamRecorder, _ context.CancelFunc) { r.Abort() },
+			wantAborted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			instr := NewInstrumentation(client, DefaultInstrumentationConfig())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			_, rec := instr.StartLLMStream(ctx, "llm.stream", LLMSpanOptions{Provider: "openai", Model: "gpt-4"})
+			var wantTokens int
+			for _, tokens := range tt.chunks {
+				rec.Chunk(tokens)
+				wantTokens += tokens
+			}
+			tt.finish(rec, cancel)
+			<-rec.stop
+
+			// Chunks after the stream finished are ignored
+			rec.Chunk(100)
+			rec.Done()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.stream")
+			if aborted, _ := attrValue(got.Attributes, LLMStreamAbortedKey); aborted.AsBool() != tt.wantAborted {
+				t.Errorf("%s = %v, want %v", LLMStreamAbortedKey, aborted.AsBool(), tt.wantAborted)
+			}
+			if tokens, _ := attrValue(got.Attributes, LLMStreamTokensKey); tokens.AsInt64() != int64(wantTokens) {
+				t.Errorf("%s = %d, want %d", LLMStreamTokensKey, tokens.AsInt64(), wantTokens)
+			}
+			if chunks, _ := attrValue(got.Attributes, LLMStreamChunksKey); chunks.AsInt64() != int64(len(tt.chunks)) {
+				t.Errorf("%s = %d, want %d", LLMStreamChunksKey, chunks.AsInt64(), len(tt.chunks))
+			}
+
+			m, recorded := findMetric(t, client.snapshotReader, "llm.stream.aborted.tokens")
+			if recorded != tt.wantAborted {
+				t.Fatalf("aborted stream metric recorded = %v, want %v", recorded, tt.wantAborted)
+			}
+			if recorded && sumValue(t, m) != float64(wantTokens) {
+				t.Errorf("aborted tokens = %v, want %d", sumValue(t, m), wantTokens)
+			}
+		})
+	}
+}
+
+func TestTraceLLMStream(t *testing.T) {
+	const firstTokenDelay = 30 * time.Millisecond
+
+	tests := []struct {
+		name       string
+		chunks     int
+		err        error
+		wantStatus codes.Code
+	}{
+		{name: "single emit", chunks: 1, wantStatus: codes.Unset},
+		{name: "emit per chunk", chunks: 5, wantStatus: codes.Unset},
+		{name: "no tokens received", wantStatus: codes.Unset},
+		{name: "stream error", chunks: 2, err: errors.New("connection reset"), wantStatus: codes.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+			instr := NewInstrumentation(client, DefaultInstrumentationConfig())
+
+			err := instr.TraceLLMStream(context.Background(), "llm.stream", LLMSpanOptions{Provider: "openai", Model: "gpt-4"},
+				func(ctx context.Context, emit func()) error {
+					time.Sleep(firstTokenDelay)
+					for i := 0; i < tt.chunks; i++ {
+						emit()
+						time.Sleep(time.Millisecond)
+					}
+					return tt.err
+				})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("TraceLLMStream() error = %v, want %v", err, tt.err)
+			}
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.stream")
+			if chunks, _ := attrValue(got.Attributes, LLMStreamChunksKey); chunks.AsInt64() != int64(tt.chunks) {
+				t.Errorf("%s = %d, want %d", LLMStreamChunksKey, chunks.AsInt64(), tt.chunks)
+			}
+			duration, _ := attrValue(got.Attributes, LLMDurationMsKey)
+			if duration.AsInt64() < firstTokenDelay.Milliseconds() {
+				t.Errorf("%s = %d, want at least %d", LLMDurationMsKey, duration.AsInt64(), firstTokenDelay.Milliseconds())
+			}
+			if got.Status.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", got.Status.Code, tt.wantStatus)
+			}
+
+			ttft, ok := attrValue(got.Attributes, LLMTimeToFirstTokenMsKey)
+			var events int
+			for _, event := range got.Events {
+				if event.Name == LLMFirstTokenEvent {
+					events++
+				}
+			}
+			if tt.chunks == 0 {
+				if ok || events != 0 {
+					t.Errorf("time to first token recorded without tokens")
+				}
+				return
+			}
+			if ttft.AsInt64() < firstTokenDelay.Milliseconds() || ttft.AsInt64() > duration.AsInt64() {
+				t.Errorf("%s = %d, want between %d and the duration %d", LLMTimeToFirstTokenMsKey, ttft.AsInt64(), firstTokenDelay.Milliseconds(), duration.AsInt64())
+			}
+			if events != 1 {
+				t.Errorf("recorded %d %s events, want 1", events, LLMFirstTokenEvent)
+			}
+		})
+	}
+}
+
+func TestTraceLLMStreamPanic(t *testing.T) {
+	client, exporter := newTestClient(t, nil)
+	instr := NewInstrumentation(client, DefaultInstrumentationConfig())
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic not propagated")
+			}
+		}()
+		_ = instr.TraceLLMStream(context.Background(), "llm.stream", LLMSpanOptions{Provider: "openai", Model: "gpt-4"},
+			func(ctx context.Context, emit func()) error {
+				emit()
+				panic("decoder failed")
+			})
+	}()
+
+	got := findSpan(t, exportedSpans(t, client, exporter), "llm.stream")
+	if aborted, _ := attrValue(got.Attributes, LLMStreamAbortedKey); !aborted.AsBool() {
+		t.Errorf("%s = false after a panic, want true", LLMStreamAbortedKey)
+	}
+}
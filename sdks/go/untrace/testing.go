@@ -0,0 +1,71 @@
+package untrace
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// SpanStub is a snapshot of an exported span: its name, span context, parent,
+// attributes, events and status
+type SpanStub = tracetest.SpanStub
+
+// TestClient is a client that records exported spans in memory for
+// assertions in tests
+type TestClient struct {
+	Client
+	exporter *tracetest.InMemoryExporter
+}
+
+// InitForTesting initializes the Untrace SDK with the default configuration
+// and an in-memory exporter in place of the Untrace API. Any client
+// initialized before is shut down first, so each test gets a fresh one.
+// Recorded spans are discarded when the client is shut down.
+func InitForTesting() (*TestClient, error) {
+	if previous := GetInstance(); previous != nil {
+		if err := previous.Shutdown(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	config := DefaultConfig("test")
+	config.SpanExporter = exporter
+
+	client, err := Init(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TestClient{
+		Client:   client,
+		exporter: exporter,
+	}, nil
+}
+
+// Reset clears the SDK's process-wide state so a test can start over as if
+// the process had just started, e.g. to observe the cold start span again.
+// It fails while a client is live; shut it down first.
+func Reset() error {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+
+	if globalClient != nil {
+		return NewConfigurationError("cannot reset while a client is live", ErrAlreadyInitialized)
+	}
+
+	coldStartClaimed.Store(false)
+	return nil
+}
+
+// RecordedSpans flushes pending spans and returns every span exported so far
+func (c *TestClient) RecordedSpans() []SpanStub {
+	// A failed flush still leaves the spans exported before it
+	_ = c.Flush(context.Background())
+	return c.exporter.GetSpans()
+}
+
+// Reset discards the recorded spans
+func (c *TestClient) Reset() {
+	c.exporter.Reset()
+}
@@ -0,0 +1,110 @@
+package untrace
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInitForTesting(t *testing.T) {
+	tests := []struct {
+		name  string
+		spans []string
+	}{
+		{name: "no spans"},
+		{name: "one span", spans: []string{"llm.chat"}},
+		{name: "several spans", spans: []string{"retrieve", "llm.chat", "tool.search"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A client left over from an earlier test is replaced
+			stale, err := InitForTesting()
+			if err != nil {
+				t.Fatalf("InitForTesting() error = %v", err)
+			}
+			client, err := InitForTesting()
+			if err != nil {
+				t.Fatalf("second InitForTesting() error = %v", err)
+			}
+			t.Cleanup(func() {
+				_ = client.Shutdown(context.Background())
+				_ = Reset()
+			})
+			if GetInstance() != client.Client || GetInstance() == stale.Client {
+				t.Fatalf("InitForTesting() did not replace the previous client")
+			}
+
+			for _, name := range tt.spans {
+				_, span := client.Tracer().StartSpan(context.Background(), name, SpanOptions{})
+				span.End()
+			}
+
+			recorded := client.RecordedSpans()
+			if len(recorded) != len(tt.spans) {
+				t.Fatalf("RecordedSpans() = %v, want %v", spanNames(recorded), tt.spans)
+			}
+			for i, span := range recorded {
+				if span.Name != tt.spans[i] {
+					t.Errorf("RecordedSpans() = %v, want %v", spanNames(recorded), tt.spans)
+					break
+				}
+			}
+
+			client.Reset()
+			if recorded := client.RecordedSpans(); len(recorded) != 0 {
+				t.Errorf("RecordedSpans() after Reset = %v, want none", spanNames(recorded))
+			}
+		})
+	}
+}
+
+func TestReset(t *testing.T) {
+	tests := []struct {
+		name     string
+		shutdown bool
+		wantErr  bool
+	}{
+		{name: "client live", wantErr: true},
+		{name: "client shut down", shutdown: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig("test-key")
+			config.SpanExporter = tracetest.NewInMemoryExporter()
+			client, err := Init(config)
+			if err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+			t.Cleanup(func() {
+				_ = client.Shutdown(context.Background())
+				_ = Reset()
+			})
+			if tt.shutdown {
+				if err := client.Shutdown(context.Background()); err != nil {
+					t.Fatalf("Shutdown() error = %v", err)
+				}
+			}
+
+			err = Reset()
+			if tt.wantErr {
+				if !errors.Is(err, ErrAlreadyInitialized) {
+					t.Errorf("Reset() error = %v, want ErrAlreadyInitialized", err)
+				}
+				if GetInstance() != client {
+					t.Errorf("Reset() cleared a live client")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reset() error = %v", err)
+			}
+			if coldStartClaimed.Load() {
+				t.Errorf("cold start still claimed after Reset")
+			}
+		})
+	}
+}
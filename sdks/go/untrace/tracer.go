@@ -2,23 +2,35 @@ package untrace
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
 // untraceTracer implements the Tracer interface
 type untraceTracer struct {
 	tracer trace.Tracer
+	config Config
 }
 
 // NewTracer creates a new Untrace tracer
 func NewTracer(tracer trace.Tracer) Tracer {
+	return newTracer(tracer, Config{})
+}
+
+// newTracer creates a tracer honoring the span-related config
+func newTracer(tracer trace.Tracer, config Config) *untraceTracer {
 	return &untraceTracer{
 		tracer: tracer,
+		config: config,
 	}
 }
 
@@ -28,9 +40,84 @@ func (t *untraceTracer) StartLLMSpan(ctx context.Context, name string, opts LLMS
 
 	spanCtx, span := t.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
 
+	// Mark the span failed so backends flag it, not just the llm.error attribute
+	if opts.Error != nil {
+		llmErr := &LLMError{Message: *opts.Error}
+		if opts.ErrorType != nil {
+			llmErr.Type = *opts.ErrorType
+		}
+		span.RecordError(llmErr)
+		span.SetStatus(codes.Error, *opts.Error)
+	}
+
+	if t.config.CaptureContent {
+		t.recordMessages(span, opts.Messages, opts.OutputMessages, nil)
+	}
+
 	return spanCtx, span
 }
 
+// recordMessages records captured input and output messages on the span,
+// laid out according to the configured attribute convention. A non-nil
+// transform, such as truncation, is applied to the serialized messages
+func (t *untraceTracer) recordMessages(span trace.Span, input, output []Message, transform func(string) string) {
+	if transform == nil {
+		transform = func(s string) string { return s }
+	}
+
+	if len(input) > 0 {
+		value := transform(marshalMessages(input))
+		if t.config.AttributeConvention == AttributeConventionOpenInference {
+			span.AddEvent(OpenInferenceInputEvent, trace.WithAttributes(
+				attribute.String(OpenInferenceInputValueKey, value),
+				attribute.String(OpenInferenceInputMimeTypeKey, "application/json"),
+			))
+		} else {
+			span.SetAttributes(attribute.String(LLMPromptKey, value))
+		}
+	}
+
+	if len(output) > 0 {
+		value := transform(marshalMessages(output))
+		if t.config.AttributeConvention == AttributeConventionOpenInference {
+			span.AddEvent(OpenInferenceOutputEvent, trace.WithAttributes(
+				attribute.String(OpenInferenceOutputValueKey, value),
+				attribute.String(OpenInferenceOutputMimeTypeKey, "application/json"),
+			))
+		} else {
+			span.SetAttributes(attribute.String(LLMCompletionKey, value))
+		}
+	}
+}
+
+// capturesContent reports whether StartLLMSpan already records the messages
+// in its options
+func (t *untraceTracer) capturesContent() bool {
+	return t.config.CaptureContent
+}
+
+// hashMessages returns the hex SHA-256 of the normalized messages. Message
+// order is significant, so only whitespace is normalized
+func hashMessages(messages []Message) string {
+	h := sha256.New()
+	for _, message := range messages {
+		h.Write([]byte(strings.TrimSpace(message.Role)))
+		h.Write([]byte{0})
+		h.Write([]byte(strings.TrimSpace(message.Content)))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// marshalMessages serializes messages as a JSON array
+func marshalMessages(messages []Message) string {
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return fmt.Sprintf("%v", messages)
+	}
+	return string(data)
+}
+
 // StartSpan starts a new span with the given options
 func (t *untraceTracer) StartSpan(ctx context.Context, name string, opts SpanOptions) (context.Context, trace.Span) {
 	var spanOpts []trace.SpanStartOption
@@ -49,10 +136,50 @@ func (t *untraceTracer) StartSpan(ctx context.Context, name string, opts SpanOpt
 		spanOpts = append(spanOpts, trace.WithAttributes(attrs...))
 	}
 
+	if len(opts.Links) > 0 {
+		spanOpts = append(spanOpts, trace.WithLinks(opts.Links...))
+	}
+
 	spanCtx, span := t.tracer.Start(ctx, name, spanOpts...)
 	return spanCtx, span
 }
 
+// LinkFromContext returns a link to the span in ctx, to be passed in
+// SpanOptions.Links, e.g. from each request joining a batch
+func LinkFromContext(ctx context.Context, attrs ...attribute.KeyValue) trace.Link {
+	return trace.Link{
+		SpanContext: trace.SpanContextFromContext(ctx),
+		Attributes:  attrs,
+	}
+}
+
+// StartEmbeddingSpan starts a new embedding span carrying both the LLM
+// attributes and the vector count and dimension. An empty name defaults to
+// the operation and input count, e.g. "embedding (batch=32)"
+func (t *untraceTracer) StartEmbeddingSpan(ctx context.Context, name string, opts EmbeddingSpanOptions) (context.Context, trace.Span) {
+	if name == "" {
+		name = fmt.Sprintf("%s (batch=%d)", LLMOperationEmbedding, opts.InputCount)
+	}
+
+	attrs := make(map[string]interface{}, len(opts.Attributes)+2)
+	if opts.InputCount > 0 {
+		attrs[VectorCountKey] = opts.InputCount
+	}
+	if opts.Dimension > 0 {
+		attrs[VectorDimensionKey] = opts.Dimension
+	}
+	for key, value := range opts.Attributes {
+		attrs[key] = value
+	}
+
+	return t.StartLLMSpan(ctx, name, LLMSpanOptions{
+		Provider:   opts.Provider,
+		Model:      opts.Model,
+		Operation:  LLMOperationEmbedding,
+		Attributes: attrs,
+	})
+}
+
 // GetTracer returns the underlying OpenTelemetry tracer
 func (t *untraceTracer) GetTracer() trace.Tracer {
 	return t.tracer
@@ -75,6 +202,12 @@ func (t *untraceTracer) buildLLMAttributes(opts LLMSpanOptions) []attribute.KeyV
 	if opts.TotalTokens != nil {
 		attrs = append(attrs, attribute.Int("llm.total.tokens", *opts.TotalTokens))
 	}
+	if opts.PromptTokens != nil {
+		attrs = append(attrs, t.contextUtilizationAttributes(opts.Provider, opts.Model, *opts.PromptTokens)...)
+	}
+	if opts.ReasoningTokens != nil {
+		attrs = append(attrs, attribute.Int(LLMReasoningTokensKey, *opts.ReasoningTokens))
+	}
 	if opts.Temperature != nil {
 		attrs = append(attrs, attribute.Float64("llm.temperature", *opts.Temperature))
 	}
@@ -117,6 +250,32 @@ func (t *untraceTracer) buildLLMAttributes(opts LLMSpanOptions) []attribute.KeyV
 	if opts.UsageReason != nil {
 		attrs = append(attrs, attribute.String("llm.usage.reason", *opts.UsageReason))
 	}
+	if opts.QueueWaitMs != nil {
+		attrs = append(attrs, attribute.Int(LLMQueueWaitMsKey, *opts.QueueWaitMs))
+	}
+	if opts.CacheReadTokens != nil {
+		attrs = append(attrs, attribute.Int(LLMCacheReadTokensKey, *opts.CacheReadTokens))
+	}
+	if opts.CacheCreationTokens != nil {
+		attrs = append(attrs, attribute.Int(LLMCacheCreationTokensKey, *opts.CacheCreationTokens))
+	}
+	if opts.MatchedStopSequence != nil {
+		attrs = append(attrs, attribute.String(LLMStopMatchedKey, *opts.MatchedStopSequence))
+	}
+	if len(opts.Modalities) > 0 {
+		attrs = append(attrs, attribute.StringSlice(LLMModalitiesKey, opts.Modalities))
+	}
+	if opts.InputImageCount != nil {
+		attrs = append(attrs, attribute.Int(LLMInputImageCountKey, *opts.InputImageCount))
+	}
+	if opts.InputAudioSeconds != nil {
+		attrs = append(attrs, attribute.Float64(LLMInputAudioSecondsKey, *opts.InputAudioSeconds))
+	}
+	if len(opts.Messages) > 0 {
+		// Hashed regardless of CaptureContent so caching can be analyzed
+		// without storing content
+		attrs = append(attrs, attribute.String(LLMPromptHashKey, hashMessages(opts.Messages)))
+	}
 
 	// Add custom attributes
 	customAttrs := t.buildAttributes(opts.Attributes)
@@ -125,6 +284,13 @@ func (t *untraceTracer) buildLLMAttributes(opts LLMSpanOptions) []attribute.KeyV
 	return attrs
 }
 
+// AddLLMEvent adds a timestamped event such as LLMToolCallStartEvent or
+// LLMToolCallEndEvent to an LLM span, marking a step within the call.
+// Attribute slices are limited like span attributes.
+func (t *untraceTracer) AddLLMEvent(span trace.Span, name string, attrs map[string]interface{}) {
+	span.AddEvent(name, trace.WithAttributes(t.buildAttributes(attrs)...))
+}
+
 // buildAttributes converts a map of attributes to OpenTelemetry attributes
 func (t *untraceTracer) buildAttributes(attrs map[string]interface{}) []attribute.KeyValue {
 	var result []attribute.KeyValue
@@ -142,11 +308,17 @@ func (t *untraceTracer) buildAttributes(attrs map[string]interface{}) []attribut
 		case bool:
 			result = append(result, attribute.Bool(key, v))
 		case []string:
-			result = append(result, attribute.StringSlice(key, v))
+			n, truncated := t.sliceLimit(len(v))
+			result = append(result, attribute.StringSlice(key, v[:n]))
+			result = appendTruncatedMarker(result, key, truncated)
 		case []int:
-			result = append(result, attribute.IntSlice(key, v))
+			n, truncated := t.sliceLimit(len(v))
+			result = append(result, attribute.IntSlice(key, v[:n]))
+			result = appendTruncatedMarker(result, key, truncated)
 		case []float64:
-			result = append(result, attribute.Float64Slice(key, v))
+			n, truncated := t.sliceLimit(len(v))
+			result = append(result, attribute.Float64Slice(key, v[:n]))
+			result = appendTruncatedMarker(result, key, truncated)
 		case time.Time:
 			result = append(result, attribute.String(key, v.Format(time.RFC3339)))
 		default:
@@ -157,3 +329,46 @@ func (t *untraceTracer) buildAttributes(attrs map[string]interface{}) []attribut
 
 	return result
 }
+
+// contextUtilizationAttributes describes how full the model's context window
+// was, if its size is known
+func (t *untraceTracer) contextUtilizationAttributes(provider, model string, promptTokens int) []attribute.KeyValue {
+	window, ok := LookupContextWindow(provider, model)
+	if !ok || window <= 0 {
+		return nil
+	}
+
+	utilization := float64(promptTokens) / float64(window)
+	attrs := []attribute.KeyValue{
+		attribute.Int(LLMContextWindowKey, window),
+		attribute.Float64(LLMContextUtilizationKey, utilization),
+	}
+
+	threshold := t.config.ContextUtilizationThreshold
+	if threshold == 0 {
+		threshold = DefaultContextUtilizationThreshold
+	}
+	if utilization >= threshold {
+		attrs = append(attrs, attribute.Bool(LLMContextHighUtilizationKey, true))
+	}
+	return attrs
+}
+
+// sliceLimit returns how many elements of a slice attribute of the given
+// length are kept under Config.MaxAttributeSliceLen, and whether it is cut
+func (t *untraceTracer) sliceLimit(length int) (int, bool) {
+	limit := t.config.MaxAttributeSliceLen
+	if limit <= 0 || length <= limit {
+		return length, false
+	}
+	return limit, true
+}
+
+// appendTruncatedMarker flags a truncated slice attribute with a
+// <key>_truncated attribute
+func appendTruncatedMarker(attrs []attribute.KeyValue, key string, truncated bool) []attribute.KeyValue {
+	if !truncated {
+		return attrs
+	}
+	return append(attrs, attribute.Bool(key+"_truncated", true))
+}
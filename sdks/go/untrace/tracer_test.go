@@ -0,0 +1,569 @@
+package untrace
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCapturedContentConvention(t *testing.T) {
+	input := []Message{{Role: "user", Content: "Hi"}}
+	output := []Message{{Role: "assistant", Content: "Hello!"}}
+	wantInput := `[{"role":"user","content":"Hi"}]`
+	wantOutput := `[{"role":"assistant","content":"Hello!"}]`
+
+	tests := []struct {
+		name       string
+		capture    bool
+		convention AttributeConvention
+		wantAttrs  bool
+		wantEvents bool
+	}{
+		{name: "capture off", convention: AttributeConventionOpenInference},
+		{name: "untrace attributes", capture: true, convention: AttributeConventionUntrace, wantAttrs: true},
+		{name: "openinference events", capture: true, convention: AttributeConventionOpenInference, wantEvents: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.CaptureContent = tt.capture
+				c.AttributeConvention = tt.convention
+			})
+
+			_, span := client.Tracer().StartLLMSpan(context.Background(), "llm.chat", LLMSpanOptions{
+				Provider:       "openai",
+				Model:          "gpt-4",
+				Messages:       input,
+				OutputMessages: output,
+			})
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.chat")
+
+			prompt, hasPrompt := attrValue(got.Attributes, LLMPromptKey)
+			completion, hasCompletion := attrValue(got.Attributes, LLMCompletionKey)
+			if hasPrompt != tt.wantAttrs || hasCompletion != tt.wantAttrs {
+				t.Errorf("prompt/completion attributes set = %v/%v, want %v", hasPrompt, hasCompletion, tt.wantAttrs)
+			}
+			if tt.wantAttrs && (prompt.AsString() != wantInput || completion.AsString() != wantOutput) {
+				t.Errorf("prompt = %s, completion = %s", prompt.AsString(), completion.AsString())
+			}
+
+			events := make(map[string]map[string]string)
+			for _, event := range got.Events {
+				attrs := make(map[string]string)
+				for _, attr := range event.Attributes {
+					attrs[string(attr.Key)] = attr.Value.AsString()
+				}
+				events[event.Name] = attrs
+			}
+			if !tt.wantEvents {
+				if len(events) != 0 {
+					t.Errorf("events = %v, want none", events)
+				}
+				return
+			}
+
+			wantEvents := map[string]map[string]string{
+				OpenInferenceInputEvent: {
+					OpenInferenceInputValueKey:    wantInput,
+					OpenInferenceInputMimeTypeKey: "application/json",
+				},
+				OpenInferenceOutputEvent: {
+					OpenInferenceOutputValueKey:    wantOutput,
+					OpenInferenceOutputMimeTypeKey: "application/json",
+				},
+			}
+			for name, wantAttrs := range wantEvents {
+				attrs, ok := events[name]
+				if !ok {
+					t.Errorf("event %q not recorded", name)
+					continue
+				}
+				for key, want := range wantAttrs {
+					if attrs[key] != want {
+						t.Errorf("event %q: %s = %q, want %q", name, key, attrs[key], want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEmbeddingSpanName(t *testing.T) {
+	tests := []struct {
+		name       string
+		spanName   string
+		inputCount int
+		want       string
+	}{
+		{name: "generated from input count", inputCount: 32, want: "embedding (batch=32)"},
+		{name: "single input", inputCount: 1, want: "embedding (batch=1)"},
+		{name: "explicit name", spanName: "embed-docs", inputCount: 32, want: "embed-docs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			_, span := client.Tracer().StartEmbeddingSpan(context.Background(), tt.spanName, EmbeddingSpanOptions{
+				Provider:   "openai",
+				Model:      "text-embedding-3-small",
+				InputCount: tt.inputCount,
+			})
+			span.End()
+
+			spans := exportedSpans(t, client, exporter)
+			if len(spans) != 1 || spans[0].Name != tt.want {
+				t.Errorf("exported %v, want [%s]", spanNames(spans), tt.want)
+			}
+		})
+	}
+}
+
+func TestPromptHash(t *testing.T) {
+	messages := []Message{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "Hi"}}
+	reference := hashMessages(messages)
+
+	tests := []struct {
+		name     string
+		messages []Message
+		capture  bool
+		wantSame bool
+	}{
+		{name: "same messages", messages: messages, wantSame: true},
+		{name: "captured content", messages: messages, capture: true, wantSame: true},
+		{
+			name:     "whitespace differences",
+			messages: []Message{{Role: "system", Content: "  Be brief.\n"}, {Role: "user", Content: "Hi "}},
+			wantSame: true,
+		},
+		{name: "reordered messages", messages: []Message{messages[1], messages[0]}},
+		{name: "different content", messages: []Message{messages[0], {Role: "user", Content: "Hello"}}},
+		{name: "no messages"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.CaptureContent = tt.capture
+			})
+
+			_, span := client.Tracer().StartLLMSpan(context.Background(), "llm.chat", LLMSpanOptions{
+				Provider: "openai",
+				Model:    "gpt-4",
+				Messages: tt.messages,
+			})
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.chat")
+			hash, ok := attrValue(got.Attributes, LLMPromptHashKey)
+			if len(tt.messages) == 0 {
+				if ok {
+					t.Errorf("%s = %q set without messages", LLMPromptHashKey, hash.AsString())
+				}
+				return
+			}
+			if len(hash.AsString()) != 64 {
+				t.Fatalf("%s = %q, want a hex SHA-256", LLMPromptHashKey, hash.AsString())
+			}
+			if same := hash.AsString() == reference; same != tt.wantSame {
+				t.Errorf("hash matches the reference = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestMatchedStopSequence(t *testing.T) {
+	stop := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		matched *string
+	}{
+		{name: "stop sequence matched", matched: stop("\n\nHuman:")},
+		{name: "empty stop sequence", matched: stop("")},
+		{name: "no stop sequence"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			_, span := client.Tracer().StartLLMSpan(context.Background(), "llm.chat", LLMSpanOptions{
+				Provider:            "anthropic",
+				Model:               "claude-2",
+				MatchedStopSequence: tt.matched,
+			})
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.chat")
+			value, ok := attrValue(got.Attributes, LLMStopMatchedKey)
+			if tt.matched == nil {
+				if ok {
+					t.Errorf("%s = %q, want it absent", LLMStopMatchedKey, value.AsString())
+				}
+				return
+			}
+			if !ok || value.AsString() != *tt.matched {
+				t.Errorf("%s = %q, want %q", LLMStopMatchedKey, value.AsString(), *tt.matched)
+			}
+		})
+	}
+}
+
+func TestModalities(t *testing.T) {
+	images := 2
+	audioSeconds := 12.5
+
+	tests := []struct {
+		name      string
+		opts      LLMSpanOptions
+		wantAttrs map[string]string
+		absent    []string
+	}{
+		{
+			name:   "text only",
+			absent: []string{LLMModalitiesKey, LLMInputImageCountKey, LLMInputAudioSecondsKey},
+		},
+		{
+			name: "text and images",
+			opts: LLMSpanOptions{Modalities: []string{ModalityText, ModalityImage}, InputImageCount: &images},
+			wantAttrs: map[string]string{
+				LLMModalitiesKey:      "[text image]",
+				LLMInputImageCountKey: "2",
+			},
+			absent: []string{LLMInputAudioSecondsKey},
+		},
+		{
+			name: "audio",
+			opts: LLMSpanOptions{Modalities: []string{ModalityAudio}, InputAudioSeconds: &audioSeconds},
+			wantAttrs: map[string]string{
+				LLMModalitiesKey:        "[audio]",
+				LLMInputAudioSecondsKey: "12.5",
+			},
+			absent: []string{LLMInputImageCountKey},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			tt.opts.Provider = "openai"
+			tt.opts.Model = "gpt-4o"
+			_, span := client.Tracer().StartLLMSpan(context.Background(), "llm.chat", tt.opts)
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.chat")
+			for key, want := range tt.wantAttrs {
+				if value, _ := attrValue(got.Attributes, key); value.Emit() != want {
+					t.Errorf("%s = %s, want %s", key, value.Emit(), want)
+				}
+			}
+			for _, key := range tt.absent {
+				if _, ok := attrValue(got.Attributes, key); ok {
+					t.Errorf("%s is set, want it absent", key)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxAttributeSliceLen(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit         int
+		value         interface{}
+		want          string
+		wantTruncated bool
+	}{
+		{name: "no limit", value: []string{"a", "b", "c", "d"}, want: "[a b c d]"},
+		{name: "within limit", limit: 4, value: []string{"a", "b", "c", "d"}, want: "[a b c d]"},
+		{name: "strings cut", limit: 2, value: []string{"a", "b", "c", "d"}, want: "[a b]", wantTruncated: true},
+		{name: "ints cut", limit: 3, value: []int{1, 2, 3, 4, 5}, want: "[1 2 3]", wantTruncated: true},
+		{name: "floats cut", limit: 1, value: []float64{0.5, 0.25}, want: "[0.5]", wantTruncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, func(c *Config) {
+				c.MaxAttributeSliceLen = tt.limit
+			})
+
+			_, span := client.Tracer().StartSpan(context.Background(), "retrieve", SpanOptions{
+				Attributes: map[string]interface{}{"doc.ids": tt.value},
+			})
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "retrieve")
+			if value, _ := attrValue(got.Attributes, "doc.ids"); value.Emit() != tt.want {
+				t.Errorf("doc.ids = %s, want %s", value.Emit(), tt.want)
+			}
+			truncated, ok := attrValue(got.Attributes, "doc.ids_truncated")
+			if ok != tt.wantTruncated || (ok && !truncated.AsBool()) {
+				t.Errorf("doc.ids_truncated = %v (set: %v), want %v", truncated.AsBool(), ok, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestLLMSpanErrorStatus(t *testing.T) {
+	message := "rate limit exceeded"
+	errorType := "rate_limit_error"
+
+	tests := []struct {
+		name       string
+		err        *string
+		errorType  *string
+		wantStatus codes.Code
+	}{
+		{name: "no error", wantStatus: codes.Unset},
+		{name: "error message", err: &message, wantStatus: codes.Error},
+		{name: "error message and type", err: &message, errorType: &errorType, wantStatus: codes.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			_, span := client.Tracer().StartLLMSpan(context.Background(), "llm.chat", LLMSpanOptions{
+				Provider:  "openai",
+				Model:     "gpt-4",
+				Error:     tt.err,
+				ErrorType: tt.errorType,
+			})
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.chat")
+			if got.Status.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", got.Status.Code, tt.wantStatus)
+			}
+			if tt.err == nil {
+				if len(got.Events) != 0 {
+					t.Errorf("recorded %d events, want none", len(got.Events))
+				}
+				return
+			}
+
+			if got.Status.Description != *tt.err {
+				t.Errorf("status description = %q, want %q", got.Status.Description, *tt.err)
+			}
+			if len(got.Events) != 1 || got.Events[0].Name != "exception" {
+				t.Fatalf("events = %v, want one exception", got.Events)
+			}
+			if msg, _ := attrValue(got.Events[0].Attributes, "exception.message"); msg.AsString() != *tt.err {
+				t.Errorf("exception.message = %q, want %q", msg.AsString(), *tt.err)
+			}
+			if llmErr, _ := attrValue(got.Attributes, LLMErrorKey); llmErr.AsString() != *tt.err {
+				t.Errorf("%s = %q, want %q", LLMErrorKey, llmErr.AsString(), *tt.err)
+			}
+		})
+	}
+}
+
+func TestSpanEvents(t *testing.T) {
+	// event is an event to add and the attributes it should be exported with
+	type event struct {
+		name  string
+		attrs map[string]interface{}
+		want  map[string]attribute.Value
+	}
+
+	tests := []struct {
+		name   string
+		add    func(client Client, span trace.Span, name string, attrs map[string]interface{})
+		events []event
+	}{
+		{
+			name: "AddEvent",
+			add: func(client Client, span trace.Span, name string, attrs map[string]interface{}) {
+				AddEvent(span, name, attrs)
+			},
+			events: []event{
+				{name: "cache.miss"},
+				{
+					name:  "retry",
+					attrs: map[string]interface{}{"attempt": 2, "backoff.ms": 250.5},
+					want:  map[string]attribute.Value{"attempt": attribute.IntValue(2), "backoff.ms": attribute.Float64Value(250.5)},
+				},
+			},
+		},
+		{
+			name: "AddLLMEvent tool call",
+			add: func(client Client, span trace.Span, name string, attrs map[string]interface{}) {
+				client.Tracer().AddLLMEvent(span, name, attrs)
+			},
+			events: []event{
+				{name: LLMFirstTokenEvent},
+				{
+					name:  LLMToolCallStartEvent,
+					attrs: map[string]interface{}{"tool.name": "search"},
+					want:  map[string]attribute.Value{"tool.name": attribute.StringValue("search")},
+				},
+				{
+					name:  LLMToolCallEndEvent,
+					attrs: map[string]interface{}{"tool.name": "search", "tool.success": true},
+					want:  map[string]attribute.Value{"tool.name": attribute.StringValue("search"), "tool.success": attribute.BoolValue(true)},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			_, span := client.Tracer().StartLLMSpan(context.Background(), "llm.chat", LLMSpanOptions{Provider: "openai", Model: "gpt-4"})
+			for _, e := range tt.events {
+				tt.add(client, span, e.name, e.attrs)
+			}
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "llm.chat")
+			if len(got.Events) != len(tt.events) {
+				t.Fatalf("exported %d events, want %d", len(got.Events), len(tt.events))
+			}
+			for i, e := range tt.events {
+				exported := got.Events[i]
+				if exported.Name != e.name {
+					t.Errorf("event %d name = %q, want %q", i, exported.Name, e.name)
+				}
+				if len(exported.Attributes) != len(e.want) {
+					t.Errorf("event %q has %d attributes, want %d", e.name, len(exported.Attributes), len(e.want))
+				}
+				for key, want := range e.want {
+					if value, ok := attrValue(exported.Attributes, key); !ok || value != want {
+						t.Errorf("event %q %s = %v, want %v", e.name, key, value.Emit(), want.Emit())
+					}
+				}
+
+				// Events are timestamped in order within the span
+				if exported.Time.Before(got.StartTime) || exported.Time.After(got.EndTime) {
+					t.Errorf("event %q at %v, outside the span [%v, %v]", e.name, exported.Time, got.StartTime, got.EndTime)
+				}
+				if i > 0 && exported.Time.Before(got.Events[i-1].Time) {
+					t.Errorf("event %q at %v, before the previous event", e.name, exported.Time)
+				}
+			}
+		})
+	}
+}
+
+func TestSpanLinks(t *testing.T) {
+	tests := []struct {
+		name      string
+		requests  int
+		linkAttrs []attribute.KeyValue
+	}{
+		{name: "no links"},
+		{name: "two links", requests: 2},
+		{name: "links with attributes", requests: 2, linkAttrs: []attribute.KeyValue{attribute.String("link.reason", "batched")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			// Each request is its own trace, joined into one batch span
+			var links []trace.Link
+			var requests []trace.SpanContext
+			for i := 0; i < tt.requests; i++ {
+				ctx, span := client.Tracer().StartSpan(context.Background(), "request", SpanOptions{})
+				links = append(links, LinkFromContext(ctx, tt.linkAttrs...))
+				requests = append(requests, span.SpanContext())
+				span.End()
+			}
+
+			_, batch := client.Tracer().StartSpan(context.Background(), "batch", SpanOptions{Links: links})
+			batch.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "batch")
+			if len(got.Links) != len(requests) {
+				t.Fatalf("exported %d links, want %d", len(got.Links), len(requests))
+			}
+			for i, link := range got.Links {
+				if !link.SpanContext.Equal(requests[i]) {
+					t.Errorf("link %d = %v, want request span %v", i, link.SpanContext.SpanID(), requests[i].SpanID())
+				}
+				if len(link.Attributes) != len(tt.linkAttrs) {
+					t.Errorf("link %d attributes = %v, want %v", i, link.Attributes, tt.linkAttrs)
+				}
+			}
+			if got.Parent.IsValid() {
+				t.Errorf("batch span has parent %v, want a root span", got.Parent.SpanID())
+			}
+		})
+	}
+}
+
+func TestEmbeddingSpanAttributes(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   EmbeddingSpanOptions
+		want   map[string]attribute.Value
+		absent []string
+	}{
+		{
+			name: "count and dimension",
+			opts: EmbeddingSpanOptions{Provider: "openai", Model: "text-embedding-3-small", InputCount: 32, Dimension: 1536},
+			want: map[string]attribute.Value{
+				LLMProviderKey:      attribute.StringValue("openai"),
+				LLMModelKey:         attribute.StringValue("text-embedding-3-small"),
+				LLMOperationTypeKey: attribute.StringValue(string(LLMOperationEmbedding)),
+				VectorCountKey:      attribute.IntValue(32),
+				VectorDimensionKey:  attribute.IntValue(1536),
+			},
+		},
+		{
+			name: "dimension unknown",
+			opts: EmbeddingSpanOptions{Provider: "cohere", Model: "embed-english-v3.0", InputCount: 4},
+			want: map[string]attribute.Value{
+				LLMProviderKey:      attribute.StringValue("cohere"),
+				LLMOperationTypeKey: attribute.StringValue(string(LLMOperationEmbedding)),
+				VectorCountKey:      attribute.IntValue(4),
+			},
+			absent: []string{VectorDimensionKey},
+		},
+		{
+			name: "custom attributes kept",
+			opts: EmbeddingSpanOptions{
+				Provider:   "openai",
+				Model:      "text-embedding-3-large",
+				InputCount: 1,
+				Dimension:  3072,
+				Attributes: map[string]interface{}{"corpus": "docs"},
+			},
+			want: map[string]attribute.Value{
+				VectorCountKey:     attribute.IntValue(1),
+				VectorDimensionKey: attribute.IntValue(3072),
+				"corpus":           attribute.StringValue("docs"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, exporter := newTestClient(t, nil)
+
+			_, span := client.Tracer().StartEmbeddingSpan(context.Background(), "embed", tt.opts)
+			span.End()
+
+			got := findSpan(t, exportedSpans(t, client, exporter), "embed")
+			for key, want := range tt.want {
+				if value, ok := attrValue(got.Attributes, key); !ok || value != want {
+					t.Errorf("%s = %v, want %v", key, value.Emit(), want.Emit())
+				}
+			}
+			for _, key := range tt.absent {
+				if value, ok := attrValue(got.Attributes, key); ok {
+					t.Errorf("%s = %v, want unset", key, value.Emit())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package untrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// llmTransport is an http.RoundTripper tracing requests to LLM APIs
+type llmTransport struct {
+	client Client
+	base   http.RoundTripper
+}
+
+// NewTransport returns an http.RoundTripper that traces requests to known LLM
+// APIs (OpenAI, Anthropic and Azure OpenAI) as LLM spans, propagates the W3C
+// trace context to them, and records token usage, cost and latency from their
+// JSON responses. Other requests are passed to base unchanged. A nil base
+// uses http.DefaultTransport.
+//
+// Streamed (text/event-stream) responses are not parsed for usage, and their
+// span ends once the response headers arrive.
+func NewTransport(client Client, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &llmTransport{client: client, base: base}
+}
+
+// RoundTrip traces the request if it targets a known LLM API
+func (t *llmTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	provider := llmProviderForHost(req.URL.Hostname())
+	if provider == "" || t.client == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	// Read the model from the request body, which is replayed to the base
+	// transport below
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	model := jsonModel(reqBody)
+
+	opts := LLMSpanOptions{
+		Provider:  provider,
+		Model:     model,
+		Operation: llmOperationForPath(req.URL.Path),
+	}
+	ctx, span := t.client.Tracer().StartLLMSpan(req.Context(), fmt.Sprintf("%s.%s", provider, opts.Operation), opts)
+	defer span.End()
+
+	// A RoundTripper must not modify the caller's request
+	out := req.Clone(ctx)
+	if reqBody != nil {
+		out.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	textMapPropagator().Inject(ctx, propagation.HeaderCarrier(out.Header))
+
+	metrics := t.client.Metrics().WithContext(ctx)
+	labels := map[string]interface{}{
+		"provider":  provider,
+		"model":     model,
+		"operation": string(opts.Operation),
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(out)
+	duration := time.Since(start)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		metrics.RecordError(err, labels)
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := NewAPIError(http.StatusText(resp.StatusCode), resp.StatusCode, "", nil)
+		span.RecordError(apiErr)
+		span.SetStatus(codes.Error, apiErr.Error())
+		metrics.RecordError(apiErr, labels)
+		return resp, nil
+	}
+	metrics.RecordLatency(duration, labels)
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/json" || resp.Body == nil {
+		return resp, nil
+	}
+
+	// Parse usage from the body, restoring it for the caller
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return resp, nil
+	}
+
+	usage, requestID := parseLLMUsage(body)
+	usage.Provider = provider
+	if usage.Model == "" {
+		usage.Model = model
+	}
+
+	result := LLMResult{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		RequestID:        requestID,
+	}
+	metrics.RecordTokenUsage(usage)
+	if cost, err := CalculateCost(usage.Provider, usage.Model, usage); err == nil {
+		metrics.RecordCost(cost)
+		result.Cost = &cost
+	}
+	SetLLMResult(span, result)
+
+	return resp, nil
+}
+
+// llmProviderForHost returns the LLM provider serving the host, or "" if it
+// is not a known LLM API
+func llmProviderForHost(host string) string {
+	switch {
+	case host == "api.openai.com":
+		return "openai"
+	case host == "api.anthropic.com":
+		return "anthropic"
+	case strings.HasSuffix(host, ".openai.azure.com"):
+		return "azure_openai"
+	default:
+		return ""
+	}
+}
+
+// llmOperationForPath returns the LLM operation of an API path
+func llmOperationForPath(path string) LLMOperationType {
+	switch {
+	case strings.HasSuffix(path, "/chat/completions"), strings.HasSuffix(path, "/messages"):
+		return LLMOperationChat
+	case strings.HasSuffix(path, "/completions"), strings.HasSuffix(path, "/complete"):
+		return LLMOperationCompletion
+	case strings.HasSuffix(path, "/embeddings"):
+		return LLMOperationEmbedding
+	case strings.HasSuffix(path, "/moderations"):
+		return LLMOperationModeration
+	case strings.Contains(path, "/images/"):
+		return LLMOperationImageGeneration
+	case strings.HasSuffix(path, "/audio/transcriptions"):
+		return LLMOperationAudioTranscription
+	case strings.HasSuffix(path, "/audio/speech"):
+		return LLMOperationAudioGeneration
+	default:
+		return LLMOperationCompletion
+	}
+}
+
+// jsonModel returns the model named in a JSON request body, if any
+func jsonModel(body []byte) string {
+	var request struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return ""
+	}
+	return request.Model
+}
+
+// parseLLMUsage reads the token usage, model and request ID from an OpenAI or
+// Anthropic JSON response body
+func parseLLMUsage(body []byte) (TokenUsage, string) {
+	var response struct {
+		ID    string `json:"id"`
+		Model string `json:"model"`
+		Usage struct {
+			// OpenAI
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+			// Anthropic
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return TokenUsage{}, ""
+	}
+
+	usage := TokenUsage{
+		Model:            response.Model,
+		PromptTokens:     response.Usage.PromptTokens + response.Usage.InputTokens,
+		CompletionTokens: response.Usage.CompletionTokens + response.Usage.OutputTokens,
+		TotalTokens:      response.Usage.TotalTokens,
+	}
+	return usage, response.ID
+}
@@ -0,0 +1,173 @@
+package untrace
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+// redirectTransport sends every request to target instead, keeping its path
+type redirectTransport struct {
+	target *url.URL
+}
+
+// RoundTrip sends the request to the target
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.URL.Scheme = t.target.Scheme
+	out.URL.Host = t.target.Host
+	out.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(out)
+}
+
+const openAIChatResponse = `{
+  "id": "chatcmpl-8abc",
+  "object": "chat.completion",
+  "created": 1700000000,
+  "model": "gpt-4-0613",
+  "choices": [{"index": 0, "message": {"role": "assistant", "content": "Hello!"}, "finish_reason": "stop"}],
+  "usage": {"prompt_tokens": 1000, "completion_tokens": 500, "total_tokens": 1500}
+}`
+
+const anthropicMessageResponse = `{
+  "id": "msg_01xyz",
+  "type": "message",
+  "role": "assistant",
+  "model": "claude-3-haiku-20240307",
+  "content": [{"type": "text", "text": "Hello!"}],
+  "stop_reason": "end_turn",
+  "usage": {"input_tokens": 4000, "output_tokens": 800}
+}`
+
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		request     string
+		status      int
+		response    string
+		wantSpan    string
+		wantModel   string
+		wantPrompt  int64
+		wantTotal   int64
+		wantRequest string
+	}{
+		{
+			name:        "openai chat completion",
+			url:         "https://api.openai.com/v1/chat/completions",
+			request:     `{"model":"gpt-4","messages":[{"role":"user","content":"Hi"}]}`,
+			status:      http.StatusOK,
+			response:    openAIChatResponse,
+			wantSpan:    "openai.chat",
+			wantModel:   "gpt-4",
+			wantPrompt:  1000,
+			wantTotal:   1500,
+			wantRequest: "chatcmpl-8abc",
+		},
+		{
+			name:        "anthropic message",
+			url:         "https://api.anthropic.com/v1/messages",
+			request:     `{"model":"claude-3-haiku-20240307","max_tokens":1024,"messages":[{"role":"user","content":"Hi"}]}`,
+			status:      http.StatusOK,
+			response:    anthropicMessageResponse,
+			wantSpan:    "anthropic.chat",
+			wantModel:   "claude-3-haiku-20240307",
+			wantPrompt:  4000,
+			wantTotal:   4800,
+			wantRequest: "msg_01xyz",
+		},
+		{
+			name:      "api error",
+			url:       "https://api.openai.com/v1/chat/completions",
+			request:   `{"model":"gpt-4"}`,
+			status:    http.StatusTooManyRequests,
+			response:  `{"error":{"message":"Rate limit reached","type":"requests"}}`,
+			wantSpan:  "openai.chat",
+			wantModel: "gpt-4",
+		},
+		{
+			name:     "other host",
+			url:      "https://example.com/v1/chat/completions",
+			request:  `{"model":"gpt-4"}`,
+			status:   http.StatusOK,
+			response: openAIChatResponse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var traceparent, received string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				traceparent = r.Header.Get("traceparent")
+				body, _ := io.ReadAll(r.Body)
+				received = string(body)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.response)
+			}))
+			t.Cleanup(server.Close)
+			target, _ := url.Parse(server.URL)
+
+			client, exporter := newTestClient(t, nil)
+			httpClient := &http.Client{Transport: NewTransport(client, redirectTransport{target: target})}
+
+			req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, tt.url, strings.NewReader(tt.request))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			// The request and response bodies reach their readers intact
+			if received != tt.request {
+				t.Errorf("server received %q, want %q", received, tt.request)
+			}
+			if string(body) != tt.response {
+				t.Errorf("caller read %q, want the server's response", body)
+			}
+
+			spans := exportedSpans(t, client, exporter)
+			if tt.wantSpan == "" {
+				if len(spans) != 0 || traceparent != "" {
+					t.Errorf("traced a request to another host: spans %v, traceparent %q", spanNames(spans), traceparent)
+				}
+				return
+			}
+
+			span := findSpan(t, spans, tt.wantSpan)
+			if !strings.Contains(traceparent, span.SpanContext.TraceID().String()) {
+				t.Errorf("traceparent = %q, want trace %s", traceparent, span.SpanContext.TraceID())
+			}
+			if model, _ := attrValue(span.Attributes, LLMModelKey); model.AsString() != tt.wantModel {
+				t.Errorf("%s = %q, want %q", LLMModelKey, model.AsString(), tt.wantModel)
+			}
+
+			if tt.status >= http.StatusBadRequest {
+				if span.Status.Code != codes.Error {
+					t.Errorf("status = %v, want %v", span.Status.Code, codes.Error)
+				}
+				return
+			}
+			if prompt, _ := attrValue(span.Attributes, LLMPromptTokensKey); prompt.AsInt64() != tt.wantPrompt {
+				t.Errorf("%s = %d, want %d", LLMPromptTokensKey, prompt.AsInt64(), tt.wantPrompt)
+			}
+			if total, _ := attrValue(span.Attributes, LLMTotalTokensKey); total.AsInt64() != tt.wantTotal {
+				t.Errorf("%s = %d, want %d", LLMTotalTokensKey, total.AsInt64(), tt.wantTotal)
+			}
+			if id, _ := attrValue(span.Attributes, LLMRequestIDKey); id.AsString() != tt.wantRequest {
+				t.Errorf("%s = %q, want %q", LLMRequestIDKey, id.AsString(), tt.wantRequest)
+			}
+			if tokens := sumValue(t, collectMetric(t, client.snapshotReader, "llm.total.tokens")); int64(tokens) != tt.wantTotal {
+				t.Errorf("llm.total.tokens = %v, want %d", tokens, tt.wantTotal)
+			}
+		})
+	}
+}
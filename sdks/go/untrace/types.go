@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -31,6 +32,7 @@ type LLMSpanOptions struct {
 	PromptTokens     *int
 	CompletionTokens *int
 	TotalTokens      *int
+	ReasoningTokens  *int
 	Temperature      *float64
 	TopP             *float64
 	MaxTokens        *int
@@ -45,7 +47,40 @@ type LLMSpanOptions struct {
 	ErrorType        *string
 	RequestID        *string
 	UsageReason      *string
-	Attributes       map[string]interface{}
+
+	MatchedStopSequence *string
+	Modalities          []string
+	InputImageCount     *int
+	InputAudioSeconds   *float64
+	CacheReadTokens     *int
+	CacheCreationTokens *int
+	QueueWaitMs         *int
+	Messages            []Message
+	OutputMessages      []Message
+	Attributes          map[string]interface{}
+}
+
+// Modality values for LLMSpanOptions.Modalities
+const (
+	ModalityText  = "text"
+	ModalityImage = "image"
+	ModalityAudio = "audio"
+)
+
+// Message represents a single chat message sent to or received from an LLM
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// EmbeddingSpanOptions represents options for creating embedding spans
+type EmbeddingSpanOptions struct {
+	Provider   string
+	Model      string
+	InputCount int
+	// Dimension is the length of the embedding vectors, if known
+	Dimension  int
+	Attributes map[string]interface{}
 }
 
 // WorkflowOptions represents options for creating workflows
@@ -55,6 +90,10 @@ type WorkflowOptions struct {
 	Version   string
 	ParentID  string
 	Metadata  map[string]interface{}
+
+	// PropagateWorkflowBaggage places the run, user and session IDs in the
+	// baggage of the workflow context so they reach downstream services
+	PropagateWorkflowBaggage bool
 }
 
 // TokenUsage represents token usage information
@@ -64,16 +103,26 @@ type TokenUsage struct {
 	TotalTokens      int
 	Model            string
 	Provider         string
+	// ReasoningTokens are the hidden reasoning tokens of reasoning models,
+	// billed as output but not included in CompletionTokens
+	ReasoningTokens int
 }
 
 // Cost represents cost information
 type Cost struct {
 	Prompt     float64
 	Completion float64
+	Reasoning  float64
 	Total      float64
 	Currency   string
 	Model      string
 	Provider   string
+	// NativeAmount and NativeUnit hold the cost in the provider's billing
+	// unit (e.g. credits), for reconciling with provider invoices
+	NativeAmount float64
+	NativeUnit   string
+	// Attributes holds the attributes of the LLM span the cost belongs to
+	Attributes map[string]interface{}
 }
 
 // SpanOptions represents options for creating spans
@@ -82,6 +131,10 @@ type SpanOptions struct {
 	Kind       trace.SpanKind
 	Attributes map[string]interface{}
 	Parent     trace.SpanContext
+
+	// Links relate the span to spans other than its parent, e.g. a batch
+	// span to the request spans it serves. See LinkFromContext
+	Links []trace.Link
 }
 
 // Workflow represents a workflow context
@@ -96,6 +149,8 @@ type Workflow interface {
 type Tracer interface {
 	StartLLMSpan(ctx context.Context, name string, opts LLMSpanOptions) (context.Context, trace.Span)
 	StartSpan(ctx context.Context, name string, opts SpanOptions) (context.Context, trace.Span)
+	StartEmbeddingSpan(ctx context.Context, name string, opts EmbeddingSpanOptions) (context.Context, trace.Span)
+	AddLLMEvent(span trace.Span, name string, attrs map[string]interface{})
 	GetTracer() trace.Tracer
 }
 
@@ -105,11 +160,25 @@ type Metrics interface {
 	RecordLatency(duration time.Duration, attributes map[string]interface{})
 	RecordError(err error, attributes map[string]interface{})
 	RecordCost(cost Cost)
+	RecordCostFromUsage(usage TokenUsage) (Cost, error)
+	RecordClassification(target string, labels map[string]float64)
+	RecordLLMError(llmErr LLMError)
+	RecordAbortedStream(tokens int, attributes map[string]interface{})
+	RecordColdStart()
+	RecordCacheHit(attributes map[string]interface{})
+	RecordQueueWait(wait time.Duration, attributes map[string]interface{})
+	RecordRetries(attempts int, succeeded bool, attributes map[string]interface{})
+	RecordActiveWorkflows(delta int)
+	RecordInFlightLLMCalls(delta int)
+	ActiveCounts() (workflows, llmCalls int64)
+	WithContext(ctx context.Context) Metrics
 }
 
 // Context represents the context manager interface
 type Context interface {
 	StartWorkflow(name, runID string, opts WorkflowOptions) Workflow
+	StartWorkflowContext(ctx context.Context, name, runID string, opts WorkflowOptions) Workflow
+	// Deprecated: use WorkflowFromContext
 	GetCurrentWorkflow() Workflow
 	SetAttribute(key string, value interface{})
 	SetAttributes(attrs map[string]interface{})
@@ -122,6 +191,9 @@ type Client interface {
 	Context() Context
 	Shutdown(ctx context.Context) error
 	Flush(ctx context.Context) error
+	FlushWithCount(ctx context.Context) (int, error)
+	TracerProvider() *sdktrace.TracerProvider
+	MetricsSnapshot() MetricsSnapshot
 }
 
 // Attribute helpers for common types
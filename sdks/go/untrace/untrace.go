@@ -3,4 +3,23 @@ package untrace
 // This file provides the main public API for the Untrace Go SDK
 
 // All types and functions are defined in the individual files
-// This file serves as the main entry point for the package
\ No newline at end of file
+// This file serves as the main entry point for the package
+
+// SDKVersion is the version of the Untrace Go SDK, reported as
+// telemetry.sdk.version. It is distinct from Config.Version, which is the
+// version of the instrumented service.
+const SDKVersion = "0.1.0"
+
+// userAgent is the User-Agent sent with every export request
+const userAgent = "untrace-sdk-go/" + SDKVersion
+
+// PayloadSchemaVersion is the version of the Untrace export payload schema,
+// sent with every export so the server can decode it
+const PayloadSchemaVersion = "1"
+
+// Schema version headers: the version the SDK sends, and the version the
+// server answers with when it prefers a different one
+const (
+	schemaVersionHeader       = "X-Untrace-Schema-Version"
+	acceptSchemaVersionHeader = "X-Untrace-Accept-Schema-Version"
+)